@@ -0,0 +1,313 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// BackgroundDir is the directory, in the migration directory, containing background sql files.
+	BackgroundDir = "background"
+	// TrackingKindBackground is the kind of tracking entry recorded for a background file, its cursor
+	// is updated after each batch.
+	TrackingKindBackground = "background"
+	// DefaultBatchSize is the number of rowids in a batch, for background files that do not set one.
+	DefaultBatchSize = 1000
+)
+
+// backgroundHeaderRegexp matches the header lines, of a background file, that declare the table
+// and the batch size; e.g. `-- table: users` and `-- batch: 5000`
+var backgroundHeaderRegexp = regexp.MustCompile(`(?m)^--\s*(table|batch)\s*:\s*(\S+)\s*$`)
+
+// isBackground returns true if the name (relative to the migration directory) is a background sql file
+func isBackground(name string) bool { return strings.HasPrefix(name, BackgroundDir+"/") }
+
+// backgroundFile is a parsed background sql file
+type backgroundFile struct {
+	Name      string
+	Table     string
+	BatchSize int64
+	Body      string
+	Hash      string
+}
+
+// BackgroundProgress is the progress of a background file for a database
+type BackgroundProgress struct {
+	// Name is the path, relative to the migration directory, of the background file
+	Name string
+	// Cursor is the rowid that the next batch starts at; all rows before it have been processed
+	Cursor int64
+	// Last is the largest rowid of the table when the file was started, the last row the file covers; or
+	// when the progress was taken, if the file has not been started
+	Last int64
+	// Started is true if a batch has been run, Done is true once all the batches have been run
+	Started bool
+	Done    bool
+}
+
+// BackgroundOptions are the options for RunBackground
+type BackgroundOptions struct {
+	// Pause between batches, to give the application time to use the database
+	Pause time.Duration
+	// Progress, if set, is called after each batch
+	Progress func(progress BackgroundProgress)
+}
+
+// Backgrounds returns the background sql files, relative to the migration directory, sorted by name.
+//
+// Background files are used to change the data of large tables without holding the database lock
+// for a long time. Each file is an sql statement that is run for a range of rowids, using the :start
+// (inclusive) and :end (exclusive) parameters; the table the rowids are for is declared with a
+// `-- table: name` line, and the size of the range with an optional `-- batch: size` line:
+//
+//	-- table: users
+//	-- batch: 5000
+//	UPDATE users SET email_lower = lower(email) WHERE rowid >= :start AND rowid < :end;
+//
+// Background files are not applied by Upgrade, but by RunBackground.
+func (mng *Manager) Backgrounds() ([]string, error) {
+	backgroundDir := filepath.Join(mng.dir, BackgroundDir)
+	if !mng.isDir(backgroundDir) {
+		return nil, nil
+	}
+	var backgrounds []string
+	err := fs.WalkDir(mng.FS(), backgroundDir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isSQLFile(filename) {
+			return nil
+		}
+		backgrounds = append(backgrounds, path.Join(BackgroundDir, strings.TrimPrefix(filename, backgroundDir+"/")))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %v: %w", backgroundDir, err)
+	}
+	sort.Strings(backgrounds)
+	return backgrounds, nil
+}
+
+// loadBackground will render, and parse the header of, the background file
+func (mng *Manager) loadBackground(name string) (backgroundFile, error) {
+	filename := filepath.Join(mng.dir, name)
+	body, hash, err := mng.renderSQLFile(filename)
+	if err != nil {
+		return backgroundFile{}, err
+	}
+	file := backgroundFile{Name: name, BatchSize: DefaultBatchSize, Body: string(body), Hash: hash}
+	for _, match := range backgroundHeaderRegexp.FindAllStringSubmatch(file.Body, -1) {
+		switch match[1] {
+		case "table":
+			file.Table = match[2]
+		case "batch":
+			size, err := strconv.ParseInt(match[2], 10, 64)
+			if err != nil || size < 1 {
+				return file, fmt.Errorf("background file %v: invalid batch size `%v`", filename, match[2])
+			}
+			file.BatchSize = size
+		}
+	}
+	if file.Table == "" {
+		return file, fmt.Errorf("background file %v: missing `-- table: name` line", filename)
+	}
+	return file, nil
+}
+
+// backgroundRow is the tracking entry of a background file
+type backgroundRow struct {
+	RowID     int64
+	Cursor    int64
+	Completed bool
+	// LastRowID is not valid for files started before it was recorded
+	LastRowID sql.NullInt64
+}
+
+// backgroundRowFor returns the tracking entry for the background file, found is false if it has not been started
+func (mng *Manager) backgroundRowFor(db Executor, name string) (row backgroundRow, found bool, err error) {
+	const (
+		SelectBackgroundSQL = `
+	SELECT ROWID, cursor, completed_at != '', last_rowid
+	FROM %s
+	WHERE kind = ? AND file_path = ?
+	ORDER by ROWID desc
+	LIMIT 1;
+	`
+	)
+	source, err := mng.trackingSource(db)
+	if err != nil {
+		return row, false, err
+	}
+	err = db.QueryRowContext(context.Background(), fmt.Sprintf(SelectBackgroundSQL, source), TrackingKindBackground, name).
+		Scan(&row.RowID, &row.Cursor, &row.Completed, &row.LastRowID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return row, false, nil
+	}
+	if err != nil {
+		return row, false, ErrTrackingRead{Err: err, TableName: mng.TableName()}
+	}
+	return row, true, nil
+}
+
+// lastRowID returns the largest rowid of the table
+func (mng *Manager) lastRowID(db Executor, table string) (last int64, err error) {
+	err = db.QueryRowContext(context.Background(),
+		fmt.Sprintf(`SELECT coalesce(max(rowid), 0) FROM %v.%v;`, quoteIdentifier(mng.Schema()), quoteIdentifier(table)),
+	).Scan(&last)
+	return last, err
+}
+
+// BackgroundProgress returns the progress of each of the background files for the db
+func (mng *Manager) BackgroundProgress(db Executor) ([]BackgroundProgress, error) {
+	if err := mng.verifyApplicationID(db, false); err != nil {
+		return nil, err
+	}
+	names, err := mng.Backgrounds()
+	if err != nil {
+		return nil, err
+	}
+	progress := make([]BackgroundProgress, 0, len(names))
+	hasTrackingTable := mng.HasTrackingTable(db)
+	for _, name := range names {
+		p := BackgroundProgress{Name: name}
+		if hasTrackingTable {
+			row, found, err := mng.backgroundRowFor(db, name)
+			if err != nil {
+				return nil, err
+			}
+			p.Cursor, p.Started, p.Done = row.Cursor, found, row.Completed
+			if row.LastRowID.Valid {
+				p.Last = row.LastRowID.Int64
+				progress = append(progress, p)
+				continue
+			}
+		}
+		if file, err := mng.loadBackground(name); err == nil {
+			// the table may not exist yet
+			p.Last, _ = mng.lastRowID(db, file.Table)
+		}
+		progress = append(progress, p)
+	}
+	return progress, nil
+}
+
+// RunBackground will run the batches of the background files that have not been completed, in name order.
+// Each batch is run, along with the update of the cursor in the tracking table, in its own transaction (see
+// Executor) so that the database lock is only held for a short time; and an interrupted run resumes from
+// the last completed batch. The rows of the table are those that exist when the file is started on, the
+// largest rowid is recorded in the tracking table; rows added afterwards are expected to be handled by
+// the application.
+//
+// RunBackground should be run after the database has been upgraded, and stops when the context is cancelled.
+func (mng *Manager) RunBackground(ctx context.Context, db Executor, author string, opts BackgroundOptions) error {
+	const (
+		InsertBackgroundSQL = `
+	INSERT INTO %s (file_path,file_hash,author,duration,created_at,kind,environments,cursor,last_rowid)
+	VALUES (?,?,?,0,datetime('now'),?,?,?,?);
+	`
+		UpdateLastRowIDSQL = `UPDATE %s SET last_rowid = ? WHERE ROWID = ?;`
+		UpdateCursorSQL    = `UPDATE %s SET cursor = ?, duration = duration + ? WHERE ROWID = ?;`
+		UpdateCompleteSQL  = `UPDATE %s SET completed_at = datetime('now') WHERE ROWID = ?;`
+	)
+	if _, err := mng.initTrackingTable(db); err != nil {
+		return err
+	}
+	names, err := mng.Backgrounds()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		row, found, err := mng.backgroundRowFor(db, name)
+		if err != nil {
+			return err
+		}
+		if row.Completed {
+			continue
+		}
+		file, err := mng.loadBackground(name)
+		if err != nil {
+			return err
+		}
+		last := row.LastRowID.Int64
+		if !row.LastRowID.Valid {
+			if last, err = mng.lastRowID(db, file.Table); err != nil {
+				return fmt.Errorf("background file %v: failed to get the last rowid of %v: %w", name, file.Table, err)
+			}
+			if found {
+				// started before the last rowid was recorded
+				if _, err = db.ExecContext(ctx, fmt.Sprintf(UpdateLastRowIDSQL, mng.trackingTable()), last, row.RowID); err != nil {
+					return ErrTrackingInfo{Err: err, TableName: mng.TableName()}
+				}
+			}
+		}
+		if !found {
+			sqlQuery := fmt.Sprintf(InsertBackgroundSQL, mng.trackingTable())
+			result, err := db.ExecContext(ctx, sqlQuery,
+				name, file.Hash, author, TrackingKindBackground, strings.Join(mng.Environments(), ","), row.Cursor, last,
+			)
+			if err != nil {
+				return ErrTrackingInfo{Err: err, TableName: mng.TableName()}
+			}
+			if row.RowID, err = result.LastInsertId(); err != nil {
+				return ErrTrackingInfo{Err: err, TableName: mng.TableName()}
+			}
+		}
+
+		progress := BackgroundProgress{Name: name, Cursor: row.Cursor, Last: last, Started: true}
+		for progress.Cursor <= last {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			var (
+				startT = time.Now()
+				end    = progress.Cursor + file.BatchSize
+			)
+			if end > last+1 {
+				// the last batch stops at the last row the file covers
+				end = last + 1
+			}
+			err = withTransaction(db, func(exec Executor) error {
+				_, err := exec.ExecContext(ctx, file.Body, sql.Named("start", progress.Cursor), sql.Named("end", end))
+				if err != nil {
+					return ErrApplyFile{Err: err, Sha1Hash: file.Hash, Filename: filepath.Join(mng.dir, name)}
+				}
+				duration := time.Now().Sub(startT).Seconds()
+				_, err = exec.ExecContext(ctx, fmt.Sprintf(UpdateCursorSQL, mng.trackingTable()), end, duration, row.RowID)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			progress.Cursor = end
+			if opts.Progress != nil {
+				opts.Progress(progress)
+			}
+			if opts.Pause > 0 && progress.Cursor <= last {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(opts.Pause):
+				}
+			}
+		}
+		if _, err = db.ExecContext(ctx, fmt.Sprintf(UpdateCompleteSQL, mng.trackingTable()), row.RowID); err != nil {
+			return ErrTrackingInfo{Err: err, TableName: mng.TableName()}
+		}
+		progress.Done = true
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		mng.Log().Printf("background file %v completed, through rowid %v", name, last)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_RunBackground(t *testing.T) {
+	const usersSQL = `
+CREATE TABLE users ( id INTEGER PRIMARY KEY, email TEXT, lower_email TEXT );
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i+1 FROM n WHERE i < 250)
+INSERT INTO users(email) SELECT 'User' || i || '@Example.com' FROM n;
+`
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt":             {Data: []byte("users.sql\n")},
+		"migrations/users.sql":                {Data: []byte(usersSQL)},
+		"migrations/background/lower.sql.tpl": {Data: []byte("-- table: users\n-- batch: 100\nUPDATE users SET lower_email = lower(email) WHERE rowid >= :start AND rowid < :end;\n")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatalf("open error, expected nil got %v", err)
+	}
+	defer db.Close()
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Fatalf("upgrade error, expected nil got %v", err)
+	}
+	remaining := func() (n int) {
+		t.Helper()
+		if err := db.QueryRow(`SELECT COUNT(*) FROM users WHERE lower_email IS NULL`).Scan(&n); err != nil {
+			t.Fatalf("count error, expected nil got %v", err)
+		}
+		return n
+	}
+	state := func() MigrationState {
+		t.Helper()
+		statuses, err := migrations.Status(db)
+		if err != nil {
+			t.Fatalf("status error, expected nil got %v", err)
+		}
+		for _, status := range statuses {
+			if status.Version == "background/lower.sql.tpl" {
+				return status.State
+			}
+		}
+		t.Fatalf("status, expected background/lower.sql.tpl to be listed")
+		return ""
+	}
+	if s := state(); s != StatePending {
+		t.Errorf("state, expected %v got %v", StatePending, s)
+	}
+
+	// stop after the first batch, as if the process was interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	err = migrations.RunBackground(ctx, db, "test", BackgroundOptions{
+		Progress: func(BackgroundProgress) { cancel() },
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("run background error, expected context.Canceled got %v", err)
+	}
+	if n := remaining(); n != 151 {
+		t.Errorf("remaining, expected 151 got %v", n)
+	}
+	if s := state(); s != StateInProgress {
+		t.Errorf("state, expected %v got %v", StateInProgress, s)
+	}
+
+	// rows added after the file was started are left to the application
+	if _, err = db.Exec(`INSERT INTO users(email) VALUES ('Late@Example.com'), ('Later@Example.com')`); err != nil {
+		t.Fatalf("insert error, expected nil got %v", err)
+	}
+
+	// resume, the first batch should not be run again
+	var batches int
+	err = migrations.RunBackground(context.Background(), db, "test", BackgroundOptions{
+		Progress: func(progress BackgroundProgress) {
+			if !progress.Done {
+				batches++
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("run background error, expected nil got %v", err)
+	}
+	if batches != 2 {
+		t.Errorf("batches, expected 2 got %v", batches)
+	}
+	if n := remaining(); n != 2 {
+		t.Errorf("remaining, expected 2 got %v", n)
+	}
+	if s := state(); s != StateApplied {
+		t.Errorf("state, expected %v got %v", StateApplied, s)
+	}
+	progress, err := migrations.BackgroundProgress(db)
+	if err != nil {
+		t.Fatalf("background progress error, expected nil got %v", err)
+	}
+	if len(progress) != 1 || !progress[0].Done || progress[0].Last != 250 {
+		t.Errorf("background progress, expected done through 250 got %+v", progress)
+	}
+}
@@ -0,0 +1,141 @@
+package migration
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// resolveBaselines replaces the tracked versions that were squashed into a baseline with the baseline.
+// A database that has applied some, but not all, of the versions squashed into a baseline can not be
+// upgraded with this sequence; and ErrBeforeBaseline is returned.
+func resolveBaselines(tracked []trackedVersion, entries []versionEntry) ([]trackedVersion, error) {
+	for _, entry := range entries {
+		if len(entry.Squashed) == 0 {
+			continue
+		}
+		// the squashed versions may contain earlier baselines
+		inner, err := resolveBaselines(tracked, entry.Squashed)
+		if err != nil {
+			return nil, err
+		}
+		squashed := make(map[string]bool, len(entry.Squashed))
+		for _, version := range entry.Squashed {
+			squashed[version.Name] = true
+		}
+		applied := make(map[string]bool, len(entry.Squashed))
+		for _, version := range inner {
+			if squashed[version.Name] && version.Kind == TrackingKindVersion {
+				applied[version.Name] = true
+			}
+		}
+		if len(applied) == 0 {
+			// the database does not have any of the squashed versions; either it is a new database,
+			// or the baseline has been applied.
+			continue
+		}
+		if len(applied) != len(squashed) {
+			var missing []string
+			for _, version := range entry.Squashed {
+				if !applied[version.Name] {
+					missing = append(missing, version.Name)
+				}
+			}
+			return nil, ErrBeforeBaseline{Baseline: entry.Name, Missing: missing}
+		}
+		// replace the squashed versions with the baseline
+		resolved := make([]trackedVersion, 0, len(inner))
+		added := false
+		for _, version := range inner {
+			if !squashed[version.Name] {
+				resolved = append(resolved, version)
+				continue
+			}
+			if !added {
+				resolved = append(resolved, trackedVersion{Name: entry.Name, Kind: TrackingKindVersion})
+				added = true
+			}
+		}
+		tracked = resolved
+	}
+	return tracked, nil
+}
+
+// SquashSequence returns the new contents of the sequence file, where all the versions up to and including
+// through have been replaced by a baseline sql file; along with the contents of the squashed sequence file
+// listing the versions that were replaced. Databases that have applied all the squashed versions will
+// treat the baseline as applied, new databases will apply the baseline.
+//
+// baseline and squashed are the file names, relative to the migration directory, of the baseline sql file and the
+// squashed sequence file. through must be the last version of a line in the sequence file, and none of the
+// squashed versions can be tagged for environments.
+func (mng *Manager) SquashSequence(through, baseline, squashed string) (sequence []byte, squashedSequence []byte, err error) {
+	seqFile := SequenceFilename
+	filename := filepath.Join(mng.dir, seqFile)
+	if !mng.exists(filename) {
+		return nil, nil, fmt.Errorf("squash requires a %v file in %v", SequenceFilename, mng.dir)
+	}
+	body, err := mng.readAllFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		walk         = sequenceWalk{mng: mng}
+		squashedBuf  bytes.Buffer
+		sequenceBuf  bytes.Buffer
+		squashedLine = 0
+	)
+	fmt.Fprintf(&squashedBuf, "# versions squashed into %v\n", baseline)
+	for _, line := range readSequenceLines(bytes.NewReader(body)) {
+		start := len(walk.entries)
+		walk.line(seqFile, line, []string{seqFile}, nil)
+		if len(walk.problems) != 0 {
+			return nil, nil, walk.problems[0]
+		}
+		entries := walk.entries[start:]
+		for _, entry := range entries {
+			if len(entry.Environments) != 0 {
+				return nil, nil, fmt.Errorf("version %v is tagged for environments %v, and can not be squashed",
+					entry.Name, strings.Join(entry.Environments, ","),
+				)
+			}
+		}
+		if directive, _ := parseSequenceLine(line.Text); directive.Directive == DirectiveBaseline {
+			// an earlier baseline, keep it so that databases from before it are still recognised
+			fmt.Fprintf(&squashedBuf, "%v %v %v\n", DirectiveBaseline, path.Clean(directive.Value), path.Clean(directive.Squashed))
+		} else {
+			for _, entry := range entries {
+				fmt.Fprintln(&squashedBuf, entry.Name)
+			}
+		}
+		for i, entry := range entries {
+			if entry.Name != through {
+				continue
+			}
+			if i != len(entries)-1 {
+				return nil, nil, fmt.Errorf("version %v is not the last version of line %d `%v` of %v",
+					through, line.LineNo, line.Text, filename,
+				)
+			}
+			squashedLine = line.LineNo
+		}
+		if squashedLine != 0 {
+			break
+		}
+	}
+	if squashedLine == 0 {
+		return nil, nil, ErrUnknownVersion(through)
+	}
+
+	fmt.Fprintf(&sequenceBuf, "# versions through %v were squashed into %v, see %v\n", through, baseline, squashed)
+	fmt.Fprintf(&sequenceBuf, "%v %v %v\n", DirectiveBaseline, baseline, squashed)
+	// copy the rest of the sequence file, as is
+	rest := strings.SplitAfter(string(body), "\n")
+	if squashedLine < len(rest) {
+		sequenceBuf.WriteString(strings.Join(rest[squashedLine:], ""))
+	}
+	return sequenceBuf.Bytes(), squashedBuf.Bytes(), nil
+}
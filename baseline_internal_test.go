@@ -0,0 +1,105 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_SquashSequence(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt": {Data: []byte("# the tables\na.sql\nb.sql\nc.sql\n")},
+		"migrations/a.sql":        {Data: []byte("CREATE TABLE a ( name TEXT );")},
+		"migrations/b.sql":        {Data: []byte("CREATE TABLE b ( name TEXT );")},
+		"migrations/c.sql":        {Data: []byte("CREATE TABLE c ( name TEXT );")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+
+	openDB := func(name string) *sql.DB {
+		t.Helper()
+		dbFilename, cleanup := NewTestDBFilename(t, nil)
+		t.Cleanup(cleanup)
+		db, err := sql.Open("sqlite3", dbFilename)
+		if err != nil {
+			t.Fatalf("%v open error, expected nil got %v", name, err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	// a database with all the squashed versions
+	oldDB := openDB("old")
+	if _, _, err := migrations.UpgradeTo(oldDB, "test", "b.sql"); err != nil {
+		t.Fatalf("upgrade to error, expected nil got %v", err)
+	}
+	// a database with only some of the squashed versions
+	partialDB := openDB("partial")
+	if _, _, err := migrations.UpgradeTo(partialDB, "test", "a.sql"); err != nil {
+		t.Fatalf("upgrade to error, expected nil got %v", err)
+	}
+
+	if _, _, err := migrations.SquashSequence("unknown.sql", "baseline.sql", "squashed.txt"); !errors.As(err, new(ErrUnknownVersion)) {
+		t.Errorf("squash unknown error, expected ErrUnknownVersion got %v", err)
+	}
+	sequence, squashed, err := migrations.SquashSequence("b.sql", "baseline.sql", "squashed.txt")
+	if err != nil {
+		t.Fatalf("squash error, expected nil got %v", err)
+	}
+	expectedSequence := "# versions through b.sql were squashed into baseline.sql, see squashed.txt\n" +
+		"baseline baseline.sql squashed.txt\n" +
+		"c.sql\n"
+	if string(sequence) != expectedSequence {
+		t.Errorf("sequence, expected %q got %q", expectedSequence, sequence)
+	}
+	expectedSquashed := "# versions squashed into baseline.sql\na.sql\nb.sql\n"
+	if string(squashed) != expectedSquashed {
+		t.Errorf("squashed, expected %q got %q", expectedSquashed, squashed)
+	}
+
+	fsys["migrations/sequence.txt"] = &fstest.MapFile{Data: sequence}
+	fsys["migrations/squashed.txt"] = &fstest.MapFile{Data: squashed}
+	fsys["migrations/baseline.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a ( name TEXT );\nCREATE TABLE b ( name TEXT );")}
+	delete(fsys, "migrations/a.sql")
+	delete(fsys, "migrations/b.sql")
+
+	if err := migrations.Validate(); err != nil {
+		t.Errorf("validate error, expected nil got %v", err)
+	}
+	versions, err := migrations.Versions()
+	if err != nil {
+		t.Fatalf("versions error, expected nil got %v", err)
+	}
+	if expected := []string{InitialVersion, "baseline.sql", "c.sql"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("versions, expected %v got %v", expected, versions)
+	}
+
+	upgrade := func(name string, db *sql.DB, start string) {
+		t.Helper()
+		startVersion, end, err := migrations.Upgrade(db, "test")
+		if err != nil {
+			t.Fatalf("%v upgrade error, expected nil got %v", name, err)
+		}
+		if startVersion != start || end != "c.sql" {
+			t.Errorf("%v upgrade, expected %v to c.sql got %v to %v", name, start, startVersion, end)
+		}
+		history, err := migrations.History(db)
+		if err != nil {
+			t.Fatalf("%v history error, expected nil got %v", name, err)
+		}
+		if history[len(history)-1] != "c.sql" {
+			t.Errorf("%v history, expected c.sql to be applied got %v", name, history)
+		}
+	}
+	upgrade("old", oldDB, "baseline.sql")
+	upgrade("new", openDB("new"), "")
+
+	_, _, err = migrations.Upgrade(partialDB, "test")
+	var beforeErr ErrBeforeBaseline
+	if !errors.As(err, &beforeErr) {
+		t.Fatalf("partial upgrade error, expected ErrBeforeBaseline got %v", err)
+	}
+	if expected := []string{"b.sql"}; !reflect.DeepEqual(beforeErr.Missing, expected) {
+		t.Errorf("partial upgrade missing, expected %v got %v", expected, beforeErr.Missing)
+	}
+}
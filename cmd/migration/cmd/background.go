@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	backgroundPause time.Duration
+
+	backgroundCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "background",
+			Short: "background runs the batches of the background files on the given database.",
+			Long: `background runs the batches of the background files on the given database
+
+Each batch of a background file is run in its own short transaction, and the progress is
+recorded in the tracking table; so the application can keep using the database between
+batches, and an interrupted run resumes from the last completed batch. The database should
+be upgraded first.
+`,
+			Run: runBackgroundCmd,
+		}
+		cmd.Flags().DurationVar(&backgroundPause, "pause", 0, "time to pause between batches")
+		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema (main, or an --attach'ed database) to run the background files on")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = backgroundCmd
+)
+
+func runBackgroundCmd(cmd *cobra.Command, _ []string) {
+
+	migrations := migrationFor(cmd, migrationPath, tableName())
+	migrations.SetSchema(databaseSchema)
+	log := getLogger(cmd)
+
+	if dbFilename == "" {
+		log.Print("database file must be given")
+		os.Exit(ExitCodeDatabase)
+	}
+	db, err := sqlite.New(dbFilename)
+	if err != nil {
+		log.Printf("error opening db %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	defer db.Close()
+	if err = attachDatabases(db); err != nil {
+		log.Printf("error attaching databases to %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	err = migrations.RunBackground(cmd.Context(), db.DB, author, migration.BackgroundOptions{
+		Pause: backgroundPause,
+		Progress: func(progress migration.BackgroundProgress) {
+			if progress.Done {
+				return
+			}
+			cursor := progress.Cursor
+			if cursor > progress.Last {
+				cursor = progress.Last
+			}
+			log.Printf("background file %v: rowid %v of %v", progress.Name, cursor, progress.Last)
+		},
+	})
+	if err != nil {
+		log.Printf("error running background files on db %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/internal/textdiff"
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkPathsCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "check-paths [old database files...]",
+			Short: "check that upgraded databases end up with the same schema as new databases",
+			Long: `check that upgraded databases end up with the same schema as new databases
+
+A new database is built at the latest version. Then, for each version of the sequence, a database
+is built at that version and upgraded to the latest version; or, if database files are given, a copy
+of each is upgraded to the latest version. The schema of each upgraded database is compared, object by
+object, with the schema of the new database, and the differences are reported; e.g. a default that
+differs.
+
+The version paths apply the current migration files, so a migration that was edited after it was
+applied is only found when database files, built with the old migration, are given.
+
+The database files are not changed.
+`,
+			Run: runCheckPathsCmd,
+		}
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = checkPathsCmd
+)
+
+// upgradePath is a way of getting to the latest version
+type upgradePath struct {
+	// Name describes the path; the version or database file it starts from
+	Name string
+	// build builds the database, that is upgraded to the latest version, at filename
+	build func(filename string) error
+}
+
+func runCheckPathsCmd(cmd *cobra.Command, args []string) {
+	log := getLogger(cmd)
+	migrations := migrationFor(cmd, migrationPath, tableName())
+	// the applied files would drown out the differences
+	migrations.SetLog(nil)
+	versions, err := migrations.Versions()
+	if err != nil {
+		log.Printf("error reading %v: %v", migrationPath, err)
+		os.Exit(ExitCodeValidation)
+	}
+
+	var paths []upgradePath
+	if len(args) == 0 {
+		// the initial version is the same as a new database, and the latest has nothing to upgrade
+		for _, version := range versions[1 : len(versions)-1] {
+			version := version
+			paths = append(paths, upgradePath{
+				Name:  "from version " + version,
+				build: func(filename string) error { return buildScratchDB(migrations, filename, version) },
+			})
+		}
+	}
+	for _, oldFilename := range args {
+		oldFilename := oldFilename
+		paths = append(paths, upgradePath{
+			Name:  "from database " + oldFilename,
+			build: func(filename string) error { return copyDatabase(oldFilename, filename) },
+		})
+	}
+
+	tmpDir, err := ioutil.TempDir("", "migration-check-paths")
+	if err != nil {
+		log.Printf("failed to create scratch dir: %v", err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	freshFilename := filepath.Join(tmpDir, "fresh.db")
+	if err = upgradeScratchDB(migrations, freshFilename); err != nil {
+		log.Printf("error building new database: %v", err)
+		os.Exit(ExitCodeDatabase)
+	}
+	fresh, err := dumpSchemaObjects(freshFilename, tableName())
+	if err != nil {
+		log.Printf("error dumping schema of new database: %v", err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	var diverged int
+	out := cmd.OutOrStdout()
+	for i, path := range paths {
+		filename := filepath.Join(tmpDir, fmt.Sprintf("path_%03d.db", i))
+		err := path.build(filename)
+		if err == nil {
+			err = upgradeScratchDB(migrations, filename)
+		}
+		if err != nil {
+			log.Printf("%v: error upgrading: %v", path.Name, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		upgraded, err := dumpSchemaObjects(filename, tableName())
+		if err != nil {
+			log.Printf("%v: error dumping schema: %v", path.Name, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		differences := compareSchemaObjects(fresh, upgraded)
+		if len(differences) == 0 {
+			fmt.Fprintf(out, "%v: ok\n", path.Name)
+			continue
+		}
+		diverged++
+		fmt.Fprintf(out, "%v: %d difference(s)\n", path.Name, len(differences))
+		for _, difference := range differences {
+			fmt.Fprintf(out, "\t%v\n", strings.ReplaceAll(strings.TrimSuffix(difference, "\n"), "\n", "\n\t"))
+		}
+	}
+	if diverged != 0 {
+		log.Printf("%d of %d upgrade path(s) do not match a new database", diverged, len(paths))
+		os.Exit(ExitCodeValidation)
+	}
+	log.Printf("all %d upgrade path(s) match a new database", len(paths))
+}
+
+// upgradeScratchDB will upgrade the database file to the latest version
+func upgradeScratchDB(migrations *migration.Manager, filename string) error {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, _, err = migrations.Upgrade(db, author)
+	return err
+}
+
+// copyDatabase will copy the database file, without changing it, to filename
+func copyDatabase(from, filename string) error {
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", "file:"+from+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec(`VACUUM INTO ?;`, filename)
+	return err
+}
+
+// dumpSchemaObjects returns the dump of each object of the main schema of the database file, without the
+// tracking table
+func dumpSchemaObjects(filename, trackingTable string) ([]schema.Object, error) {
+	db, err := sqlite.New(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return schema.DumpObjects(db, trackingTable)
+}
+
+// compareSchemaObjects returns a description of each object that is only in one of the schemas, or that
+// differs between them
+func compareSchemaObjects(expected, got []schema.Object) (differences []string) {
+	gotObjects := make(map[string]schema.Object, len(got))
+	for _, obj := range got {
+		gotObjects[obj.Key()] = obj
+	}
+	expectedObjects := make(map[string]bool, len(expected))
+	for _, obj := range expected {
+		expectedObjects[obj.Key()] = true
+		gotObj, ok := gotObjects[obj.Key()]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("%v: missing", obj.Key()))
+		case gotObj.Dump != obj.Dump:
+			differences = append(differences, fmt.Sprintf("%v: differs\n%v", obj.Key(), textdiff.Lines(obj.Dump, gotObj.Dump)))
+		}
+	}
+	for _, obj := range got {
+		if !expectedObjects[obj.Key()] {
+			differences = append(differences, fmt.Sprintf("%v: not in a new database", obj.Key()))
+		}
+	}
+	return differences
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffAgainst string
+
+	diffCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "diff --db a.db --against b.db",
+			Short: "show how the schema of a database differs from that of another",
+			Long: `show how the schema of a database differs from that of another
+
+The tables, columns, foreign keys, indexes, views and triggers of the --db database are compared with
+those of the --against database. Each difference is printed on a line starting with + for objects only
+in the --against database, - for objects only in the --db database, and ~ for objects that differ.
+The migrations tracking table is left out.
+
+Neither database is changed.
+`,
+			Run: runDiffCmd,
+		}
+		cmd.Flags().StringVar(&diffAgainst, "against", "", "the database file to compare against")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = diffCmd
+)
+
+func runDiffCmd(cmd *cobra.Command, _ []string) {
+	log := getLogger(cmd)
+	if dbFilename == "" || diffAgainst == "" {
+		log.Print("database files must be given with --db and --against")
+		os.Exit(ExitCodeDatabase)
+	}
+	var databases []*sqlite.DB
+	for _, filename := range []string{dbFilename, diffAgainst} {
+		// opening a missing file would create an empty database
+		if _, err := os.Stat(filename); err != nil {
+			log.Printf("error opening db %v: %v", filename, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		db, err := sqlite.New(filename)
+		if err != nil {
+			log.Printf("error opening db %v: %v", filename, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		defer db.Close()
+		databases = append(databases, db)
+	}
+
+	changes, err := schema.Diff(databases[0], databases[1], tableName())
+	if err != nil {
+		log.Printf("error comparing %v with %v: %v", dbFilename, diffAgainst, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	if changes.Empty() {
+		log.Printf("the schema of %v is the same as that of %v", dbFilename, diffAgainst)
+		return
+	}
+	fmt.Print(changes)
+}
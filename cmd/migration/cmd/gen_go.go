@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	genGoPackage  string
+	genGoOutput   string
+	genGoViews    bool
+	genGoPointers bool
+
+	genGoCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "gen-go --package name",
+			Short: "generate go structs for the tables of the schema",
+			Long: `generate go structs for the tables of the schema
+
+A go file is written, to stdout or the --output file, with a struct for each table; and, with --views,
+each view. Each struct has a field, with db and json tags, for each column, and a TableName method. The
+go type of a column comes from its declared type; nullable columns are the sql.NullX type of it, or with
+--pointers a pointer to it. The file is formatted, and marked as generated.
+
+With --db the schema of the database is used; otherwise the latest version is built in a scratch
+database. The migrations tracking table is left out.
+`,
+			Run: runGenGoCmd,
+		}
+		cmd.Flags().StringVar(&genGoPackage, "package", "models", "the package of the go file")
+		cmd.Flags().StringVar(&genGoOutput, "output", "", "the go file to write (default stdout)")
+		cmd.Flags().BoolVar(&genGoViews, "views", false, "generate structs for the views as well")
+		cmd.Flags().BoolVar(&genGoPointers, "pointers", false, "use pointers for nullable columns, rather than the sql.NullX types")
+		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema (main, temp or an --attach'ed database) of the --db to use")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = genGoCmd
+)
+
+func runGenGoCmd(cmd *cobra.Command, _ []string) {
+	log := getLogger(cmd)
+	if !token.IsIdentifier(genGoPackage) {
+		log.Printf("`%v` is not a valid go package name", genGoPackage)
+		os.Exit(ExitCodeValidation)
+	}
+
+	filename, schemaName := dbFilename, databaseSchema
+	if filename == "" {
+		migrations := migrationFor(cmd, migrationPath, tableName())
+		migrations.SetLog(nil)
+		tmpDir, err := ioutil.TempDir("", "migration-gen-go")
+		if err != nil {
+			log.Printf("failed to create scratch dir: %v", err)
+			os.Exit(ExitCodeOutputPath)
+		}
+		defer os.RemoveAll(tmpDir)
+		filename, schemaName = filepath.Join(tmpDir, "scratch.db"), generator.DefaultSchema
+		if err = upgradeScratchDB(migrations, filename); err != nil {
+			log.Printf("error building scratch database: %v", err)
+			os.Exit(ExitCodeDatabase)
+		}
+	} else if _, err := os.Stat(filename); err != nil {
+		// opening a missing file would create an empty database
+		log.Printf("error opening db %v: %v", filename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	db, err := sqlite.New(filename)
+	if err != nil {
+		log.Printf("error opening db %v: %v", filename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	defer db.Close()
+	if dbFilename != "" {
+		if err = attachDatabases(db); err != nil {
+			log.Printf("error attaching databases to %v: %v", filename, err)
+			os.Exit(ExitCodeDatabase)
+		}
+	}
+	dbSchema, err := db.SchemaNamed(schemaName)
+	if err != nil {
+		log.Printf("error getting schema %v of db %v: %v", schemaName, filename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	src, err := generator.GoStructs(dbSchema, generator.GoOptions{
+		Package:   genGoPackage,
+		Views:     genGoViews,
+		Pointers:  genGoPointers,
+		Generator: "migrate gen-go",
+		Skip:      []string{tableName()},
+	})
+	if err != nil {
+		log.Printf("error generating go structs: %v", err)
+		os.Exit(ExitCodeDatabase)
+	}
+	if genGoOutput == "" {
+		_, _ = cmd.OutOrStdout().Write(src)
+		return
+	}
+	if err = ioutil.WriteFile(genGoOutput, src, 0666); err != nil {
+		log.Printf("error writing %v: %v", genGoOutput, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	log.Printf("wrote go structs to %v", genGoOutput)
+}
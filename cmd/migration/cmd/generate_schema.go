@@ -42,7 +42,7 @@ be overwritten.
 		cmd.Flags().BoolVarP(&dataOnly, "data-only", "d", false, "Do not generate the Create statements for tables, views, etc..")
 		cmd.Flags().BoolVar(&useSingleFile, "single-file", false, "generate only one file containing all SQL statements")
 		cmd.Flags().StringVar(&tableNameFile, "table-name", filepath.Join(migrationPath, "table_order.txt"), "the file to specify the order in which to write the tables out.")
-		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema to dump")
+		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema (main, temp or an --attach'ed database) to dump")
 
 		rootCmd.AddCommand(cmd)
 		return cmd
@@ -80,7 +80,7 @@ type TableObjectDescriptor struct {
 	order      uint32
 }
 
-func (obj *TableObjectDescriptor) writeSchemaSQL(log *log.Logger, db *sql.DB, file *os.File, writeHeader, dataOnly bool, skipFields []string) (result map[string]bool, err error) {
+func (obj *TableObjectDescriptor) writeSchemaSQL(log *log.Logger, db *sql.DB, schemaName string, file *os.File, writeHeader, dataOnly bool, skipFields []string) (result map[string]bool, err error) {
 	var (
 		name    string
 		sqlText string
@@ -135,7 +135,7 @@ func (obj *TableObjectDescriptor) writeSchemaSQL(log *log.Logger, db *sql.DB, fi
 	}
 	// We should only write out data for things that are tables.
 	if isTable {
-		insertSQL := generator.InsertSQL(db, name, skipFields...)
+		insertSQL := generator.InsertSQLForSchema(db, schemaName, name, skipFields...)
 		if len(insertSQL) != 0 {
 			result["data"] = true
 			if _, err = file.Write(insertSQL); err != nil {
@@ -157,6 +157,8 @@ type tableWriter struct {
 	tableMap          map[string]TableObjectDescriptor
 	excludeTables     []string
 	order             uint32
+	// schemaName is the database schema being written out
+	schemaName string
 }
 
 func (w *tableWriter) OrderedTableNames() []string {
@@ -231,7 +233,7 @@ func (w *tableWriter) WriteTable(log *log.Logger, db *sql.DB, outputPath, name s
 		}
 		defer file.Close()
 	}
-	w.dMap[name], err = tblDesc.writeSchemaSQL(log, db, file, w.isSingleFile, w.dataOnly, w.excludedFieldsFor(name))
+	w.dMap[name], err = tblDesc.writeSchemaSQL(log, db, w.schemaName, file, w.isSingleFile, w.dataOnly, w.excludedFieldsFor(name))
 	if err != nil {
 		return fmt.Errorf("failed to write schema(%v): %w", name, err)
 	}
@@ -319,6 +321,15 @@ func runGenerateSchemaCmd(cmd *cobra.Command, args []string) {
 		theLogger.Printf("error opening db %v: %v", dbFilename, err)
 		os.Exit(ExitCodeDatabase)
 	}
+	if err = attachDatabases(db); err != nil {
+		theLogger.Printf("error attaching databases to %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	dbSchema, err := db.SchemaNamed(databaseSchema)
+	if err != nil {
+		theLogger.Printf("error getting schema %v of db %v: %v", databaseSchema, dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
 
 	if isOutputDir {
 		_ = os.RemoveAll(outputPath)
@@ -343,8 +354,8 @@ func runGenerateSchemaCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	tables, _ := db.Tables()
-	views, _ := db.Views()
+	tables, _ := dbSchema.Tables()
+	views, _ := dbSchema.Views()
 	isExcluded := func(name string) bool {
 		if len(excludedTables) == 0 {
 			return false
@@ -413,6 +424,7 @@ func runGenerateSchemaCmd(cmd *cobra.Command, args []string) {
 		dataOnly:          dataOnly,
 		excludedFields:    excludedFields,
 		excludeTables:     excludedTables,
+		schemaName:        dbSchema.Name(),
 	}
 
 	for _, name := range orderedTableNames {
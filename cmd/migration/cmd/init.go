@@ -1,11 +1,13 @@
 package cmd
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"os"
 
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +27,7 @@ a non-existant database; otherwise doing anything and an exiting with a code of
 			Run: runInitCmd,
 		}
 		cmd.Flags().BoolVarP(&force, "force", "f", false, "force action")
+		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema (main, or an --attach'ed database) to initialize")
 
 		rootCmd.AddCommand(cmd)
 		return cmd
@@ -36,6 +39,7 @@ a non-existant database; otherwise doing anything and an exiting with a code of
 func runInitCmd(cmd *cobra.Command, _ []string) {
 
 	migrations := migrationFor(cmd, migrationPath, tableName())
+	migrations.SetSchema(databaseSchema)
 	log := getLogger(cmd)
 
 	// check to see if the db file exists.
@@ -58,13 +62,18 @@ func runInitCmd(cmd *cobra.Command, _ []string) {
 			os.Exit(ExitCodeDatabase)
 		}
 	}
-	db, err := sql.Open("sqlite3", dbFilename)
+	sdb, err := sqlite.New(dbFilename)
 	if err != nil {
 		log.Printf("error opening db %v: %v", dbFilename, err)
 		os.Exit(ExitCodeDatabase)
 	}
+	defer sdb.Close()
+	if err = attachDatabases(sdb); err != nil {
+		log.Printf("error attaching databases to %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
 	// Now need to setup migrations
-	ver, ok, err := migrations.Init(db, author)
+	ver, ok, err := migrations.Init(sdb.DB, author)
 	if err != nil {
 		log.Printf("error opening db %v: %v", dbFilename, err)
 		os.Exit(ExitCodeDatabase)
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectFormat string
+
+	inspectCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "inspect --db file.db",
+			Short: "print the structure of the schema of a database",
+			Long: `print the structure of the schema of a database
+
+The tables, with their columns and foreign keys, views, indexes and triggers of the schema are printed;
+with --format text as a normalised dump, the same as schema-snapshot, and with --format json as a JSON
+document for other tools. The JSON document is described in the README. The migrations tracking table
+is left out.
+`,
+			Run: runInspectCmd,
+		}
+		cmd.Flags().StringVar(&inspectFormat, "format", "text", "the format to print the schema in; text or json")
+		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema (main, temp or an --attach'ed database) to inspect")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = inspectCmd
+)
+
+func runInspectCmd(cmd *cobra.Command, _ []string) {
+	log := getLogger(cmd)
+	if inspectFormat != "text" && inspectFormat != "json" {
+		log.Printf("unknown format `%v`, expected text or json", inspectFormat)
+		os.Exit(ExitCodeValidation)
+	}
+	if dbFilename == "" {
+		log.Print("database file must be given")
+		os.Exit(ExitCodeDatabase)
+	}
+	// opening a missing file would create an empty database
+	if _, err := os.Stat(dbFilename); err != nil {
+		log.Printf("error opening db %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	db, err := sqlite.New(dbFilename)
+	if err != nil {
+		log.Printf("error opening db %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	defer db.Close()
+	if err = attachDatabases(db); err != nil {
+		log.Printf("error attaching databases to %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	dbSchema, err := db.SchemaNamed(databaseSchema)
+	if err != nil {
+		log.Printf("error getting schema %v of db %v: %v", databaseSchema, dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	if inspectFormat == "text" {
+		dump, err := schema.Dump(dbSchema, tableName())
+		if err != nil {
+			log.Printf("error reading the schema of %v: %v", dbFilename, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		fmt.Print(dump)
+		return
+	}
+	exported, err := schema.Export(dbSchema, tableName())
+	if err != nil {
+		log.Printf("error reading the schema of %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(exported); err != nil {
+		log.Printf("error writing the schema of %v: %v", dbFilename, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintRules          []string
+	lintLargeTableRows int64
+
+	lintCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "lint",
+			Short: "check the migration files for risky SQLite operations",
+			Long: `check the migration files for risky SQLite operations
+
+Every migration file is checked; or, if --db is given, the files that would be applied to the database.
+The rules are:
+  drop-table           DROP TABLE, which loses the data of the table
+  drop-column          ALTER TABLE ... DROP COLUMN, which loses the data of the column
+  add-column-not-null  ALTER TABLE ... ADD COLUMN ... NOT NULL without a DEFAULT, which SQLite rejects
+  create-index         CREATE INDEX, without IF NOT EXISTS, on a large table (needs --db)
+  foreign-keys-pragma  PRAGMA foreign_keys inside a transaction, where it does nothing
+  sqlite-version       features newer than the min_sqlite_version of config.txt
+  begin-transaction    BEGIN in a file, with --transaction-per-file, where it fails
+
+The severity of a rule (error, warning or off) is set with a "lint rule severity" line in config.txt,
+or with --rule rule=severity. A file can turn rules off with a "-- lint:allow rule, ..." comment.
+
+The exit code is non-zero if there are errors.
+`,
+			Run: runLintCmd,
+		}
+		cmd.Flags().StringSliceVar(&lintRules, "rule", nil, "the severity of a rule, as rule=severity (error, warning or off)")
+		cmd.Flags().BoolVar(&transactionPerFile, "transaction-per-file", false, "lint the files as they are applied by upgrade --transaction-per-file")
+		cmd.Flags().Int64Var(&lintLargeTableRows, "large-table-rows", migration.DefaultLargeTableRows, "the number of rows that make a table large, for the create-index rule")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = lintCmd
+)
+
+func runLintCmd(cmd *cobra.Command, _ []string) {
+	log := getLogger(cmd)
+	migrations := migrationFor(cmd, migrationPath, tableName())
+	migrations.SetTransactionPerFile(transactionPerFile)
+
+	opts := migration.LintOptions{
+		Severities:     make(map[migration.LintRule]migration.LintSeverity),
+		LargeTableRows: lintLargeTableRows,
+	}
+	for _, ruleSeverity := range lintRules {
+		parts := strings.SplitN(ruleSeverity, "=", 2)
+		if len(parts) != 2 {
+			log.Printf("invalid rule `%v`, expected rule=severity", ruleSeverity)
+			os.Exit(ExitCodeValidation)
+		}
+		rule, err := migration.ParseLintRule(parts[0])
+		if err != nil {
+			log.Print(err)
+			os.Exit(ExitCodeValidation)
+		}
+		if opts.Severities[rule], err = migration.ParseLintSeverity(parts[1]); err != nil {
+			log.Print(err)
+			os.Exit(ExitCodeValidation)
+		}
+	}
+
+	var db migration.Executor
+	if dbFilename != "" {
+		if _, err := os.Stat(dbFilename); err != nil {
+			log.Printf("error opening db %v: %v", dbFilename, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		sqliteDB, err := sqlite.New(dbFilename)
+		if err != nil {
+			log.Printf("error opening db %v: %v", dbFilename, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		defer sqliteDB.Close()
+		if err = attachDatabases(sqliteDB); err != nil {
+			log.Printf("error attaching databases to %v: %v", dbFilename, err)
+			os.Exit(ExitCodeDatabase)
+		}
+		db = sqliteDB.DB
+	}
+
+	problems, err := migrations.Lint(db, opts)
+	if err != nil {
+		log.Printf("error linting %v: %v", migrationPath, err)
+		os.Exit(ExitCodeValidation)
+	}
+	var errors int
+	for _, problem := range problems {
+		fmt.Fprintln(cmd.OutOrStdout(), problem)
+		if problem.Severity == migration.LintError {
+			errors++
+		}
+	}
+	if errors != 0 {
+		log.Printf("found %d error(s) and %d warning(s) in %v", errors, len(problems)-errors, migrationPath)
+		os.Exit(ExitCodeValidation)
+	}
+	log.Printf("found %d warning(s) in %v", len(problems), migrationPath)
+}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	newFromDiff bool
+	newTarget   string
+	newRenames  []string
+
+	newCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "new [name]",
+			Short: "create a new migration file, and add it to the sequence",
+			Long: `create a new migration file, and add it to the sequence
+
+A file named <timestamp>_<name>.sql is created in the migration directory, and appended to its
+sequence.txt.
+
+With --from-diff the file is not empty. The latest version is built in a scratch database, and
+compared with the --target schema; a sql file, or a database file. The file has the statements that
+change the latest version into the target: ALTER TABLE ... ADD, RENAME and DROP COLUMN where SQLite
+allows it, and a rebuild of the table otherwise. A file that rebuilds tables turns foreign keys off,
+and begins its own transaction, so it can not be applied with --transaction-per-file. Columns are only
+renamed when given with --rename table.old_name=new_name; otherwise a removed column is dropped, along
+with its data, and an added column is added. DROP COLUMN is only used when the SQLite of this program,
+and the min_sqlite_version of config.txt, are at least 3.35.0.
+
+The statements are checked by applying them to the scratch database. Review the file before committing it.
+`,
+			Args: cobra.MaximumNArgs(1),
+			Run:  runNewCmd,
+		}
+		cmd.Flags().BoolVar(&newFromDiff, "from-diff", false, "generate the statements that change the latest version into the --target schema")
+		cmd.Flags().StringVar(&newTarget, "target", "", "the sql, or database, file of the target schema, for --from-diff")
+		cmd.Flags().StringArrayVar(&newRenames, "rename", nil, "a renamed column, as table.old_name=new_name, for --from-diff")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = newCmd
+)
+
+// dropColumnVersion is the first version of SQLite with ALTER TABLE ... DROP COLUMN
+const dropColumnVersion = "3.35.0"
+
+func runNewCmd(cmd *cobra.Command, args []string) {
+	log := getLogger(cmd)
+	name := "from_diff"
+	if len(args) != 0 {
+		name = args[0]
+	} else if !newFromDiff {
+		log.Print("the name of the migration must be given")
+		os.Exit(ExitCodeValidation)
+	}
+	if newFromDiff && newTarget == "" {
+		log.Print("the target schema must be given with --target")
+		os.Exit(ExitCodeValidation)
+	}
+	filename := time.Now().UTC().Format("20060102150405") + "_" + name + ".sql"
+	sequenceFilename := filepath.Join(migrationPath, migration.SequenceFilename)
+	if _, err := os.Stat(sequenceFilename); err != nil && !orderByFilename {
+		log.Printf("error reading %v: %v", sequenceFilename, err)
+		os.Exit(ExitCodeValidation)
+	}
+
+	body := []byte("-- " + name + "\n")
+	if newFromDiff {
+		migrations := migrationFor(cmd, migrationPath, tableName())
+		// the applied files would drown out the statements
+		migrations.SetLog(nil)
+		renames := make(map[string]string, len(newRenames))
+		for _, rename := range newRenames {
+			parts := strings.SplitN(rename, "=", 2)
+			if len(parts) != 2 || !strings.Contains(parts[0], ".") || parts[1] == "" {
+				log.Printf("--rename %v, expected table.old_name=new_name", rename)
+				os.Exit(ExitCodeValidation)
+			}
+			renames[parts[0]] = parts[1]
+		}
+		statements, err := migrationFromDiff(migrations, newTarget, renames)
+		if err != nil {
+			log.Printf("error generating migration: %v", err)
+			os.Exit(ExitCodeValidation)
+		}
+		if statements == "" {
+			log.Printf("the latest version of %v is the same as %v, no migration was created", migrationPath, newTarget)
+			return
+		}
+		command := "migrate new --from-diff --target " + newTarget
+		for _, rename := range newRenames {
+			command += " --rename " + rename
+		}
+		body = []byte(fmt.Sprintf("-- %v: generated by %v\n\n%v\n", name, command, statements))
+	}
+
+	migrationFilename := filepath.Join(migrationPath, filename)
+	if _, err := os.Stat(migrationFilename); err == nil {
+		log.Printf("%v already exists", migrationFilename)
+		os.Exit(ExitCodeOutputPath)
+	}
+	if err := ioutil.WriteFile(migrationFilename, body, 0666); err != nil {
+		log.Printf("error writing %v: %v", migrationFilename, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	if err := appendToSequence(sequenceFilename, filename); err != nil {
+		log.Printf("error writing %v: %v", sequenceFilename, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	log.Printf("created %v", migrationFilename)
+}
+
+// appendToSequence adds the entry to the end of the sequence file, if there is one
+func appendToSequence(sequenceFilename, entry string) error {
+	sequence, err := ioutil.ReadFile(sequenceFilename)
+	if os.IsNotExist(err) {
+		// the files are ordered by their name
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(sequence) != 0 && !bytes.HasSuffix(sequence, []byte("\n")) {
+		sequence = append(sequence, '\n')
+	}
+	return ioutil.WriteFile(sequenceFilename, append(sequence, entry+"\n"...), 0666)
+}
+
+// migrationFromDiff returns the statements that change the latest version of the migrations into the schema of
+// the target, a sql or database file; "" if they are the same. The renames are the renamed columns, the new
+// name by table.old_name.
+func migrationFromDiff(migrations *migration.Manager, target string, renames map[string]string) (string, error) {
+	tmpDir, err := ioutil.TempDir("", "migration-new")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	currentFilename := filepath.Join(tmpDir, "current.db")
+	if err = upgradeScratchDB(migrations, currentFilename); err != nil {
+		return "", fmt.Errorf("building the latest version: %w", err)
+	}
+	targetFilename := filepath.Join(tmpDir, "target.db")
+	if err = loadTarget(target, targetFilename); err != nil {
+		return "", fmt.Errorf("loading %v: %w", target, err)
+	}
+
+	current, err := sqlite.New(currentFilename)
+	if err != nil {
+		return "", err
+	}
+	defer current.Close()
+	targetDB, err := sqlite.New(targetFilename)
+	if err != nil {
+		return "", err
+	}
+	defer targetDB.Close()
+
+	opts := generator.MigrationOptions{Skip: []string{tableName()}, Renames: renames}
+	if opts.DropColumn, err = canDropColumn(migrations, current.DB); err != nil {
+		return "", err
+	}
+	statements, err := generator.MigrationSQL(current, targetDB, opts)
+	if err != nil || statements == "" {
+		return statements, err
+	}
+
+	// check the statements, and that they do change the schema into the target; with foreign keys on, as
+	// the statements may begin their own transaction to turn them off
+	conn, err := current.DB.Conn(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(context.Background(), `PRAGMA foreign_keys = ON;`); err != nil {
+		return "", err
+	}
+	if _, err = conn.ExecContext(context.Background(), statements); err != nil {
+		return "", fmt.Errorf("applying the generated statements: %w\n%v", err, statements)
+	}
+	changes, err := schema.Diff(current, targetDB, tableName())
+	if err != nil {
+		return "", err
+	}
+	// the sql of altered and rebuilt tables is not written as it was, but they define the same table
+	tables := changes.Tables
+	changes.Tables = nil
+	for _, table := range tables {
+		if table.Kind != schema.Changed || len(table.Columns) != 0 || len(table.ForeignKeys) != 0 {
+			changes.Tables = append(changes.Tables, table)
+		}
+	}
+	if !changes.Empty() {
+		return "", fmt.Errorf("the generated statements do not change the schema into the target:\n%v\n%v", changes, statements)
+	}
+	return statements, nil
+}
+
+// loadTarget creates the database at filename with the schema of the target; a copy of it if it is a database
+// file, otherwise the result of running its sql
+func loadTarget(target, filename string) error {
+	body, err := ioutil.ReadFile(target)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(body, []byte("SQLite format 3\x00")) {
+		return copyDatabase(target, filename)
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec(string(body))
+	return err
+}
+
+// canDropColumn returns true if the SQLite of db, and the min_sqlite_version of the migrations, have
+// ALTER TABLE ... DROP COLUMN
+func canDropColumn(migrations *migration.Manager, db *sql.DB) (bool, error) {
+	required, _ := migration.ParseSQLiteVersion(dropColumnVersion)
+	var version string
+	if err := db.QueryRow(`SELECT sqlite_version();`).Scan(&version); err != nil {
+		return false, err
+	}
+	if number, err := migration.ParseSQLiteVersion(version); err != nil || number < required {
+		return false, err
+	}
+	config, err := migrations.Config()
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(config.MinSQLiteVersion) == "" {
+		return true, nil
+	}
+	number, err := migration.ParseSQLiteVersion(config.MinSQLiteVersion)
+	return err == nil && number >= required, err
+}
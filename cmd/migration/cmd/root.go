@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
 	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
 
 	"github.com/spf13/cobra"
 )
@@ -16,6 +18,10 @@ var (
 	dbFilename      string
 	migrationPath   string
 	migrationPrefix string
+	orderByFilename bool
+	environments    []string
+	attachments     []string
+	syncUserVersion bool
 )
 
 var rootCmd = func() *cobra.Command {
@@ -29,6 +35,10 @@ var rootCmd = func() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&dbFilename, "db", "", "database file to use")
 	cmd.PersistentFlags().StringVar(&migrationPath, "path", "sql_files/migrations", "the path to the migrations files.")
 	cmd.PersistentFlags().StringVar(&migrationPrefix, "prefix", "gen", "the table prefix to use for the migrations table")
+	cmd.PersistentFlags().StringSliceVar(&environments, "env", nil, "the active environments, sequence entries tagged with other environments (@dev) are skipped")
+	cmd.PersistentFlags().StringSliceVar(&attachments, "attach", nil, "databases to attach to the database, as schema=filename")
+	cmd.PersistentFlags().BoolVar(&syncUserVersion, "sync-user-version", false, "keep PRAGMA user_version in sync with the version of the database, and report drift")
+	cmd.PersistentFlags().BoolVar(&orderByFilename, "order-by-filename", false, "order sql files by their numeric (0001_) or timestamp (20240101120000_) prefix when there is no sequence.txt")
 
 	return cmd
 }()
@@ -45,6 +55,7 @@ const (
 	ExitCodeDatabase              = 4
 	ExitCodeDatabaseAlreadyExists = 5
 	ExitCodeOutputPath            = 6
+	ExitCodeValidation            = 7
 )
 
 func tableName() string {
@@ -73,5 +84,24 @@ func getLogger(cmd *cobra.Command) *log.Logger {
 func migrationFor(cmd *cobra.Command, path, tablename string) *migration.Manager {
 	migrations := migration.New(path, tablename, nil)
 	migrations.SetLog(getLogger(cmd))
+	if orderByFilename {
+		migrations.SetOrdering(migration.OrderByFilename)
+	}
+	migrations.SetEnvironments(environments...)
+	migrations.SetSyncUserVersion(syncUserVersion)
 	return migrations
 }
+
+// attachDatabases will attach the databases given with --attach to the database
+func attachDatabases(db *sqlite.DB) error {
+	for _, attachment := range attachments {
+		parts := strings.SplitN(attachment, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid attachment `%v`, expected schema=filename", attachment)
+		}
+		if err := db.Attach(parts[0], parts[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gdey/sqlite-migration/internal/textdiff"
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotGolden string
+	snapshotCheck  bool
+
+	schemaSnapshotCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "schema-snapshot",
+			Short: "write, or check, the golden snapshot of the schema at the latest version",
+			Long: `write, or check, the golden snapshot of the schema at the latest version
+
+The migrations are applied to a scratch database, and a normalised dump of its schema is written to
+the golden file (default <path>/schema_snapshot.txt). Commit the golden file, so that every schema
+change shows up in code review.
+
+With --check the golden file is not written; instead the differences are shown, and the exit code is
+non-zero, if the schema does not match it.
+`,
+			Run: runSchemaSnapshotCmd,
+		}
+		cmd.Flags().StringVar(&snapshotGolden, "golden", "", "the golden file (default <path>/schema_snapshot.txt)")
+		cmd.Flags().BoolVar(&snapshotCheck, "check", false, "check the schema against the golden file, instead of writing it")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = schemaSnapshotCmd
+)
+
+func runSchemaSnapshotCmd(cmd *cobra.Command, _ []string) {
+	log := getLogger(cmd)
+	golden := snapshotGolden
+	if golden == "" {
+		golden = filepath.Join(migrationPath, "schema_snapshot.txt")
+	}
+
+	migrations := migrationFor(cmd, migrationPath, tableName())
+	if _, err := migrations.LatestVersion(); err != nil {
+		log.Printf("error reading %v: %v", migrationPath, err)
+		os.Exit(ExitCodeValidation)
+	}
+	tmpDir, err := ioutil.TempDir("", "migration-snapshot")
+	if err != nil {
+		log.Printf("failed to create scratch dir: %v", err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	defer os.RemoveAll(tmpDir)
+	scratchFilename := filepath.Join(tmpDir, "scratch.db")
+	// built with Upgrade, as migrationtest.SchemaSnapshot is, so the repeatable files are applied
+	if err = upgradeScratchDB(migrations, scratchFilename); err != nil {
+		log.Printf("error building scratch database: %v", err)
+		os.Exit(ExitCodeDatabase)
+	}
+	snapshot, err := dumpSchema(scratchFilename, tableName())
+	if err != nil {
+		log.Printf("error dumping schema: %v", err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	if !snapshotCheck {
+		if err = ioutil.WriteFile(golden, []byte(snapshot), 0666); err != nil {
+			log.Printf("error writing %v: %v", golden, err)
+			os.Exit(ExitCodeOutputPath)
+		}
+		log.Printf("wrote schema snapshot to %v", golden)
+		return
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("error reading %v: %v", golden, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	if diff := textdiff.Lines(string(expected), snapshot); diff != "" {
+		fmt.Fprint(cmd.OutOrStdout(), diff)
+		log.Printf("schema does not match %v, run `migrate schema-snapshot` to update it", golden)
+		os.Exit(ExitCodeValidation)
+	}
+	log.Printf("schema matches %v", golden)
+}
+
+// dumpSchema returns the dump of the main schema of the database file, without the tracking table
+func dumpSchema(filename, trackingTable string) (string, error) {
+	db, err := sqlite.New(filename)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	return schema.Dump(db, trackingTable)
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/cmd/migration/cmd/genschema"
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	squashThrough      string
+	squashBaseline     string
+	squashSquashedFile string
+
+	squashCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "squash",
+			Short: "squash the versions up to a version into a single baseline sql file",
+			Long: `squash the versions up to a version into a single baseline sql file
+
+The versions up to and including the "--through" version are applied to a scratch database, which is
+then dumped into the baseline sql file. The sequence.txt is rewritten to start with the baseline, and
+the squashed versions are listed in the squashed sequence file.
+
+New databases will apply the baseline, databases that have applied all the squashed versions will
+continue from where they are. Databases that have only applied some of the squashed versions can no
+longer be upgraded, and need to be upgraded with the sequence from before the squash.
+`,
+			Run: runSquashCmd,
+		}
+		cmd.Flags().StringVar(&squashThrough, "through", "", "the last version to squash into the baseline")
+		cmd.Flags().StringVar(&squashBaseline, "baseline", "", "the name of the baseline sql file (default baseline_<date>.sql)")
+		cmd.Flags().StringVar(&squashSquashedFile, "squashed", "", "the name of the sequence file listing the squashed versions (default <baseline>_squashed.txt)")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = squashCmd
+)
+
+func runSquashCmd(cmd *cobra.Command, _ []string) {
+	log := getLogger(cmd)
+	if squashThrough == "" {
+		log.Print("the version to squash through must be given with --through")
+		os.Exit(ExitCodeValidation)
+	}
+	baseline := squashBaseline
+	if baseline == "" {
+		baseline = "baseline_" + time.Now().Format("20060102") + ".sql"
+	}
+	squashed := squashSquashedFile
+	if squashed == "" {
+		squashed = strings.TrimSuffix(baseline, filepath.Ext(baseline)) + "_squashed.txt"
+	}
+
+	migrations := migrationFor(cmd, migrationPath, tableName())
+	sequence, squashedSequence, err := migrations.SquashSequence(squashThrough, baseline, squashed)
+	if err != nil {
+		log.Printf("error squashing %v: %v", migrationPath, err)
+		os.Exit(ExitCodeValidation)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "migration-squash")
+	if err != nil {
+		log.Printf("failed to create scratch dir: %v", err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	defer os.RemoveAll(tmpDir)
+	scratchFilename := filepath.Join(tmpDir, "scratch.db")
+
+	if err = buildScratchDB(migrations, scratchFilename, squashThrough); err != nil {
+		log.Printf("error building scratch database: %v", err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	baselineFilename := filepath.Join(migrationPath, baseline)
+	if err = writeBaseline(scratchFilename, baselineFilename, tableName()); err != nil {
+		log.Printf("error writing baseline %v: %v", baselineFilename, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	squashedFilename := filepath.Join(migrationPath, squashed)
+	if err = ioutil.WriteFile(squashedFilename, squashedSequence, 0666); err != nil {
+		log.Printf("error writing %v: %v", squashedFilename, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	sequenceFilename := filepath.Join(migrationPath, migration.SequenceFilename)
+	if err = ioutil.WriteFile(sequenceFilename, sequence, 0666); err != nil {
+		log.Printf("error writing %v: %v", sequenceFilename, err)
+		os.Exit(ExitCodeOutputPath)
+	}
+	log.Printf("squashed versions through `%v` into %v", squashThrough, baselineFilename)
+}
+
+// buildScratchDB will create a new database, at filename, upgraded to the through version
+func buildScratchDB(migrations *migration.Manager, filename, through string) error {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, _, err = migrations.UpgradeTo(db, author, through)
+	return err
+}
+
+// writeBaseline will dump the schema and data of the database into the baseline file. Tables are
+// written first, ordered so that tables are created before the tables that reference them, followed by
+// their data; then indexes, views and triggers; so that triggers do not fire while the data is inserted.
+func writeBaseline(dbFilename, baselineFilename, trackingTable string) error {
+	db, err := sqlite.New(dbFilename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	file, err := os.Create(baselineFilename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, _ = file.WriteString(genschema.FileHeader)
+
+	isInternal := func(name string) bool {
+		return name == trackingTable || strings.HasPrefix(name, "sqlite_")
+	}
+
+	tables, err := db.Tables()
+	if err != nil {
+		return err
+	}
+	tableMap := make(map[string]schema.Table, len(tables))
+	names := make([]string, 0, len(tables))
+	for _, tbl := range tables {
+		if isInternal(tbl.Name()) {
+			continue
+		}
+		tableMap[tbl.Name()] = tbl
+		names = append(names, tbl.Name())
+	}
+	sort.Strings(names)
+
+	written := make(map[string]bool, len(names))
+	var writeTable func(name string) error
+	writeTable = func(name string) error {
+		tbl, ok := tableMap[name]
+		if !ok || written[name] {
+			return nil
+		}
+		written[name] = true
+		keys, _ := tbl.ForeignKeys()
+		for _, key := range keys {
+			if err := writeTable(key.ToTable()); err != nil {
+				return err
+			}
+		}
+		if err := genschema.WriteTableSQL(file, tbl.SQL()); err != nil {
+			return err
+		}
+		if _, err := file.Write(generator.InsertSQL(db.DB, name)); err != nil {
+			return fmt.Errorf("failed to write insert sql for %v: %w", name, err)
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err = writeTable(name); err != nil {
+			return err
+		}
+	}
+
+	var objects []schema.NamedSQLer
+	indexes, err := db.Indexes()
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if sqler, ok := index.(schema.NamedSQLer); ok && !isInternal(index.Table()) {
+			objects = append(objects, sqler)
+		}
+	}
+	views, err := db.Views()
+	if err != nil {
+		return err
+	}
+	for _, view := range views {
+		objects = append(objects, view)
+	}
+	triggers, err := db.Triggers()
+	if err != nil {
+		return err
+	}
+	for _, trigger := range triggers {
+		if !isInternal(trigger.Table()) {
+			objects = append(objects, trigger)
+		}
+	}
+	for _, obj := range objects {
+		// indexes created for UNIQUE and PRIMARY KEY constraints have no sql
+		if err = genschema.WriteTableSQL(file, obj.SQL()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "status",
+			Short: "status shows the state of each migration file for the given database.",
+			Long: `status shows the state of each migration file for the given database
+
+Every entry in sequence.txt, every repeatable and background file, and every file in the
+tracking table is listed with one of the states:
+  applied      the file has been applied, and has not changed since
+  pending      the file has not been applied
+  modified     the file has been applied, but has changed since
+  missing      the file is not on disk
+  baselined    the file was applied, and has since been squashed into a baseline
+  skipped      the file was skipped, as it was not for the active environments
+  in-progress  the background file has been started, but not completed
+`,
+			Run: runStatusCmd,
+		}
+		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema (main, or an --attach'ed database) to report on")
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = statusCmd
+)
+
+func runStatusCmd(cmd *cobra.Command, _ []string) {
+
+	migrations := migrationFor(cmd, migrationPath, tableName())
+	migrations.SetSchema(databaseSchema)
+	log := getLogger(cmd)
+
+	if dbFilename == "" {
+		log.Print("database file must be given")
+		os.Exit(ExitCodeDatabase)
+	}
+	db, err := sqlite.New(dbFilename)
+	if err != nil {
+		log.Printf("error opening db %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	defer db.Close()
+	if err = attachDatabases(db); err != nil {
+		log.Printf("error attaching databases to %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+
+	statuses, err := migrations.Status(db.DB)
+	if err != nil {
+		log.Printf("error getting status of db %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tKIND\tSTATE\tAPPLIED AT\tAUTHOR")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", status.Version, status.Kind, status.State, status.AppliedAt, status.Author)
+	}
+	_ = w.Flush()
+}
@@ -4,18 +4,41 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
 
 	"github.com/spf13/cobra"
 )
 
 var (
+	allowOutOfOrder    bool
+	parallel           int
+	maintenance        []string
+	transactionPerFile bool
+
 	upgradeCmd = func() *cobra.Command {
 		cmd := &cobra.Command{
 			Use:   "upgrade",
 			Short: "upgrade the given database using the migration files.",
-			Long:  fmt.Sprintf(`upgrade the given database using the migration files`),
-			Run:   runUpgradeCmd,
+			Long: `upgrade the given database using the migration files
+
+If --db is a glob pattern (e.g. 'data/*.db') all the matching database files are upgraded,
+up to --parallel at a time. A file that fails to upgrade does not stop the others, a summary
+of each file is printed at the end, and the exit code is non-zero if any failed.
+`,
+			Run: runUpgradeCmd,
 		}
+		cmd.Flags().StringVar(&databaseSchema, "database-schema", generator.DefaultSchema, "The database schema (main, or an --attach'ed database) to upgrade")
+		cmd.Flags().BoolVar(&allowOutOfOrder, "allow-out-of-order", false, "apply sequence entries inserted before the current version of the database")
+		cmd.Flags().StringSliceVar(&maintenance, "maintenance", nil, "maintenance steps to run after a successful upgrade: optimize, analyze, vacuum, incremental_vacuum, checkpoint")
+		cmd.Flags().BoolVar(&transactionPerFile, "transaction-per-file", false, "apply each sql file in its own transaction; the files must not begin or commit transactions themselves")
+		cmd.Flags().IntVar(&parallel, "parallel", 1, "the number of database files to upgrade at the same time, when --db is a glob pattern")
 		rootCmd.AddCommand(cmd)
 		return cmd
 	}()
@@ -26,26 +49,51 @@ var (
 func runUpgradeCmd(cmd *cobra.Command, _ []string) {
 
 	migrations := migrationFor(cmd, migrationPath, tableName())
+	migrations.SetAllowOutOfOrder(allowOutOfOrder)
+	migrations.SetTransactionPerFile(transactionPerFile)
+	migrations.SetSchema(databaseSchema)
 	log := getLogger(cmd)
+	steps := make([]migration.MaintenanceStep, 0, len(maintenance))
+	for _, name := range maintenance {
+		step, err := migration.ParseMaintenanceStep(name)
+		if err != nil {
+			log.Print(err)
+			os.Exit(ExitCodeValidation)
+		}
+		steps = append(steps, step)
+	}
+	migrations.SetMaintenance(steps...)
 
 	// check to see if the db file exists.
 	if dbFilename == "" {
 		log.Print("database file must be given")
 		os.Exit(ExitCodeDatabase)
 	}
+	if isGlobPattern(dbFilename) {
+		runUpgradeAll(cmd, migrations)
+		return
+	}
 
-	db, err := sql.Open("sqlite3", dbFilename)
+	sdb, err := sqlite.New(dbFilename)
 	if err != nil {
 		log.Printf("error opening db %v: %v", dbFilename, err)
 		os.Exit(ExitCodeDatabase)
 	}
+	defer sdb.Close()
+	if err = attachDatabases(sdb); err != nil {
+		log.Printf("error attaching databases to %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	db := sdb.DB
 	// Now need to setup migrations
 	startingVersion, newVersion, err := migrations.Upgrade(db, author)
 	if err != nil {
 		log.Printf("error upgrading db %v: %v", dbFilename, err)
+		reportSkipped(cmd, migrations, db)
 		os.Exit(ExitCodeDatabase)
 
 	}
+	reportSkipped(cmd, migrations, db)
 	if startingVersion == newVersion {
 		log.Printf("database file %v already at latest version `%v`", dbFilename, startingVersion)
 		return
@@ -58,3 +106,70 @@ func runUpgradeCmd(cmd *cobra.Command, _ []string) {
 	log.Printf("database file (%v) upgraded from %v to `%v`", dbFilename, startingVersion, newVersion)
 	return
 }
+
+// reportSkipped will log the versions that were skipped because they were for other environments
+func reportSkipped(cmd *cobra.Command, migrations *migration.Manager, db *sql.DB) {
+	log := getLogger(cmd)
+	skipped, err := migrations.Skipped(db)
+	if err != nil {
+		log.Printf("error getting skipped versions for db %v: %v", dbFilename, err)
+		return
+	}
+	for _, version := range skipped {
+		if version.Applies {
+			log.Printf("`%v` was skipped for environments %v, and applies to %v; use --allow-out-of-order to apply it",
+				version.Version, version.Environments, migrations.Environments(),
+			)
+			continue
+		}
+		log.Printf("`%v` was skipped for environments %v", version.Version, version.Environments)
+	}
+}
+
+// isGlobPattern reports whether the name contains any of the glob meta characters
+func isGlobPattern(name string) bool { return strings.ContainsAny(name, `*?[`) }
+
+// runUpgradeAll will upgrade all the database files matching the --db pattern
+func runUpgradeAll(cmd *cobra.Command, migrations *migration.Manager) {
+	log := getLogger(cmd)
+	paths, err := filepath.Glob(dbFilename)
+	if err != nil {
+		log.Printf("error matching database files %v: %v", dbFilename, err)
+		os.Exit(ExitCodeDatabase)
+	}
+	if len(paths) == 0 {
+		log.Printf("no database files match %v", dbFilename)
+		os.Exit(ExitCodeDatabase)
+	}
+	results, err := migration.UpgradeAll(cmd.Context(), paths, migration.UpgradeAllOptions{
+		Manager:  migrations,
+		Author:   author,
+		Parallel: parallel,
+		Open: func(path string) (*sql.DB, error) {
+			db, err := sqlite.New(path)
+			if err != nil {
+				return nil, err
+			}
+			if err = attachDatabases(db); err != nil {
+				db.Close()
+				return nil, err
+			}
+			return db.DB, nil
+		},
+	})
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATABASE\tFROM\tTO\tDURATION\tERROR")
+	for _, result := range results {
+		errMsg := ""
+		if result.Err != nil {
+			errMsg = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", result.Path, result.From, result.To, result.Duration.Round(time.Millisecond), errMsg)
+	}
+	_ = w.Flush()
+	if err != nil {
+		log.Print(err)
+		os.Exit(ExitCodeDatabase)
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	migration "github.com/gdey/sqlite-migration"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateCmd = func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "validate",
+			Short: "validate the migration files without a database.",
+			Long: `validate the migration files without a database
+
+Checks that every entry in sequence.txt exists and is only listed once, that no sql files
+are left unreferenced, that all templates parse and all referenced partials exist, and that
+all directives are well formed.
+`,
+			Run: runValidateCmd,
+		}
+		rootCmd.AddCommand(cmd)
+		return cmd
+	}()
+
+	_ = validateCmd
+)
+
+func runValidateCmd(cmd *cobra.Command, _ []string) {
+
+	migrations := migrationFor(cmd, migrationPath, tableName())
+	log := getLogger(cmd)
+
+	err := migrations.Validate()
+	if err == nil {
+		log.Printf("migration files in %v are valid", migrationPath)
+		return
+	}
+	var problems migration.ErrValidation
+	if !errors.As(err, &problems) {
+		log.Printf("error validating %v: %v", migrationPath, err)
+		os.Exit(ExitCodeValidation)
+	}
+	for _, problem := range problems {
+		log.Print(problem)
+	}
+	log.Printf("found %d problem(s) in %v", len(problems), migrationPath)
+	os.Exit(ExitCodeValidation)
+}
@@ -0,0 +1,144 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ConfigFilename is the name of the optional file, in a migration directory, with settings for the
+	// migration directory. Each line is a setting and its value, lines that begin with # are comments.
+	//   application_id 0x4d594150
+	ConfigFilename = "config.txt"
+
+	// ConfigApplicationID is the setting for the PRAGMA application_id of the databases of the migration
+	// directory. It is a 32-bit number, in decimal or hex (0x).
+	ConfigApplicationID = "application_id"
+
+	// ConfigMinSQLiteVersion is the setting for the oldest version of SQLite the databases are used with;
+	// the sqlite-version lint rule reports features that are newer than it.
+	//   min_sqlite_version 3.31.0
+	ConfigMinSQLiteVersion = "min_sqlite_version"
+
+	// ConfigLint is the setting for the severity of a lint rule; error, warning or off.
+	//   lint create-index error
+	ConfigLint = "lint"
+)
+
+// Config are the settings of a migration directory
+type Config struct {
+	// ApplicationID is the PRAGMA application_id of the databases, 0 if it is not set
+	ApplicationID int32
+	// MinSQLiteVersion is the oldest version of SQLite the databases are used with, "" if it is not set
+	MinSQLiteVersion string
+	// LintSeverities are the severities of the lint rules that have been configured
+	LintSeverities map[LintRule]LintSeverity
+}
+
+// Config returns the settings from the config file of the migration directory. A migration directory
+// without a config file has the zero Config.
+func (mng *Manager) Config() (Config, error) {
+	var config Config
+	filename := filepath.Join(mng.dir, ConfigFilename)
+	if !mng.exists(filename) {
+		return config, nil
+	}
+	body, err := mng.readAllFile(filename)
+	if err != nil {
+		return config, err
+	}
+	for _, line := range readSequenceLines(bytes.NewReader(body)) {
+		fields := strings.Fields(line.Text)
+		invalid := func(reason string) error {
+			return ErrInvalidConfigEntry{Filename: filename, LineNo: line.LineNo, Line: line.Text, Reason: reason}
+		}
+		switch {
+		case fields[0] == ConfigLint && len(fields) != 3:
+			return config, invalid("expected a lint rule and its severity")
+		case fields[0] != ConfigLint && len(fields) != 2:
+			return config, invalid("expected a setting and its value")
+		}
+		switch fields[0] {
+		case ConfigApplicationID:
+			id, err := strconv.ParseInt(fields[1], 0, 64)
+			if err != nil || id < math.MinInt32 || id > math.MaxUint32 {
+				return config, invalid("application_id is not a 32-bit number")
+			}
+			// values above MaxInt32 are allowed, so that hex ids can use all 32 bits
+			config.ApplicationID = int32(uint32(id))
+		case ConfigMinSQLiteVersion:
+			if _, err := ParseSQLiteVersion(fields[1]); err != nil {
+				return config, invalid(err.Error())
+			}
+			config.MinSQLiteVersion = fields[1]
+		case ConfigLint:
+			rule, err := ParseLintRule(fields[1])
+			if err != nil {
+				return config, invalid(err.Error())
+			}
+			severity, err := ParseLintSeverity(fields[2])
+			if err != nil {
+				return config, invalid(err.Error())
+			}
+			if config.LintSeverities == nil {
+				config.LintSeverities = make(map[LintRule]LintSeverity)
+			}
+			config.LintSeverities[rule] = severity
+		default:
+			return config, invalid(fmt.Sprintf("unknown setting `%v`", fields[0]))
+		}
+	}
+	return config, nil
+}
+
+// ApplicationID returns the PRAGMA application_id of the db
+func (mng *Manager) ApplicationID(db Executor) (id int32, err error) {
+	err = db.QueryRowContext(context.Background(), fmt.Sprintf(`PRAGMA %v.application_id;`, quoteIdentifier(mng.Schema()))).
+		Scan(&id)
+	return id, err
+}
+
+// verifyApplicationID will check that the db belongs to the migration directory, if the config sets an
+// application id; every operation on a db calls it before touching the db. A db without an application id
+// is accepted if the db is empty or has already been migrated with the migration directory, and is stamped
+// with it if stamp is true (the operation writes to the db); otherwise it is not touched, and ErrApplicationID
+// is returned.
+func (mng *Manager) verifyApplicationID(db Executor, stamp bool) error {
+	const (
+		CountObjectsSQL = `SELECT COUNT(*) FROM %v.sqlite_master;`
+	)
+	config, err := mng.Config()
+	if err != nil || config.ApplicationID == 0 {
+		return err
+	}
+	id, err := mng.ApplicationID(db)
+	if err != nil {
+		return err
+	}
+	if id == config.ApplicationID {
+		return nil
+	}
+	if id == 0 {
+		var count int
+		err = db.QueryRowContext(context.Background(), fmt.Sprintf(CountObjectsSQL, quoteIdentifier(mng.Schema()))).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count == 0 || mng.HasTrackingTable(db) {
+			if !stamp {
+				return nil
+			}
+			// pragmas do not take parameters
+			_, err = db.ExecContext(context.Background(),
+				fmt.Sprintf(`PRAGMA %v.application_id = %d;`, quoteIdentifier(mng.Schema()), config.ApplicationID),
+			)
+			return err
+		}
+	}
+	return ErrApplicationID{Expected: config.ApplicationID, ApplicationID: id}
+}
@@ -0,0 +1,92 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_ApplicationID(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/config.txt":   {Data: []byte("# our application\napplication_id 0x4d594150\n")},
+		"migrations/sequence.txt": {Data: []byte("a.sql\n")},
+		"migrations/a.sql":        {Data: []byte("CREATE TABLE a ( name TEXT );")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+	config, err := migrations.Config()
+	if err != nil {
+		t.Fatalf("config error, expected nil got %v", err)
+	}
+	if config.ApplicationID != 0x4d594150 {
+		t.Errorf("config application id, expected 0x4d594150 got %#x", config.ApplicationID)
+	}
+
+	openDB := func(setup string) *sql.DB {
+		t.Helper()
+		dbFilename, cleanup := NewTestDBFilename(t, nil)
+		t.Cleanup(cleanup)
+		db, err := sql.Open("sqlite3", dbFilename)
+		if err != nil {
+			t.Fatalf("open error, expected nil got %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if setup != "" {
+			if _, err = db.Exec(setup); err != nil {
+				t.Fatalf("setup error, expected nil got %v", err)
+			}
+		}
+		return db
+	}
+
+	// a new database is stamped
+	db := openDB("")
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Fatalf("upgrade error, expected nil got %v", err)
+	}
+	if id, err := migrations.ApplicationID(db); err != nil || id != 0x4d594150 {
+		t.Errorf("application id, expected 0x4d594150 got %#x, %v", id, err)
+	}
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Errorf("second upgrade error, expected nil got %v", err)
+	}
+
+	for name, setup := range map[string]string{
+		"other application": "PRAGMA application_id = 42;",
+		"no application id": "CREATE TABLE other ( name TEXT );",
+	} {
+		db := openDB(setup)
+		_, _, err := migrations.Upgrade(db, "test")
+		if !errors.As(err, new(ErrApplicationID)) {
+			t.Errorf("%v upgrade error, expected ErrApplicationID got %v", name, err)
+		}
+		if migrations.HasTrackingTable(db) {
+			t.Errorf("%v, expected the database not to be touched", name)
+		}
+		// every other operation on the database is refused as well
+		for operation, fn := range map[string]func() error{
+			"db version":          func() error { _, err := migrations.DBVersion(db); return err },
+			"history":             func() error { _, err := migrations.History(db); return err },
+			"skipped":             func() error { _, err := migrations.Skipped(db); return err },
+			"status":              func() error { _, err := migrations.Status(db); return err },
+			"background progress": func() error { _, err := migrations.BackgroundProgress(db); return err },
+			"repeatable hash":     func() error { _, err := migrations.RepeatableHash(db, "a.sql"); return err },
+			"lint":                func() error { _, err := migrations.Lint(db, LintOptions{}); return err },
+		} {
+			if err := fn(); !errors.As(err, new(ErrApplicationID)) {
+				t.Errorf("%v %v error, expected ErrApplicationID got %v", name, operation, err)
+			}
+		}
+		if id, err := migrations.ApplicationID(db); err != nil || id == 0x4d594150 {
+			t.Errorf("%v application id, expected not to be stamped got %#x, %v", name, id, err)
+		}
+	}
+
+	fsys["migrations/config.txt"] = &fstest.MapFile{Data: []byte("application_id lots\n")}
+	if _, err = migrations.Config(); !errors.As(err, new(ErrInvalidConfigEntry)) {
+		t.Errorf("config error, expected ErrInvalidConfigEntry got %v", err)
+	}
+	if err = migrations.Validate(); !errors.As(err, new(ErrValidation)) {
+		t.Errorf("validate error, expected ErrValidation got %v", err)
+	}
+}
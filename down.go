@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DownInfix is added before the .sql extension of a version, to get the name of the file that reverts it.
+	//   0007_add_email.sql      -> 0007_add_email.down.sql
+	//   0007_add_email.sql.tpl  -> 0007_add_email.down.sql.tpl
+	// Down files are not applied by the Manager, they are used by the migrationtest package to check that
+	// a version can be reverted.
+	DownInfix = ".down"
+)
+
+// isDownFile returns true if the name is that of a down file
+func isDownFile(name string) bool {
+	return strings.HasSuffix(name, DownInfix+".sql") || strings.HasSuffix(name, DownInfix+".sql.tpl")
+}
+
+// downFilename returns the name of the down file for the version
+func downFilename(version string) string {
+	for _, ext := range []string{".sql.tpl", ".sql"} {
+		if strings.HasSuffix(version, ext) {
+			return strings.TrimSuffix(version, ext) + DownInfix + ext
+		}
+	}
+	return version + DownInfix
+}
+
+// DownFile returns the name, relative to the migration directory, of the down file for the version;
+// ok is false if the version does not have one.
+func (mng *Manager) DownFile(version string) (name string, ok bool) {
+	if version == InitialVersion || isDownFile(version) {
+		return "", false
+	}
+	name = downFilename(version)
+	return name, mng.exists(filepath.Join(mng.dir, name))
+}
+
+// RenderFile returns the sql of the file, relative to the migration directory, rendering it if it is a template
+func (mng *Manager) RenderFile(name string) ([]byte, error) {
+	body, _, err := mng.renderSQLFile(filepath.Join(mng.dir, name))
+	return body, err
+}
@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// TrackingKindSkipped is the kind of tracking entry recorded for a version that was not applied because
+	// it was not for the active environments.
+	TrackingKindSkipped = "skipped"
+)
+
+// Environments returns the active environments. Entries in the sequence that are tagged with environments
+// (e.g. `seed_users.sql @dev @test`) are only applied if one of their environments is active; untagged
+// entries are always applied.
+func (mng *Manager) Environments() []string {
+	if mng == nil {
+		return nil
+	}
+	return mng.environments
+}
+
+// SetEnvironments sets the active environments.
+func (mng *Manager) SetEnvironments(environments ...string) {
+	if mng == nil {
+		return
+	}
+	mng.environments = nil
+	for _, env := range environments {
+		env = strings.TrimPrefix(strings.TrimSpace(env), "@")
+		if env == "" {
+			continue
+		}
+		mng.environments = append(mng.environments, env)
+	}
+}
+
+// appliesTo returns true if an entry tagged with the given environments should be applied
+func (mng *Manager) appliesTo(environments []string) bool {
+	if len(environments) == 0 {
+		return true
+	}
+	for _, env := range environments {
+		for _, active := range mng.Environments() {
+			if env == active {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SkippedVersion is a version that was not applied to a database, because it was not for the environments
+// that were active at the time.
+type SkippedVersion struct {
+	Version string
+	// Environments that were active when the version was skipped
+	Environments []string
+	CreatedAt    string
+	// Applies is true if the version is for the currently active environments
+	Applies bool
+}
+
+// Skipped returns the versions that were skipped, and have not since been applied, for the given db.
+func (mng *Manager) Skipped(db Executor) ([]SkippedVersion, error) {
+	const (
+		SelectSkippedSQL = `
+	SELECT file_path, environments, created_at
+	FROM %[1]s AS skipped
+	WHERE kind = ?
+	AND NOT EXISTS (
+		SELECT 1 FROM %[1]s AS applied
+		WHERE applied.kind = ? AND applied.file_path = skipped.file_path
+	)
+	ORDER by ROWID asc;
+	`
+	)
+	if err := mng.verifyApplicationID(db, false); err != nil {
+		return nil, err
+	}
+	entries, problems := mng.versionEntries()
+	if len(problems) != 0 {
+		return nil, problems[0]
+	}
+	environments := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		environments[entry.Name] = entry.Environments
+	}
+
+	source, err := mng.trackingSource(db)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(context.Background(), fmt.Sprintf(SelectSkippedSQL, source), TrackingKindSkipped, TrackingKindVersion)
+	if err != nil {
+		return nil, ErrTrackingRead{Err: err, TableName: mng.TableName()}
+	}
+	defer rows.Close()
+	var skipped []SkippedVersion
+	for rows.Next() {
+		var (
+			version SkippedVersion
+			envs    string
+		)
+		if err = rows.Scan(&version.Version, &envs, &version.CreatedAt); err != nil {
+			return nil, err
+		}
+		if envs != "" {
+			version.Environments = strings.Split(envs, ",")
+		}
+		if entryEnvs, ok := environments[version.Version]; ok {
+			version.Applies = mng.appliesTo(entryEnvs)
+		}
+		skipped = append(skipped, version)
+	}
+	return skipped, rows.Err()
+}
@@ -1,6 +1,9 @@
 package migration
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type ErrCreateTable struct {
 	Err       error
@@ -22,6 +25,17 @@ func (err ErrTrackingInfo) Error() string {
 	return fmt.Sprintf("failed inserting tracking info %v : %v", err.TableName, err.Err)
 }
 
+// ErrTrackingRead is returned when the entries of the tracking table can not be read
+type ErrTrackingRead struct {
+	Err       error
+	TableName string
+}
+
+func (err ErrTrackingRead) Unwrap() error { return err.Err }
+func (err ErrTrackingRead) Error() string {
+	return fmt.Sprintf("failed reading tracking info %v : %v", err.TableName, err.Err)
+}
+
 type ErrApplyFileRead struct {
 	Filename string
 	Err      error
@@ -58,3 +72,206 @@ type ErrUnknownVersion string
 func (err ErrUnknownVersion) Error() string {
 	return fmt.Sprintf("unknown db version: `%v`", string(err))
 }
+
+type ErrDuplicateVersion string
+
+func (err ErrDuplicateVersion) Error() string {
+	return fmt.Sprintf("duplicate version: `%v`", string(err))
+}
+
+// ErrSequenceCycle is returned when sequence files include each other; it is the chain of
+// sequence files that make up the cycle.
+type ErrSequenceCycle []string
+
+func (err ErrSequenceCycle) Error() string {
+	return fmt.Sprintf("sequence include cycle: %v", strings.Join(err, " -> "))
+}
+
+type ErrInvalidSequenceEntry struct {
+	Filename string
+	LineNo   int
+	Line     string
+	Reason   string
+}
+
+func (err ErrInvalidSequenceEntry) Error() string {
+	return fmt.Sprintf("invalid entry in %v:%d `%v`: %v", err.Filename, err.LineNo, err.Line, err.Reason)
+}
+
+// ErrInvalidConfigEntry is returned for a line of the config file that is not a valid setting
+type ErrInvalidConfigEntry struct {
+	Filename string
+	LineNo   int
+	Line     string
+	Reason   string
+}
+
+func (err ErrInvalidConfigEntry) Error() string {
+	return fmt.Sprintf("invalid setting in %v:%d `%v`: %v", err.Filename, err.LineNo, err.Line, err.Reason)
+}
+
+type ErrMissingFile struct {
+	Filename string
+	// Source is the sequence file that referenced the file
+	Source string
+	LineNo int
+}
+
+func (err ErrMissingFile) Error() string {
+	return fmt.Sprintf("file %v referenced in %v:%d does not exist", err.Filename, err.Source, err.LineNo)
+}
+
+type ErrUnreferencedFile string
+
+func (err ErrUnreferencedFile) Error() string {
+	return fmt.Sprintf("file %v is not referenced by any sequence file", string(err))
+}
+
+type ErrMissingPartial struct {
+	Filename string
+	Partial  string
+}
+
+func (err ErrMissingPartial) Error() string {
+	return fmt.Sprintf("partial `%v` used in %v does not exist", err.Partial, err.Filename)
+}
+
+// ErrValidation is returned by Manager.Validate, and contains all the problems that were found
+type ErrValidation []error
+
+func (err ErrValidation) Error() string {
+	var str strings.Builder
+	fmt.Fprintf(&str, "%d problem(s) found:", len(err))
+	for _, e := range err {
+		str.WriteString("\n\t")
+		str.WriteString(e.Error())
+	}
+	return str.String()
+}
+
+// ErrDivergence is returned when the versions applied to a database are not the start of the sequence.
+type ErrDivergence struct {
+	// Version is the last version applied to the database
+	Version string
+	// Inserted are the versions in the sequence before the current version that have not been applied
+	Inserted []string
+	// Removed are the applied versions that are no longer in the sequence
+	Removed []string
+	// Reordered are the applied versions whose order in the sequence has changed
+	Reordered []string
+	// Skipped are the versions that were skipped, as they were not for the active environments at the time,
+	// but are for the currently active environments
+	Skipped []string
+}
+
+// Unwrap will return an ErrUnknownVersion if the last applied version is no longer in the sequence
+func (err ErrDivergence) Unwrap() error {
+	for _, version := range err.Removed {
+		if version == err.Version {
+			return ErrUnknownVersion(err.Version)
+		}
+	}
+	return nil
+}
+func (err ErrDivergence) Error() string {
+	var str strings.Builder
+	str.WriteString("applied versions diverge from the sequence")
+	for _, part := range []struct {
+		name     string
+		versions []string
+	}{
+		{"inserted", err.Inserted},
+		{"removed", err.Removed},
+		{"reordered", err.Reordered},
+		{"skipped for other environments", err.Skipped},
+	} {
+		if len(part.versions) == 0 {
+			continue
+		}
+		fmt.Fprintf(&str, "; %v: %v", part.name, strings.Join(part.versions, ", "))
+	}
+	return str.String()
+}
+
+// ErrFilenameConvention is returned when ordering by filename, and some of the sql files do not
+// start with a numeric or timestamp prefix.
+type ErrFilenameConvention struct {
+	Dir       string
+	Filenames []string
+}
+
+func (err ErrFilenameConvention) Error() string {
+	return fmt.Sprintf("files in %v do not start with a numeric (0001_) or timestamp (20240101120000_) prefix: %v",
+		err.Dir, strings.Join(err.Filenames, ", "),
+	)
+}
+
+type ErrDuplicatePrefix struct {
+	Dir       string
+	Filenames []string
+}
+
+func (err ErrDuplicatePrefix) Error() string {
+	return fmt.Sprintf("files in %v share the same prefix: %v", err.Dir, strings.Join(err.Filenames, ", "))
+}
+
+type ErrPrefixGap struct {
+	Dir   string
+	After string
+	Next  string
+}
+
+func (err ErrPrefixGap) Error() string {
+	return fmt.Sprintf("gap in the numeric prefixes of files in %v: %v is followed by %v", err.Dir, err.After, err.Next)
+}
+
+// ErrBeforeBaseline is returned when a database has applied some, but not all, of the versions that
+// were squashed into a baseline. Such a database needs to be upgraded with a sequence from before the squash.
+type ErrBeforeBaseline struct {
+	Baseline string
+	// Missing are the squashed versions that have not been applied
+	Missing []string
+}
+
+func (err ErrBeforeBaseline) Error() string {
+	return fmt.Sprintf("database is before baseline %v, missing squashed versions: %v",
+		err.Baseline, strings.Join(err.Missing, ", "),
+	)
+}
+
+// ErrUpgradeAll is returned by UpgradeAll when some of the database files failed to upgrade; the error
+// of each is in its UpgradeResult.
+type ErrUpgradeAll struct {
+	Failed int
+	Total  int
+}
+
+func (err ErrUpgradeAll) Error() string {
+	return fmt.Sprintf("%d of %d database files failed to upgrade", err.Failed, err.Total)
+}
+
+// ErrUserVersionDrift is returned when the PRAGMA user_version of a database does not match the version
+// recorded in its tracking table; the database was changed by something other than the migrations.
+type ErrUserVersionDrift struct {
+	Version     string
+	Expected    int
+	UserVersion int
+}
+
+func (err ErrUserVersionDrift) Error() string {
+	return fmt.Sprintf("user_version drift: user_version is %d, but version %v is at %d", err.UserVersion, err.Version, err.Expected)
+}
+
+// ErrApplicationID is returned when the PRAGMA application_id of a database is not the one set in the
+// config file of the migration directory; the database belongs to another application.
+type ErrApplicationID struct {
+	Expected      int32
+	ApplicationID int32
+}
+
+func (err ErrApplicationID) Error() string {
+	if err.ApplicationID == 0 {
+		return fmt.Sprintf("database does not have an application_id, expected %#x, and is not empty", uint32(err.Expected))
+	}
+	return fmt.Sprintf("database application_id is %#x, expected %#x", uint32(err.ApplicationID), uint32(err.Expected))
+}
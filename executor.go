@@ -0,0 +1,83 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Executor is the part of *sql.DB, *sql.Conn and *sql.Tx that the Manager uses to run sql.
+//
+// The executor determines which features are available while upgrading:
+//   - *sql.DB and *sql.Conn: each sql file is run as is, and its tracking entry recorded after it.
+//     With SetTransactionPerFile(true) each sql file is applied, along with its tracking entry, in its
+//     own transaction; so a file that fails is rolled back without leaving a partial change behind.
+//     SQLite's write lock is only held while a file is applied, so two processes upgrading the
+//     same database at the same time are not kept from applying the same file twice.
+//   - *sql.Conn: PRAGMAs, registered functions and attached databases set on the connection are
+//     available to the sql files.
+//   - *sql.Tx: everything is applied in the given transaction, nothing is committed until the caller
+//     commits, and the caller decides what to do if the upgrade fails. A transaction that has written
+//     to the database holds SQLite's write lock until it ends; begin it with `_txlock=immediate` in the
+//     dsn to hold the lock for the whole upgrade, keeping out other processes.
+//
+// Sql files applied in a transaction should not begin or commit transactions themselves, and
+// transaction sensitive PRAGMAs (e.g. foreign_keys) do nothing in them.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txBeginner is implemented by the executors that can start a transaction; *sql.DB and *sql.Conn
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+var (
+	_ Executor   = (*sql.DB)(nil)
+	_ Executor   = (*sql.Conn)(nil)
+	_ Executor   = (*sql.Tx)(nil)
+	_ txBeginner = (*sql.DB)(nil)
+	_ txBeginner = (*sql.Conn)(nil)
+)
+
+// TransactionPerFile reports whether each sql file is applied in its own transaction, when the
+// executor can begin transactions.
+func (mng *Manager) TransactionPerFile() bool { return mng != nil && mng.transactionPerFile }
+
+// SetTransactionPerFile sets whether each sql file is applied in its own transaction, when the
+// executor can begin transactions. The default is false; only turn it on if none of the sql files
+// manage their own transactions or set transaction sensitive PRAGMAs.
+func (mng *Manager) SetTransactionPerFile(perFile bool) {
+	if mng == nil {
+		return
+	}
+	mng.transactionPerFile = perFile
+}
+
+// inTransaction will call fn with a transaction begun on the db, if transactions per file are enabled.
+// Otherwise, fn is called with the db.
+func (mng *Manager) inTransaction(db Executor, fn func(exec Executor) error) error {
+	if !mng.TransactionPerFile() {
+		return fn(db)
+	}
+	return withTransaction(db, fn)
+}
+
+// withTransaction will call fn with a transaction begun on the db, if the db can begin transactions,
+// committing it if fn does not return an error. Otherwise, fn is called with the db.
+func withTransaction(db Executor, fn func(exec Executor) error) error {
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := beginner.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,149 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_Executors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt": {Data: []byte("a.sql\nb.sql\n")},
+		"migrations/a.sql":        {Data: []byte("CREATE TABLE a ( name TEXT ); INSERT INTO a SELECT name FROM connection_only;")},
+		"migrations/b.sql":        {Data: []byte("CREATE TABLE b ( name TEXT ); INSERT INTO b VALUES ('b');")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+
+	openDB := func() *sql.DB {
+		t.Helper()
+		dbFilename, cleanup := NewTestDBFilename(t, nil)
+		t.Cleanup(cleanup)
+		db, err := sql.Open("sqlite3", dbFilename)
+		if err != nil {
+			t.Fatalf("open error, expected nil got %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	tableCount := func(exec Executor, name string) (n int) {
+		t.Helper()
+		err := exec.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM sqlite_master WHERE name = ?`, name).Scan(&n)
+		if err != nil {
+			t.Fatalf("count error, expected nil got %v", err)
+		}
+		return n
+	}
+
+	t.Run("conn", func(t *testing.T) {
+		db := openDB()
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("conn error, expected nil got %v", err)
+		}
+		defer conn.Close()
+		// a temporary table is only visible on the connection that created it
+		if _, err = conn.ExecContext(context.Background(), `CREATE TEMP TABLE connection_only AS SELECT 'a' AS name;`); err != nil {
+			t.Fatalf("create temp error, expected nil got %v", err)
+		}
+		if _, end, err := migrations.Upgrade(conn, "test"); err != nil || end != "b.sql" {
+			t.Fatalf("upgrade, expected b.sql got %v, %v", end, err)
+		}
+	})
+
+	t.Run("tx", func(t *testing.T) {
+		db := openDB()
+		if _, err := db.Exec(`CREATE TABLE connection_only AS SELECT 'a' AS name;`); err != nil {
+			t.Fatalf("create error, expected nil got %v", err)
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin error, expected nil got %v", err)
+		}
+		if _, end, err := migrations.Upgrade(tx, "test"); err != nil || end != "b.sql" {
+			t.Fatalf("upgrade, expected b.sql got %v, %v", end, err)
+		}
+		if err = tx.Rollback(); err != nil {
+			t.Fatalf("rollback error, expected nil got %v", err)
+		}
+		// nothing should be left after the rollback
+		for _, name := range []string{"a", "b", "gen_migrations"} {
+			if n := tableCount(db, name); n != 0 {
+				t.Errorf("%v, expected to be rolled back", name)
+			}
+		}
+	})
+
+	t.Run("failed file is rolled back", func(t *testing.T) {
+		db := openDB()
+		perFile := New("migrations", "gen_migrations", fsys)
+		perFile.SetTransactionPerFile(true)
+		// connection_only does not exist, so a.sql fails after creating a
+		if _, _, err := perFile.Upgrade(db, "test"); err == nil {
+			t.Fatalf("upgrade error, expected error got nil")
+		}
+		if n := tableCount(db, "a"); n != 0 {
+			t.Errorf("a, expected to be rolled back")
+		}
+		if history, err := perFile.History(db); err != nil || len(history) != 0 {
+			t.Errorf("history, expected empty got %v, %v", history, err)
+		}
+	})
+
+	t.Run("file with its own transaction", func(t *testing.T) {
+		db := openDB()
+		own := New("migrations", "gen_migrations", fstest.MapFS{
+			"migrations/sequence.txt": {Data: []byte("a.sql\n")},
+			"migrations/a.sql":        {Data: []byte("PRAGMA foreign_keys = OFF; BEGIN; CREATE TABLE a ( name TEXT ); COMMIT;")},
+		})
+		if own.TransactionPerFile() {
+			t.Fatalf("transaction per file, expected to be off by default")
+		}
+		if _, end, err := own.Upgrade(db, "test"); err != nil || end != "a.sql" {
+			t.Fatalf("upgrade, expected a.sql got %v, %v", end, err)
+		}
+		if n := tableCount(db, "a"); n != 1 {
+			t.Errorf("a, expected to be created")
+		}
+	})
+
+	t.Run("failed file with its own transaction is rolled back", func(t *testing.T) {
+		db := openDB()
+		own := New("migrations", "gen_migrations", fstest.MapFS{
+			"migrations/sequence.txt": {Data: []byte("a.sql\n")},
+			"migrations/a.sql":        {Data: []byte("BEGIN; CREATE TABLE a ( name TEXT ); INSERT INTO missing VALUES (1); COMMIT;")},
+		})
+		if _, _, err := own.Upgrade(db, "test"); err == nil {
+			t.Fatalf("upgrade error, expected error got nil")
+		}
+		if n := tableCount(db, "a"); n != 0 {
+			t.Errorf("a, expected to be rolled back")
+		}
+		// a transaction left open would hold the write lock
+		if _, err := db.Exec(`CREATE TABLE b ( name TEXT );`); err != nil {
+			t.Errorf("create error, expected nil got %v", err)
+		}
+	})
+	t.Run("failed file turning off foreign keys turns them back on", func(t *testing.T) {
+		db := openDB()
+		// the file runs on the only connection, which goes back to the pool
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
+			t.Fatalf("foreign keys error, expected nil got %v", err)
+		}
+		own := New("migrations", "gen_migrations", fstest.MapFS{
+			"migrations/sequence.txt": {Data: []byte("a.sql\n")},
+			"migrations/a.sql":        {Data: []byte("PRAGMA foreign_keys = OFF; BEGIN; CREATE TABLE a ( name TEXT ); INSERT INTO missing VALUES (1); COMMIT; PRAGMA foreign_keys = ON;")},
+		})
+		if _, _, err := own.Upgrade(db, "test"); err == nil {
+			t.Fatalf("upgrade error, expected error got nil")
+		}
+		var foreignKeys int
+		if err := db.QueryRow(`PRAGMA foreign_keys;`).Scan(&foreignKeys); err != nil {
+			t.Fatalf("foreign keys error, expected nil got %v", err)
+		}
+		if foreignKeys != 1 {
+			t.Errorf("foreign keys, expected 1 got %v", foreignKeys)
+		}
+	})
+}
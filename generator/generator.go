@@ -41,8 +41,17 @@ func (b Byer) Len() int           { return b.LenFn() }
 func (b Byer) Swap(i, j int)      { b.SwapFn(i, j) }
 
 func InsertSQL(db *sql.DB, tableName string, skipFields ...string) []byte {
+	return InsertSQLForSchema(db, DefaultSchema, tableName, skipFields...)
+}
+
+// InsertSQLForSchema returns the insert statements for the data in the table of the given database schema
+// (main, temp or an attached database). The insert statements are not qualified by the schema.
+func InsertSQLForSchema(db *sql.DB, schemaName, tableName string, skipFields ...string) []byte {
+	if schemaName == "" {
+		schemaName = DefaultSchema
+	}
 	// let's get all the data from the database
-	rows, err := db.Query(fmt.Sprintf(`SELECT * FROM "%v";`, tableName))
+	rows, err := db.Query(fmt.Sprintf(`SELECT * FROM "%v"."%v";`, schemaName, tableName))
 	if err != nil {
 		log.Fatal("got error getting data", err)
 	}
@@ -0,0 +1,236 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gdey/sqlite-migration/schema"
+)
+
+// GoOptions are the options of GoStructs
+type GoOptions struct {
+	// Package is the name of the package of the go file
+	Package string
+	// Views is true to generate structs for the views as well as the tables
+	Views bool
+	// Pointers is true to use pointers for nullable columns, rather than the sql.NullX types
+	Pointers bool
+	// Generator names the program that generated the file, in its header; e.g. migrate gen-go
+	Generator string
+	// Skip are the tables to leave out; e.g. the migrations tracking table
+	Skip []string
+}
+
+// goInitialisms are the words that are upper case in go names
+var goInitialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "SQL": true,
+	"SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true,
+	"URI": true, "URL": true, "UTF8": true, "UUID": true, "XML": true,
+}
+
+// goStruct is a struct of the go file
+type goStruct struct {
+	Name  string
+	Kind  string
+	Table string
+	// Fields are the name, type and tag of each field, aligned by go/format
+	Fields []goField
+}
+
+type goField struct {
+	Name, Type, Column string
+}
+
+// GoStructs returns a go file with a struct for each table of the schema, and, optionally, each view. Each
+// struct has a field, with db and json tags, for each column, and a TableName method. Columns get the go type
+// of their declared type, or its SQLite type affinity; nullable columns are the sql.NullX type, or a pointer, of it.
+func GoStructs(s schema.Schema, opts GoOptions) ([]byte, error) {
+	include := schema.IncludeFunc(opts.Skip...)
+	imports := make(map[string]bool)
+
+	var structs []goStruct
+	tables, err := s.Tables()
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		if !include(table.Name()) {
+			continue
+		}
+		columns, err := table.Columns()
+		if err != nil {
+			return nil, err
+		}
+		structs = append(structs, newGoStruct("table", table.Name(), columns, opts.Pointers, imports))
+	}
+	if opts.Views {
+		views, err := s.Views()
+		if err != nil {
+			return nil, err
+		}
+		for _, view := range views {
+			if !include(view.Name()) {
+				continue
+			}
+			columns, err := view.Columns()
+			if err != nil {
+				return nil, err
+			}
+			structs = append(structs, newGoStruct("view", view.Name(), columns, opts.Pointers, imports))
+		}
+	}
+	sort.Slice(structs, func(i, j int) bool { return structs[i].Table < structs[j].Table })
+	// two tables may have the same go name; e.g. user_ids and UserIDs
+	used := make(map[string]int)
+	for i := range structs {
+		structs[i].Name = uniqueGoName(structs[i].Name, used)
+	}
+
+	var src bytes.Buffer
+	generator := opts.Generator
+	if generator == "" {
+		generator = "sqlite-migration"
+	}
+	fmt.Fprintf(&src, "// Code generated by %v; DO NOT EDIT.\n\n", generator)
+	fmt.Fprintf(&src, "package %v\n\n", opts.Package)
+	if len(imports) != 0 {
+		names := make([]string, 0, len(imports))
+		for name := range imports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		src.WriteString("import (\n")
+		for _, name := range names {
+			fmt.Fprintf(&src, "\t%q\n", name)
+		}
+		src.WriteString(")\n\n")
+	}
+	for _, st := range structs {
+		fmt.Fprintf(&src, "// %v is a row of the %v %v\n", st.Name, st.Table, st.Kind)
+		fmt.Fprintf(&src, "type %v struct {\n", st.Name)
+		for _, field := range st.Fields {
+			fmt.Fprintf(&src, "\t%v %v `db:%q json:%q`\n", field.Name, field.Type, field.Column, field.Column)
+		}
+		src.WriteString("}\n\n")
+		fmt.Fprintf(&src, "// TableName returns the name of the %v\n", st.Kind)
+		fmt.Fprintf(&src, "func (%v) TableName() string { return %q }\n\n", st.Name, st.Table)
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting the generated go: %w\n%s", err, src.Bytes())
+	}
+	return formatted, nil
+}
+
+// newGoStruct returns the struct for the table or view, adding the packages its fields need to imports
+func newGoStruct(kind, name string, columns []schema.Column, pointers bool, imports map[string]bool) goStruct {
+	st := goStruct{Name: GoName(name), Kind: kind, Table: name}
+	used := map[string]int{"TableName": 1}
+	for _, column := range columns {
+		if column.Hidden() {
+			continue
+		}
+		// the primary key of a rowid table can not be null, though it is not declared NOT NULL
+		primary, _ := column.IsPrimary()
+		nullable := column.Nullable() && !primary
+		typ, pkg := goType(column.Type(), nullable, pointers)
+		if pkg != "" {
+			imports[pkg] = true
+		}
+		st.Fields = append(st.Fields, goField{
+			Name:   uniqueGoName(GoName(column.Name()), used),
+			Type:   typ,
+			Column: column.Name(),
+		})
+	}
+	return st
+}
+
+// goType returns the go type, and the package it needs, for a column of the declared type; types other than
+// the ones listed get the go type of their SQLite type affinity, e.g. BIGINT is an int64, VARCHAR(255) a
+// string, and a column without a declared type a []byte.
+func goType(declared string, nullable, pointers bool) (typ string, pkg string) {
+	switch declared {
+	case "NULL":
+		return "interface{}", ""
+	case "BOOL", "BOOLEAN":
+		typ = "bool"
+	case "DATETIME", "DATE", "TIMESTAMP":
+		typ, pkg = "time.Time", "time"
+	}
+	// REF: https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+	switch {
+	case typ != "":
+	case strings.Contains(declared, "INT"):
+		typ = "int64"
+	case strings.Contains(declared, "CHAR"), strings.Contains(declared, "CLOB"), strings.Contains(declared, "TEXT"):
+		typ = "string"
+	case declared == "", strings.Contains(declared, "BLOB"):
+		// a nil slice is a null
+		return "[]byte", ""
+	case strings.Contains(declared, "REAL"), strings.Contains(declared, "FLOA"), strings.Contains(declared, "DOUB"):
+		typ = "float64"
+	default:
+		// NUMERIC affinity, the value may be an integer or a real
+		typ = "string"
+	}
+	if !nullable {
+		return typ, pkg
+	}
+	if pointers {
+		return "*" + typ, pkg
+	}
+	switch typ {
+	case "int64":
+		return "sql.NullInt64", "database/sql"
+	case "float64":
+		return "sql.NullFloat64", "database/sql"
+	case "bool":
+		return "sql.NullBool", "database/sql"
+	case "string":
+		return "sql.NullString", "database/sql"
+	default:
+		return "sql.NullTime", "database/sql"
+	}
+}
+
+// GoName returns the exported go name for the sql name; e.g. user_id is UserID
+func GoName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	var goName strings.Builder
+	for _, word := range words {
+		if upper := strings.ToUpper(word); goInitialisms[upper] {
+			goName.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		goName.WriteString(string(unicode.ToUpper(runes[0])) + string(runes[1:]))
+	}
+	if goName.Len() == 0 {
+		return "X"
+	}
+	if first := []rune(goName.String())[0]; !unicode.IsLetter(first) {
+		return "X" + goName.String()
+	}
+	return goName.String()
+}
+
+// uniqueGoName returns the name, with a number added if it has been used already
+func uniqueGoName(name string, used map[string]int) string {
+	used[name]++
+	if used[name] == 1 {
+		return name
+	}
+	unique := fmt.Sprintf("%v%d", name, used[name])
+	for used[unique] != 0 {
+		used[name]++
+		unique = fmt.Sprintf("%v%d", name, used[name])
+	}
+	used[unique]++
+	return unique
+}
@@ -0,0 +1,141 @@
+package generator_test
+
+import (
+	"testing"
+
+	"github.com/gdey/sqlite-migration/generator"
+)
+
+func TestGoStructs(t *testing.T) {
+	db := newMigrationDB(t, "structs.db",
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			nick TEXT,
+			age BIGINT,
+			score REAL NOT NULL DEFAULT 0,
+			active BOOLEAN,
+			avatar BLOB,
+			data,
+			created_at DATETIME NOT NULL,
+			"owner id" INTEGER,
+			"table name" TEXT
+		)`,
+		`CREATE TABLE tracking (version TEXT)`,
+		`CREATE VIEW user_names AS SELECT id, name FROM users`,
+	)
+	type tcase struct {
+		opts     generator.GoOptions
+		expected string
+	}
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			src, err := generator.GoStructs(db, tc.opts)
+			if err != nil {
+				t.Fatalf("go structs, expected nil got %v", err)
+			}
+			if string(src) != tc.expected {
+				t.Errorf("go structs,\n\texpected\n%v\n\tgot\n%s", tc.expected, src)
+			}
+		}
+	}
+	tests := map[string]tcase{
+		"null types": {
+			opts: generator.GoOptions{Package: "models", Generator: "migrate gen-go", Skip: []string{"tracking"}},
+			expected: `// Code generated by migrate gen-go; DO NOT EDIT.
+
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Users is a row of the users table
+type Users struct {
+	ID         int64          ` + "`" + `db:"id" json:"id"` + "`" + `
+	Name       string         ` + "`" + `db:"name" json:"name"` + "`" + `
+	Nick       sql.NullString ` + "`" + `db:"nick" json:"nick"` + "`" + `
+	Age        sql.NullInt64  ` + "`" + `db:"age" json:"age"` + "`" + `
+	Score      float64        ` + "`" + `db:"score" json:"score"` + "`" + `
+	Active     sql.NullBool   ` + "`" + `db:"active" json:"active"` + "`" + `
+	Avatar     []byte         ` + "`" + `db:"avatar" json:"avatar"` + "`" + `
+	Data       []byte         ` + "`" + `db:"data" json:"data"` + "`" + `
+	CreatedAt  time.Time      ` + "`" + `db:"created_at" json:"created_at"` + "`" + `
+	OwnerID    sql.NullInt64  ` + "`" + `db:"owner id" json:"owner id"` + "`" + `
+	TableName2 sql.NullString ` + "`" + `db:"table name" json:"table name"` + "`" + `
+}
+
+// TableName returns the name of the table
+func (Users) TableName() string { return "users" }
+`,
+		},
+		"pointers and views": {
+			opts: generator.GoOptions{Package: "db", Views: true, Pointers: true},
+			expected: `// Code generated by sqlite-migration; DO NOT EDIT.
+
+package db
+
+import (
+	"time"
+)
+
+// Tracking is a row of the tracking table
+type Tracking struct {
+	Version *string ` + "`" + `db:"version" json:"version"` + "`" + `
+}
+
+// TableName returns the name of the table
+func (Tracking) TableName() string { return "tracking" }
+
+// UserNames is a row of the user_names view
+type UserNames struct {
+	ID   *int64  ` + "`" + `db:"id" json:"id"` + "`" + `
+	Name *string ` + "`" + `db:"name" json:"name"` + "`" + `
+}
+
+// TableName returns the name of the view
+func (UserNames) TableName() string { return "user_names" }
+
+// Users is a row of the users table
+type Users struct {
+	ID         int64     ` + "`" + `db:"id" json:"id"` + "`" + `
+	Name       string    ` + "`" + `db:"name" json:"name"` + "`" + `
+	Nick       *string   ` + "`" + `db:"nick" json:"nick"` + "`" + `
+	Age        *int64    ` + "`" + `db:"age" json:"age"` + "`" + `
+	Score      float64   ` + "`" + `db:"score" json:"score"` + "`" + `
+	Active     *bool     ` + "`" + `db:"active" json:"active"` + "`" + `
+	Avatar     []byte    ` + "`" + `db:"avatar" json:"avatar"` + "`" + `
+	Data       []byte    ` + "`" + `db:"data" json:"data"` + "`" + `
+	CreatedAt  time.Time ` + "`" + `db:"created_at" json:"created_at"` + "`" + `
+	OwnerID    *int64    ` + "`" + `db:"owner id" json:"owner id"` + "`" + `
+	TableName2 *string   ` + "`" + `db:"table name" json:"table name"` + "`" + `
+}
+
+// TableName returns the name of the table
+func (Users) TableName() string { return "users" }
+`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"users":       "Users",
+		"user_id":     "UserID",
+		"api_url":     "APIURL",
+		"firstName":   "FirstName",
+		"owner id":    "OwnerID",
+		"1st_place":   "X1stPlace",
+		"__":          "X",
+		"order-items": "OrderItems",
+	}
+	for name, expected := range tests {
+		if got := generator.GoName(name); got != expected {
+			t.Errorf("go name of %q, expected %v got %v", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/sqlparse"
+)
+
+// MigrationOptions are the options of MigrationSQL
+type MigrationOptions struct {
+	// DropColumn is true when ALTER TABLE ... DROP COLUMN, added in SQLite 3.35.0, can be used; otherwise
+	// tables that lose a column are rebuilt
+	DropColumn bool
+	// Skip are the tables, along with their indexes and triggers, to leave out; e.g. the migrations tracking table
+	Skip []string
+	// Renames are the renamed columns, the new name by "table.old_name". A column that is no longer in the
+	// table, and is not renamed, is dropped, along with its data.
+	Renames map[string]string
+}
+
+// rebuiltSuffix is added to the name of a table while it is rebuilt
+const rebuiltSuffix = "__rebuilt"
+
+// foreignKeyCheck are the statements that fail if PRAGMA foreign_key_check reports any rows, as the pragma
+// on its own only lists them
+var foreignKeyCheck = []string{
+	"CREATE TEMP TABLE foreign_key_check (violations INTEGER CHECK (violations = 0))",
+	"INSERT INTO temp.foreign_key_check (violations) SELECT count(*) FROM pragma_foreign_key_check",
+	"DROP TABLE temp.foreign_key_check",
+}
+
+// MigrationSQL returns the sql of a migration that changes the schema from into the schema to; "" if they
+// are the same.
+//
+// Tables are changed with ALTER TABLE ... ADD, RENAME and DROP COLUMN where SQLite allows it, and are rebuilt
+// otherwise; the new table is created, the rows copied into it, and the old table dropped. Columns are only
+// renamed when they are in opts.Renames. The indexes and triggers of a rebuilt table are created again.
+//
+// When a table is rebuilt the sql follows SQLite's procedure for changing a table: foreign keys are turned
+// off, outside of the transaction the sql begins, and checked with PRAGMA foreign_key_check before it is
+// committed. So the sql can not be applied in a transaction (see migration.Manager.SetTransactionPerFile).
+func MigrationSQL(from, to schema.Schema, opts MigrationOptions) (string, error) {
+	changes, err := schema.Diff(from, to, opts.Skip...)
+	if err != nil {
+		return "", err
+	}
+	if changes.Empty() {
+		return "", nil
+	}
+
+	var (
+		tables strings.Builder
+		// gone are the tables that are dropped, or rebuilt, along with their indexes and triggers
+		gone    = make(map[string]bool)
+		rebuilt []string
+		// lostData is true when a table is removed, or a rebuilt table loses columns
+		lostData bool
+		// usedRenames are the keys of opts.Renames of the changed tables
+		usedRenames = make(map[string]bool)
+	)
+	for _, change := range changes.Tables {
+		switch change.Kind {
+		case schema.Added:
+			writeStatements(&tables, fmt.Sprintf("-- %v: added", change.Name), change.To)
+		case schema.Removed:
+			gone[change.Name] = true
+			lostData = true
+			writeStatements(&tables, fmt.Sprintf("-- %v: removed", change.Name), "DROP TABLE "+sqlparse.QuoteIdentifier(change.Name))
+		case schema.Changed:
+			fromTable, err := parseTable(change.From)
+			if err != nil {
+				return "", fmt.Errorf("table %v: %w", change.Name, err)
+			}
+			toTable, err := parseTable(change.To)
+			if err != nil {
+				return "", fmt.Errorf("table %v: %w", change.Name, err)
+			}
+			renames, err := columnRenames(fromTable, toTable, opts.Renames, usedRenames)
+			if err != nil {
+				return "", err
+			}
+			if statements, ok := alterTable(fromTable, toTable, renames, opts); ok {
+				writeStatements(&tables, fmt.Sprintf("-- %v: altered", change.Name), statements...)
+				continue
+			}
+			gone[change.Name] = true
+			rebuilt = append(rebuilt, change.Name)
+			comment := fmt.Sprintf("-- %v: rebuilt, as SQLite can not ALTER it into the new definition", change.Name)
+			statements, lost := rebuildTable(fromTable, toTable, renames)
+			if len(lost) != 0 {
+				lostData = true
+				comment += fmt.Sprintf("\n-- the data of %v is not copied", strings.Join(lost, ", "))
+			}
+			writeStatements(&tables, comment, statements...)
+		}
+	}
+	var unused []string
+	for key := range opts.Renames {
+		if !usedRenames[key] {
+			unused = append(unused, key)
+		}
+	}
+	if len(unused) != 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("renames of tables that are not changed: %v", strings.Join(unused, ", "))
+	}
+
+	// the indexes and triggers of the rebuilt tables are created again
+	var createIndexes, createTriggers []string
+	toIndexes, err := to.Indexes()
+	if err != nil {
+		return "", err
+	}
+	for _, index := range toIndexes {
+		// indexes created for UNIQUE and PRIMARY KEY constraints have no sql
+		if sql := schema.IndexSQL(index); gone[index.Table()] && sql != "" {
+			createIndexes = append(createIndexes, schema.NormaliseSQL(sql))
+		}
+	}
+	toTriggers, err := to.Triggers()
+	if err != nil {
+		return "", err
+	}
+	for _, trigger := range toTriggers {
+		if gone[trigger.Table()] {
+			createTriggers = append(createTriggers, schema.NormaliseSQL(trigger.SQL()))
+		}
+	}
+
+	var (
+		drops   []string
+		creates []string
+	)
+	for _, change := range changes.Views {
+		if change.Kind != schema.Added {
+			drops = append(drops, "DROP VIEW "+sqlparse.QuoteIdentifier(change.Name))
+		}
+		if change.Kind != schema.Removed {
+			creates = append(creates, change.To)
+		}
+	}
+	for _, objects := range []struct {
+		kind    string
+		changes []schema.Change
+		creates *[]string
+	}{
+		{"TRIGGER", changes.Triggers, &createTriggers},
+		{"INDEX", changes.Indexes, &createIndexes},
+	} {
+		for _, change := range objects.changes {
+			if change.Kind != schema.Added {
+				table, err := objectTable(change.From)
+				if err != nil {
+					return "", fmt.Errorf("%v %v: %w", strings.ToLower(objects.kind), change.Name, err)
+				}
+				if !gone[table] {
+					drops = append(drops, "DROP "+objects.kind+" "+sqlparse.QuoteIdentifier(change.Name))
+				}
+			}
+			if change.Kind != schema.Removed {
+				table, err := objectTable(change.To)
+				if err != nil {
+					return "", fmt.Errorf("%v %v: %w", strings.ToLower(objects.kind), change.Name, err)
+				}
+				if !gone[table] {
+					*objects.creates = append(*objects.creates, change.To)
+				}
+			}
+		}
+	}
+
+	var sql strings.Builder
+	if len(rebuilt) != 0 {
+		// SQLite's procedure for changing a table: with foreign keys on, dropping the old table would delete
+		// (or cascade to) the rows that reference it, and PRAGMA foreign_keys does nothing in a transaction
+		fmt.Fprintf(&sql, "-- %v rebuilt; foreign keys are turned off while the tables are rebuilt, and checked\n", strings.Join(rebuilt, ", "))
+		sql.WriteString("-- before the transaction is committed. The file begins its own transaction, so it can not be\n")
+		sql.WriteString("-- applied with transactions per file, and turns foreign keys on once it is done.\n")
+		if !lostData {
+			// the tables are dropped after their rows have been copied, with foreign keys off
+			sql.WriteString("-- lint:allow drop-table\n")
+		}
+		sql.WriteString("\n")
+		writeStatements(&sql, "", "PRAGMA foreign_keys = OFF", "BEGIN")
+	}
+	writeStatements(&sql, "", drops...)
+	sql.WriteString(tables.String())
+	writeStatements(&sql, "", createIndexes...)
+	writeStatements(&sql, "", createTriggers...)
+	writeStatements(&sql, "", creates...)
+	if len(rebuilt) != 0 {
+		writeStatements(&sql, "-- fails, rolling back the transaction, if a row no longer matches its foreign key", foreignKeyCheck...)
+		writeStatements(&sql, "", "COMMIT", "PRAGMA foreign_keys = ON")
+	}
+	return strings.TrimSuffix(sql.String(), "\n"), nil
+}
+
+// writeStatements writes the comment, if it is not "", followed by the statements, ending each with a ;
+// and the group with a blank line
+func writeStatements(sql *strings.Builder, comment string, statements ...string) {
+	if len(statements) == 0 {
+		return
+	}
+	if comment != "" {
+		sql.WriteString(comment + "\n")
+	}
+	for _, statement := range statements {
+		sql.WriteString(strings.TrimSuffix(strings.TrimSpace(statement), ";") + ";\n")
+	}
+	sql.WriteString("\n")
+}
+
+// parseTable parses the sql of a table
+func parseTable(sql string) (*sqlparse.CreateTable, error) {
+	statement, err := sqlparse.Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := statement.(*sqlparse.CreateTable)
+	if !ok || table.AsSelect != "" {
+		return nil, fmt.Errorf("expected a CREATE TABLE with columns, got `%v`", sql)
+	}
+	return table, nil
+}
+
+// objectTable returns the table of the sql of an index or trigger
+func objectTable(sql string) (string, error) {
+	statement, err := sqlparse.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	switch statement := statement.(type) {
+	case *sqlparse.CreateIndex:
+		return statement.Table, nil
+	case *sqlparse.CreateTrigger:
+		return statement.Table, nil
+	default:
+		return "", fmt.Errorf("expected a CREATE INDEX or TRIGGER, got `%v`", sql)
+	}
+}
+
+// columnRenames returns the old name, by new name, of the columns of the table that are renamed in renames;
+// the keys of renames, that are used, are added to used.
+func columnRenames(from, to *sqlparse.CreateTable, renames map[string]string, used map[string]bool) (map[string]string, error) {
+	columns := make(map[string]string)
+	for key, newName := range renames {
+		dot := strings.LastIndex(key, ".")
+		if dot == -1 || key[:dot] != to.Name.Name {
+			continue
+		}
+		table, oldName := key[:dot], key[dot+1:]
+		used[key] = true
+		if from.Column(oldName) == nil || to.Column(oldName) != nil {
+			return nil, fmt.Errorf("rename %v: %v is not a column that was removed from %v", key, oldName, table)
+		}
+		if to.Column(newName) == nil || from.Column(newName) != nil {
+			return nil, fmt.Errorf("rename %v: %v is not a column that was added to %v", key, newName, table)
+		}
+		columns[newName] = oldName
+	}
+	return columns, nil
+}
+
+// alterTable returns the ALTER TABLE statements that turn the from table into the to table; false if SQLite
+// can not ALTER it into the to table, and it has to be rebuilt
+func alterTable(from, to *sqlparse.CreateTable, renames map[string]string, opts MigrationOptions) ([]string, bool) {
+	// the statements are applied to a copy of the from table, which must end up the same as the to table
+	altered := *from
+	altered.Columns = append([]sqlparse.ColumnDef{}, from.Columns...)
+	altered.Constraints = append([]sqlparse.TableConstraint{}, from.Constraints...)
+	name := sqlparse.QuoteIdentifier(to.Name.Name)
+
+	var statements []string
+	for _, column := range to.Columns {
+		oldName, ok := renames[column.Name]
+		if !ok {
+			continue
+		}
+		altered.Column(oldName).Name = column.Name
+		for i := range altered.Constraints {
+			constraint := &altered.Constraints[i]
+			constraint.Columns = append([]sqlparse.IndexedColumn{}, constraint.Columns...)
+			for j := range constraint.Columns {
+				if strings.EqualFold(constraint.Columns[j].Name, oldName) {
+					constraint.Columns[j].Name = column.Name
+				}
+			}
+			constraint.FromColumns = append([]string{}, constraint.FromColumns...)
+			for j := range constraint.FromColumns {
+				if strings.EqualFold(constraint.FromColumns[j], oldName) {
+					constraint.FromColumns[j] = column.Name
+				}
+			}
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %v RENAME COLUMN %v TO %v",
+			name, sqlparse.QuoteIdentifier(oldName), sqlparse.QuoteIdentifier(column.Name),
+		))
+	}
+	for i := 0; i < len(altered.Columns); i++ {
+		column := altered.Columns[i]
+		if to.Column(column.Name) != nil {
+			continue
+		}
+		if !opts.DropColumn || !canDropColumn(&altered, column) {
+			return nil, false
+		}
+		altered.Columns = append(altered.Columns[:i:i], altered.Columns[i+1:]...)
+		i--
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %v DROP COLUMN %v", name, sqlparse.QuoteIdentifier(column.Name)))
+	}
+	for _, column := range to.Columns {
+		if altered.Column(column.Name) != nil {
+			continue
+		}
+		if !canAddColumn(column) {
+			return nil, false
+		}
+		altered.Columns = append(altered.Columns, column)
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v", name, column))
+	}
+
+	altered.Temporary, altered.IfNotExists = to.Temporary, to.IfNotExists
+	return statements, altered.String() == to.String()
+}
+
+// canDropColumn returns true if ALTER TABLE ... DROP COLUMN can drop the column of the table; it can not be
+// part of a key, or be used by the constraints or generated columns of the table
+func canDropColumn(table *sqlparse.CreateTable, column sqlparse.ColumnDef) bool {
+	for _, constraint := range column.Constraints {
+		switch constraint.Kind {
+		case sqlparse.ConstraintPrimaryKey, sqlparse.ConstraintUnique, sqlparse.ConstraintForeignKey:
+			return false
+		}
+	}
+	for _, other := range table.Columns {
+		for _, constraint := range other.Constraints {
+			if exprReferences(constraint.Expr, column.Name) {
+				return false
+			}
+		}
+	}
+	for _, constraint := range table.Constraints {
+		if exprReferences(constraint.Expr, column.Name) {
+			return false
+		}
+		for _, indexed := range constraint.Columns {
+			if strings.EqualFold(indexed.Name, column.Name) || exprReferences(indexed.Expr, column.Name) {
+				return false
+			}
+		}
+		for _, name := range constraint.FromColumns {
+			if strings.EqualFold(name, column.Name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// canAddColumn returns true if ALTER TABLE ... ADD COLUMN can add the column; it can not be a key, a STORED
+// generated column, or have a default that is not a constant, and a NOT NULL, or REFERENCES, column needs
+// a default that is not NULL
+func canAddColumn(column sqlparse.ColumnDef) bool {
+	var defaultValue string
+	if constraint := column.Constraint(sqlparse.ConstraintDefault); constraint != nil {
+		defaultValue = strings.ToUpper(constraint.Expr)
+	}
+	switch defaultValue {
+	case "CURRENT_TIME", "CURRENT_DATE", "CURRENT_TIMESTAMP":
+		return false
+	}
+	if strings.HasPrefix(defaultValue, "(") {
+		return false
+	}
+	hasDefault := defaultValue != "" && defaultValue != "NULL"
+	generated := column.Constraint(sqlparse.ConstraintGenerated)
+	for _, constraint := range column.Constraints {
+		switch constraint.Kind {
+		case sqlparse.ConstraintPrimaryKey, sqlparse.ConstraintUnique:
+			return false
+		case sqlparse.ConstraintGenerated:
+			if constraint.Stored {
+				return false
+			}
+		case sqlparse.ConstraintNotNull:
+			if !hasDefault && generated == nil {
+				return false
+			}
+		case sqlparse.ConstraintForeignKey:
+			if hasDefault {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// exprReferences returns true if the expression uses the column
+func exprReferences(expr, column string) bool {
+	if expr == "" {
+		return false
+	}
+	tokens, err := sqlparse.Tokenize(expr)
+	if err != nil {
+		// assume the worst
+		return true
+	}
+	for _, tok := range tokens {
+		if (tok.Kind == sqlparse.TokenIdent || tok.Kind == sqlparse.TokenQuotedIdent) && strings.EqualFold(tok.Value(), column) {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildTable returns the statements that rebuild the from table as the to table; the to table is created
+// under another name, the rows of the from table are copied into it, the from table is dropped, and the to
+// table renamed. lost are the columns of the from table that are not copied.
+func rebuildTable(from, to *sqlparse.CreateTable, renames map[string]string) (statements []string, lost []string) {
+	rebuilt := *to
+	rebuilt.Name = sqlparse.QualifiedName{Name: to.Name.Name + rebuiltSuffix}
+	rebuilt.IfNotExists = false
+	name, rebuiltName := sqlparse.QuoteIdentifier(to.Name.Name), rebuilt.Name.String()
+
+	var toColumns, fromColumns []string
+	copied := make(map[string]bool)
+	for _, column := range to.Columns {
+		// generated columns can not be inserted into, and are computed again
+		if column.Constraint(sqlparse.ConstraintGenerated) != nil {
+			continue
+		}
+		oldName, ok := renames[column.Name]
+		if !ok {
+			oldName = column.Name
+		}
+		oldColumn := from.Column(oldName)
+		if oldColumn == nil || oldColumn.Constraint(sqlparse.ConstraintGenerated) != nil {
+			continue
+		}
+		toColumns = append(toColumns, sqlparse.QuoteIdentifier(column.Name))
+		fromColumns = append(fromColumns, sqlparse.QuoteIdentifier(oldColumn.Name))
+		copied[oldColumn.Name] = true
+	}
+	for _, column := range from.Columns {
+		if !copied[column.Name] && column.Constraint(sqlparse.ConstraintGenerated) == nil {
+			lost = append(lost, column.Name)
+		}
+	}
+
+	statements = []string{rebuilt.String()}
+	if len(toColumns) != 0 {
+		statements = append(statements, fmt.Sprintf("INSERT INTO %v (%v) SELECT %v FROM %v",
+			rebuiltName, strings.Join(toColumns, ", "), strings.Join(fromColumns, ", "), name,
+		))
+	}
+	statements = append(statements,
+		"DROP TABLE "+name,
+		// without legacy_alter_table, the rename fails on the views and triggers that use the dropped table
+		"PRAGMA legacy_alter_table = ON",
+		fmt.Sprintf("ALTER TABLE %v RENAME TO %v", rebuiltName, name),
+		"PRAGMA legacy_alter_table = OFF",
+	)
+	return statements, lost
+}
@@ -0,0 +1,298 @@
+package generator_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdey/sqlite-migration/generator"
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	// we only work with sqlite
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newMigrationDB(t *testing.T, name string, statements ...string) *sqlite.DB {
+	t.Helper()
+	db, err := sqlite.New(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatalf("new error, expected nil got %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, statement := range statements {
+		if _, err := db.DB.Exec(statement); err != nil {
+			t.Fatalf("exec %v, expected nil got %v", statement, err)
+		}
+	}
+	return db
+}
+
+// checkRows checks the result of the query, if it is not ""
+func checkRows(t *testing.T, db *sqlite.DB, query, expected string) {
+	t.Helper()
+	if query == "" {
+		return
+	}
+	var got string
+	if err := db.DB.QueryRow(query).Scan(&got); err != nil {
+		t.Fatalf("rows, expected nil got %v", err)
+	}
+	if got != expected {
+		t.Errorf("rows, expected %v got %v", expected, got)
+	}
+}
+
+func TestMigrationSQL(t *testing.T) {
+	type tcase struct {
+		from []string
+		to   []string
+		opts generator.MigrationOptions
+		// contains and excludes are the statements the sql must, and must not, have
+		contains []string
+		excludes []string
+		// rows is a query, and its result, that must be the same after the migration has been applied
+		rows     string
+		expected string
+		// skipApply is true when the sql can not be run by the SQLite of the test
+		skipApply bool
+		// failApply is true when applying the sql must fail, leaving the rows as they were
+		failApply bool
+	}
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			from := newMigrationDB(t, "from.db", tc.from...)
+			to := newMigrationDB(t, "to.db", tc.to...)
+			sql, err := generator.MigrationSQL(from, to, tc.opts)
+			if err != nil {
+				t.Fatalf("migration sql, expected nil got %v", err)
+			}
+			for _, statement := range tc.contains {
+				if !strings.Contains(sql, statement) {
+					t.Errorf("sql, expected to contain %q got\n%v", statement, sql)
+				}
+			}
+			for _, statement := range tc.excludes {
+				if strings.Contains(sql, statement) {
+					t.Errorf("sql, expected not to contain %q got\n%v", statement, sql)
+				}
+			}
+			if tc.skipApply {
+				return
+			}
+
+			// the sql is applied to a connection with foreign keys on, and may begin its own transaction
+			conn, err := from.DB.Conn(context.Background())
+			if err != nil {
+				t.Fatalf("conn, expected nil got %v", err)
+			}
+			defer conn.Close()
+			if _, err = conn.ExecContext(context.Background(), `PRAGMA foreign_keys = ON`); err != nil {
+				t.Fatalf("foreign keys, expected nil got %v", err)
+			}
+			_, err = conn.ExecContext(context.Background(), sql)
+			if tc.failApply {
+				if err == nil {
+					t.Fatalf("exec, expected error got nil\n%v", sql)
+				}
+				// the transaction the sql began is left open when it fails
+				if _, err = conn.ExecContext(context.Background(), `ROLLBACK`); err != nil {
+					t.Fatalf("rollback, expected nil got %v", err)
+				}
+				checkRows(t, from, tc.rows, tc.expected)
+				return
+			}
+			if err != nil {
+				t.Fatalf("exec, expected nil got %v\n%v", err, sql)
+			}
+			changes, err := schema.Diff(from, to, tc.opts.Skip...)
+			if err != nil {
+				t.Fatalf("diff, expected nil got %v", err)
+			}
+			// the sql of altered and rebuilt tables is not written as it was, but they must define the same table
+			remaining := changes
+			remaining.Tables = nil
+			for _, table := range changes.Tables {
+				if table.Kind != schema.Changed || len(table.Columns) != 0 || len(table.ForeignKeys) != 0 {
+					remaining.Tables = append(remaining.Tables, table)
+				}
+			}
+			if !remaining.Empty() {
+				t.Errorf("changes after the migration, expected none got\n%v\n%v", remaining, sql)
+			}
+			checkRows(t, from, tc.rows, tc.expected)
+		}
+	}
+	tests := map[string]tcase{
+		"same": {
+			from: []string{`CREATE TABLE a (b INTEGER)`},
+			to:   []string{`CREATE TABLE a ( b INTEGER ) -- comments and spacing do not matter`},
+		},
+		"alter": {
+			from: []string{
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, UNIQUE (name))`,
+				`INSERT INTO users (name) VALUES ('ann'), ('bob')`,
+			},
+			to: []string{
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, full_name TEXT, active BOOLEAN NOT NULL DEFAULT 1, UNIQUE (full_name))`,
+			},
+			opts: generator.MigrationOptions{Renames: map[string]string{"users.name": "full_name"}},
+			contains: []string{
+				`ALTER TABLE users RENAME COLUMN name TO full_name;`,
+				`ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT 1;`,
+			},
+			excludes: []string{"DROP TABLE"},
+			rows:     `SELECT group_concat(full_name || active) FROM users`,
+			expected: "ann1,bob1",
+		},
+		"rebuild": {
+			from: []string{
+				`CREATE TABLE owners (id INTEGER PRIMARY KEY)`,
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age TEXT, owner_id INTEGER)`,
+				`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id))`,
+				`CREATE INDEX users_name ON users (name)`,
+				`CREATE VIEW named_users AS SELECT name FROM users`,
+				`CREATE TRIGGER posts_ai AFTER INSERT ON posts BEGIN UPDATE users SET age = age WHERE id = new.user_id; END`,
+				`CREATE TABLE old (id INTEGER)`,
+				`INSERT INTO owners (id) VALUES (7)`,
+				`INSERT INTO users (name, age, owner_id) VALUES ('ann', '30', 7)`,
+			},
+			to: []string{
+				`CREATE TABLE owners (id INTEGER PRIMARY KEY)`,
+				`CREATE TABLE users (
+					id INTEGER PRIMARY KEY,
+					name TEXT NOT NULL,
+					age INTEGER,
+					owner_id INTEGER REFERENCES owners(id),
+					upper_name TEXT AS (upper(name))
+				)`,
+				`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id))`,
+				`CREATE INDEX users_name ON users (name)`,
+				`CREATE INDEX users_age ON users (age)`,
+				`CREATE VIEW named_users AS SELECT name FROM users`,
+				`CREATE TRIGGER posts_ai AFTER INSERT ON posts BEGIN UPDATE users SET age = age WHERE id = new.user_id; END`,
+				`CREATE TABLE tags (name TEXT)`,
+			},
+			contains: []string{
+				`INSERT INTO users__rebuilt (id, name, age, owner_id) SELECT id, name, age, owner_id FROM users;`,
+				`DROP TABLE users;`,
+				`ALTER TABLE users__rebuilt RENAME TO users;`,
+				`CREATE INDEX users_name ON users (name);`,
+				`DROP TABLE old;`,
+				`CREATE TABLE tags (name TEXT);`,
+			},
+			// a table is removed, so the drop-table lint rule is not allowed
+			excludes: []string{"lint:allow"},
+			rows:     `SELECT name || typeof(age) || owner_id || upper_name FROM users`,
+			expected: "anninteger7ANN",
+		},
+		"rebuild referenced table": {
+			from: []string{
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`,
+				`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id) ON DELETE CASCADE)`,
+				`INSERT INTO users (id, name) VALUES (1, 'ann'), (2, 'bob')`,
+				`INSERT INTO posts (user_id) VALUES (1), (2)`,
+			},
+			to: []string{
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, email TEXT UNIQUE)`,
+				`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id) ON DELETE CASCADE)`,
+			},
+			contains: []string{
+				"PRAGMA foreign_keys = OFF;\nBEGIN;",
+				`DROP TABLE users;`,
+				"FROM pragma_foreign_key_check;",
+				"COMMIT;\nPRAGMA foreign_keys = ON;",
+			},
+			// dropping users, with foreign keys on, would have deleted the posts
+			rows:     `SELECT count(*) || ',' || (SELECT count(*) FROM users) FROM posts`,
+			expected: "2,2",
+		},
+		"rebuild with a broken foreign key": {
+			from: []string{
+				`CREATE TABLE owners (id INTEGER PRIMARY KEY)`,
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, owner_id INTEGER)`,
+				`INSERT INTO users (owner_id) VALUES (7)`,
+			},
+			to: []string{
+				`CREATE TABLE owners (id INTEGER PRIMARY KEY)`,
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, owner_id INTEGER REFERENCES owners(id))`,
+			},
+			failApply: true,
+			rows:      `SELECT count(*) || sql FROM users, sqlite_master WHERE sqlite_master.name = 'users'`,
+			expected:  "1CREATE TABLE users (id INTEGER PRIMARY KEY, owner_id INTEGER)",
+		},
+		"rebuild instead of drop column": {
+			from: []string{
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)`,
+				`INSERT INTO users (name, age) VALUES ('ann', 30)`,
+			},
+			to:       []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`},
+			contains: []string{"-- the data of age is not copied", `INSERT INTO users__rebuilt (id, name) SELECT id, name FROM users;`},
+			excludes: []string{"DROP COLUMN", "lint:allow"},
+			rows:     `SELECT group_concat(name) FROM users`,
+			expected: "ann",
+		},
+		"same definition is not a rename": {
+			from: []string{
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`,
+				`INSERT INTO users (name) VALUES ('ann')`,
+			},
+			to:       []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, nickname TEXT)`},
+			contains: []string{"-- the data of name is not copied", `INSERT INTO users__rebuilt (id) SELECT id FROM users;`},
+			excludes: []string{"RENAME COLUMN"},
+			rows:     `SELECT count(*) || count(nickname) FROM users`,
+			expected: "10",
+		},
+		"drop column": {
+			from:     []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER, CHECK (name != ''))`},
+			to:       []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, CHECK (name != ''))`},
+			opts:     generator.MigrationOptions{DropColumn: true},
+			contains: []string{`ALTER TABLE users DROP COLUMN age;`},
+			excludes: []string{"DROP TABLE"},
+			// DROP COLUMN was added in SQLite 3.35.0
+			skipApply: true,
+		},
+		"drop column in a constraint": {
+			from:     []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER CHECK (age > 0))`},
+			to:       []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT CHECK (name != ''))`},
+			opts:     generator.MigrationOptions{DropColumn: true},
+			contains: []string{`DROP TABLE users;`},
+			excludes: []string{"DROP COLUMN"},
+		},
+		"add unique column": {
+			from: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY)`},
+			to:   []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT UNIQUE)`},
+			// no data is lost, so the drop-table lint rule is allowed
+			contains: []string{`DROP TABLE users;`, "-- lint:allow drop-table"},
+			excludes: []string{"ADD COLUMN", "is not copied"},
+		},
+		"skip": {
+			from: []string{`CREATE TABLE tracking (version TEXT)`},
+			to:   []string{`CREATE TABLE tracking (version TEXT, applied TEXT)`},
+			opts: generator.MigrationOptions{Skip: []string{"tracking"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestMigrationSQL_badRenames(t *testing.T) {
+	from := newMigrationDB(t, "from.db", `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`, `CREATE TABLE posts (id INTEGER)`)
+	to := newMigrationDB(t, "to.db", `CREATE TABLE users (id INTEGER PRIMARY KEY, full_name TEXT)`, `CREATE TABLE posts (id INTEGER)`)
+	tests := map[string]map[string]string{
+		"old column not removed": {"users.id": "full_name"},
+		"new column not added":   {"users.name": "id"},
+		"unchanged table":        {"users.name": "full_name", "posts.id": "post_id"},
+		"missing table":          {"users.name": "full_name", "tags.name": "label"},
+	}
+	for name, renames := range tests {
+		renames := renames
+		t.Run(name, func(t *testing.T) {
+			if _, err := generator.MigrationSQL(from, to, generator.MigrationOptions{Renames: renames}); err == nil {
+				t.Errorf("migration sql, expected error got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Package textdiff provides a line based diff of two texts, for reporting the differences to a person.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change
+const Context = 2
+
+// Lines returns the lines of expected that are not in got, prefixed with "-", and the lines of got that are
+// not in expected, prefixed with "+", along with the unchanged lines around them; or "" if the texts are
+// the same.
+func Lines(expected, got string) string {
+	if expected == got {
+		return ""
+	}
+	a, b := strings.Split(expected, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	// only show the unchanged lines that are near a change
+	show := make([]bool, len(lines))
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for c := k - Context; c <= k+Context; c++ {
+			if c >= 0 && c < len(lines) {
+				show[c] = true
+			}
+		}
+	}
+	var diff strings.Builder
+	for k, l := range lines {
+		if !show[k] {
+			if k == 0 || show[k-1] {
+				diff.WriteString("...\n")
+			}
+			continue
+		}
+		fmt.Fprintf(&diff, "%c %v\n", l.op, l.text)
+	}
+	return diff.String()
+}
@@ -0,0 +1,33 @@
+package textdiff
+
+import "testing"
+
+func TestLines(t *testing.T) {
+	tests := map[string]struct {
+		expected string
+		got      string
+		diff     string
+	}{
+		"same": {
+			expected: "a\nb\n",
+			got:      "a\nb\n",
+		},
+		"changed": {
+			expected: "a\nb\nc\nd\ne\nf\ng",
+			got:      "a\nb\nc\nD\ne\nf\ng",
+			diff:     "...\n  b\n  c\n- d\n+ D\n  e\n  f\n...\n",
+		},
+		"added": {
+			expected: "a",
+			got:      "a\nb",
+			diff:     "  a\n+ b\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := Lines(tc.expected, tc.got); diff != tc.diff {
+				t.Errorf("diff,\n\texpected %q\n\t     got %q", tc.diff, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package migration
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdey/sqlite-migration/sqlparse"
+)
+
+// LintRule is a check for a risky pattern in a migration file
+type LintRule string
+
+const (
+	// LintDropTable reports DROP TABLE, which loses the data of the table
+	LintDropTable = LintRule("drop-table")
+	// LintDropColumn reports ALTER TABLE ... DROP COLUMN, which loses the data of the column
+	LintDropColumn = LintRule("drop-column")
+	// LintAddColumnNotNull reports ALTER TABLE ... ADD COLUMN ... NOT NULL without a default, which SQLite rejects
+	LintAddColumnNotNull = LintRule("add-column-not-null")
+	// LintCreateIndex reports CREATE INDEX, without IF NOT EXISTS, on a large table; building the index holds
+	// the write lock for a long time, and fails if the index was already created by hand
+	LintCreateIndex = LintRule("create-index")
+	// LintForeignKeysPragma reports PRAGMA foreign_keys inside a transaction, where it does nothing
+	LintForeignKeysPragma = LintRule("foreign-keys-pragma")
+	// LintSQLiteVersion reports features that are newer than the min_sqlite_version of the config file
+	LintSQLiteVersion = LintRule("sqlite-version")
+	// LintBeginTransaction reports a file that begins its own transaction, when each file is applied in a
+	// transaction (see Manager.SetTransactionPerFile); SQLite can not begin a transaction inside another, so
+	// the file fails to apply
+	LintBeginTransaction = LintRule("begin-transaction")
+
+	// LintAllowDirective, in a comment of a migration file, turns off lint rules for the file:
+	//   -- lint:allow drop-table, drop-column
+	LintAllowDirective = "lint:allow"
+
+	// DefaultLargeTableRows is the number of rows that make a table large, for the create-index rule
+	DefaultLargeTableRows = 100000
+)
+
+// LintRules are all the lint rules
+var LintRules = []LintRule{
+	LintDropTable,
+	LintDropColumn,
+	LintAddColumnNotNull,
+	LintCreateIndex,
+	LintForeignKeysPragma,
+	LintSQLiteVersion,
+	LintBeginTransaction,
+}
+
+// ParseLintRule returns the lint rule with the given name
+func ParseLintRule(name string) (LintRule, error) {
+	for _, rule := range LintRules {
+		if strings.EqualFold(string(rule), strings.TrimSpace(name)) {
+			return rule, nil
+		}
+	}
+	names := make([]string, len(LintRules))
+	for i, rule := range LintRules {
+		names[i] = string(rule)
+	}
+	return "", fmt.Errorf("unknown lint rule `%v`, expected one of %v", name, strings.Join(names, ", "))
+}
+
+// DefaultSeverity returns the severity of the rule, when it has not been configured
+func (rule LintRule) DefaultSeverity() LintSeverity {
+	switch rule {
+	case LintCreateIndex, LintForeignKeysPragma:
+		return LintWarning
+	default:
+		return LintError
+	}
+}
+
+// LintSeverity is how a lint rule is reported
+type LintSeverity string
+
+const (
+	// LintOff turns the rule off
+	LintOff = LintSeverity("off")
+	// LintWarning reports the problems of the rule, without failing the lint
+	LintWarning = LintSeverity("warning")
+	// LintError reports the problems of the rule, and fails the lint
+	LintError = LintSeverity("error")
+)
+
+// ParseLintSeverity returns the lint severity with the given name
+func ParseLintSeverity(name string) (LintSeverity, error) {
+	for _, severity := range []LintSeverity{LintOff, LintWarning, LintError} {
+		if strings.EqualFold(string(severity), strings.TrimSpace(name)) {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("unknown lint severity `%v`, expected one of off, warning, error", name)
+}
+
+// LintProblem is a risky pattern found in a migration file
+type LintProblem struct {
+	// Filename is the path, relative to the migration directory, of the file
+	Filename string
+	// Line is the line of the file the statement starts on
+	Line     int
+	Rule     LintRule
+	Severity LintSeverity
+	Message  string
+}
+
+func (problem LintProblem) String() string {
+	return fmt.Sprintf("%v:%d: %v: %v (%v)", problem.Filename, problem.Line, problem.Severity, problem.Message, problem.Rule)
+}
+
+// LintOptions are the options for Lint
+type LintOptions struct {
+	// Severities of the rules; these override the lint settings of the config file
+	Severities map[LintRule]LintSeverity
+	// LargeTableRows is the number of rows that make a table large, DefaultLargeTableRows if 0
+	LargeTableRows int64
+}
+
+// Lint checks the migration files for risky patterns. If db is nil every version, repeatable and background
+// file is checked; otherwise only the files that would be applied to the db. The create-index rule needs the
+// db, to know the size of the tables.
+//
+// The severity of each rule is its default, then the lint settings of the config file, then the options.
+// Rules can be turned off for a file with a `-- lint:allow rule, ...` comment in the file.
+func (mng *Manager) Lint(db Executor, opts LintOptions) ([]LintProblem, error) {
+	config, err := mng.Config()
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		if err = mng.verifyApplicationID(db, false); err != nil {
+			return nil, err
+		}
+	}
+	linter := linter{
+		mng:            mng,
+		db:             db,
+		severities:     make(map[LintRule]LintSeverity, len(LintRules)),
+		largeTableRows: opts.LargeTableRows,
+	}
+	if linter.largeTableRows <= 0 {
+		linter.largeTableRows = DefaultLargeTableRows
+	}
+	for _, rule := range LintRules {
+		linter.severities[rule] = rule.DefaultSeverity()
+	}
+	for _, severities := range []map[LintRule]LintSeverity{config.LintSeverities, opts.Severities} {
+		for rule, severity := range severities {
+			linter.severities[rule] = severity
+		}
+	}
+	if config.MinSQLiteVersion != "" {
+		linter.minVersion, _ = ParseSQLiteVersion(config.MinSQLiteVersion)
+	}
+
+	names, err := mng.lintFiles(db)
+	if err != nil {
+		return nil, err
+	}
+	var problems []LintProblem
+	for _, name := range names {
+		body, _, err := mng.renderSQLFile(filepath.Join(mng.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		fileProblems, err := linter.lint(name, string(body))
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, fileProblems...)
+	}
+	return problems, nil
+}
+
+// lintFiles returns the files to lint; all of them, or those that would be applied to the db
+func (mng *Manager) lintFiles(db Executor) (names []string, err error) {
+	if db != nil {
+		statuses, err := mng.Status(db)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			switch {
+			case status.State == StatePending, status.State == StateInProgress,
+				status.State == StateModified && status.Kind == TrackingKindRepeatable:
+				names = append(names, status.Version)
+			}
+		}
+		return names, nil
+	}
+	versions, err := mng.Versions()
+	if err != nil {
+		return nil, err
+	}
+	repeatables, err := mng.Repeatables()
+	if err != nil {
+		return nil, err
+	}
+	backgrounds, err := mng.Backgrounds()
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, versions[1:]...)
+	names = append(names, repeatables...)
+	return append(names, backgrounds...), nil
+}
+
+// sqliteFeatures are the features the sqlite-version rule knows about, and the version of SQLite they were added in
+var sqliteFeatures = []struct {
+	Feature string
+	Version string
+	Regexp  *regexp.Regexp
+}{
+	{"UPSERT (ON CONFLICT ... DO)", "3.24.0", regexp.MustCompile(`(?i)\bON CONFLICT\b.*\bDO (NOTHING|UPDATE)\b`)},
+	{"ALTER TABLE ... RENAME COLUMN", "3.25.0", regexp.MustCompile(`(?i)^ALTER TABLE \S+ RENAME (COLUMN )?\S+ TO\b`)},
+	{"window functions", "3.25.0", regexp.MustCompile(`(?i)\bOVER ?\(`)},
+	{"VACUUM INTO", "3.27.0", regexp.MustCompile(`(?i)^VACUUM (\S+ )?INTO\b`)},
+	{"generated columns", "3.31.0", regexp.MustCompile(`(?i)\bGENERATED ALWAYS\b`)},
+	{"iif()", "3.32.0", regexp.MustCompile(`(?i)\bIIF ?\(`)},
+	{"ALTER TABLE ... DROP COLUMN", "3.35.0", regexp.MustCompile(`(?i)^ALTER TABLE \S+ DROP\b`)},
+	{"RETURNING", "3.35.0", regexp.MustCompile(`(?i)\bRETURNING\b`)},
+	{"MATERIALIZED common table expressions", "3.35.0", regexp.MustCompile(`(?i)\bAS (NOT )?MATERIALIZED\b`)},
+	{"STRICT tables", "3.37.0", regexp.MustCompile(`(?i)^CREATE .*\)( WITHOUT ROWID ?,)? ?STRICT\b`)},
+	{"-> and ->> operators", "3.38.0", regexp.MustCompile(`->`)},
+	{"unixepoch()", "3.38.0", regexp.MustCompile(`(?i)\bUNIXEPOCH ?\(`)},
+}
+
+var (
+	lintDropTableRegexp   = regexp.MustCompile(`(?i)^DROP TABLE (IF EXISTS )?(\S+)`)
+	lintAlterTableRegexp  = regexp.MustCompile(`(?i)^ALTER TABLE (\S+) (.*)$`)
+	lintDropColumnRegexp  = regexp.MustCompile(`(?i)^DROP (COLUMN )?(\S+)`)
+	lintAddColumnRegexp   = regexp.MustCompile(`(?i)^ADD (COLUMN )?(\S+)(.*)$`)
+	lintNotNullRegexp     = regexp.MustCompile(`(?i)\bNOT NULL\b`)
+	lintDefaultRegexp     = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+	lintCreateIndexRegexp = regexp.MustCompile(`(?i)^CREATE (UNIQUE )?INDEX (IF NOT EXISTS )?(\S+) ON ([^ (]+)`)
+	lintForeignKeysRegexp = regexp.MustCompile(`(?i)^PRAGMA (\S+\.)?FOREIGN_KEYS\b`)
+	lintBeginRegexp       = regexp.MustCompile(`(?i)^BEGIN\b`)
+	lintEndRegexp         = regexp.MustCompile(`(?i)^(COMMIT|END|ROLLBACK)\b`)
+)
+
+// linter checks files against the lint rules
+type linter struct {
+	mng            *Manager
+	db             Executor
+	severities     map[LintRule]LintSeverity
+	minVersion     int
+	largeTableRows int64
+}
+
+// lint returns the problems of the file
+func (l linter) lint(name, body string) ([]LintProblem, error) {
+	statements, allowed, err := scanLintStatements(body)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	var (
+		problems []LintProblem
+		// inTransaction is true when the file has begun its own transaction
+		inTransaction bool
+		// ownTransaction is true when the file begins its own transaction anywhere
+		ownTransaction bool
+	)
+	for _, statement := range statements {
+		if lintBeginRegexp.MatchString(statement.Text) {
+			ownTransaction = true
+		}
+	}
+	report := func(statement lintStatement, rule LintRule, format string, args ...interface{}) {
+		severity := l.severities[rule]
+		if severity == LintOff || allowed[rule] {
+			return
+		}
+		problems = append(problems, LintProblem{
+			Filename: name,
+			Line:     statement.Line,
+			Rule:     rule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	for _, statement := range statements {
+		text := statement.Text
+		switch {
+		case lintBeginRegexp.MatchString(text):
+			if l.mng.TransactionPerFile() {
+				report(statement, LintBeginTransaction, "BEGIN fails, as each file is applied in a transaction of its own")
+			}
+			inTransaction = true
+		case lintEndRegexp.MatchString(text):
+			inTransaction = false
+		}
+
+		if match := lintDropTableRegexp.FindStringSubmatch(text); match != nil {
+			report(statement, LintDropTable, "DROP TABLE %v loses the data of the table", match[2])
+		}
+		if match := lintAlterTableRegexp.FindStringSubmatch(text); match != nil {
+			table, action := match[1], match[2]
+			if drop := lintDropColumnRegexp.FindStringSubmatch(action); drop != nil {
+				report(statement, LintDropColumn, "DROP COLUMN %v loses the data of the column of %v", drop[2], table)
+			}
+			if add := lintAddColumnRegexp.FindStringSubmatch(action); add != nil &&
+				lintNotNullRegexp.MatchString(add[3]) && !lintDefaultRegexp.MatchString(add[3]) {
+				report(statement, LintAddColumnNotNull, "ADD COLUMN %v to %v is NOT NULL without a DEFAULT, which SQLite rejects", add[2], table)
+			}
+		}
+		if match := lintCreateIndexRegexp.FindStringSubmatch(text); match != nil && match[2] == "" && l.db != nil {
+			table := unquoteIdentifier(match[4])
+			if i := strings.LastIndex(table, "."); i != -1 {
+				table = unquoteIdentifier(table[i+1:])
+			}
+			// the table may not exist yet, or be WITHOUT ROWID
+			if rows, err := l.mng.lastRowID(l.db, table); err == nil && rows >= l.largeTableRows {
+				report(statement, LintCreateIndex, "CREATE INDEX %v, without IF NOT EXISTS, on %v which has about %d rows", match[3], table, rows)
+			}
+		}
+		// a file that begins its own transaction is reported as failing to apply, with transactions per file
+		if lintForeignKeysRegexp.MatchString(text) && (inTransaction || (l.mng.TransactionPerFile() && !ownTransaction)) {
+			report(statement, LintForeignKeysPragma, "PRAGMA foreign_keys does nothing inside a transaction")
+		}
+		if l.minVersion != 0 {
+			for _, feature := range sqliteFeatures {
+				if version, _ := ParseSQLiteVersion(feature.Version); version > l.minVersion && feature.Regexp.MatchString(text) {
+					report(statement, LintSQLiteVersion, "%v needs SQLite %v", feature.Feature, feature.Version)
+				}
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems, nil
+}
+
+// lintStatement is a statement of a file, prepared for linting
+type lintStatement struct {
+	// Line is the line the statement starts on
+	Line int
+	// Text is the statement without its comments, or the contents of its strings, and with each run of
+	// whitespace replaced with a single space
+	Text string
+}
+
+// scanLintStatements splits the body into statements, and returns the rules allowed by the lint:allow
+// directives of its comments
+func scanLintStatements(body string) (statements []lintStatement, allowed map[LintRule]bool, err error) {
+	tokens, err := sqlparse.Tokenize(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	allowed = make(map[LintRule]bool)
+	for _, tok := range tokens {
+		if tok.Kind != sqlparse.TokenComment {
+			continue
+		}
+		comment := strings.TrimPrefix(tok.Text, "--")
+		if strings.HasPrefix(tok.Text, "/*") {
+			comment = strings.TrimSuffix(strings.TrimPrefix(tok.Text, "/*"), "*/")
+		}
+		if err = allowLintRules(comment, tok.Line, allowed); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	raw, err := sqlparse.SplitStatements(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, statement := range raw {
+		var (
+			text  strings.Builder
+			line  int
+			space bool
+		)
+		for _, tok := range statement.Tokens {
+			if tok.IsTrivia() {
+				space = true
+				continue
+			}
+			if line == 0 {
+				line = tok.Line
+			}
+			if space && text.Len() > 0 {
+				text.WriteByte(' ')
+			}
+			space = false
+			if tok.Kind == sqlparse.TokenString {
+				// the contents of strings are dropped, so that they are not mistaken for sql
+				text.WriteString("''")
+				continue
+			}
+			text.WriteString(tok.Text)
+		}
+		statements = append(statements, lintStatement{Line: line, Text: text.String()})
+	}
+	return statements, allowed, nil
+}
+
+// allowLintRules adds the rules of the lint:allow directive of the comment, if it has one
+func allowLintRules(comment string, line int, allowed map[LintRule]bool) error {
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, LintAllowDirective) {
+		return nil
+	}
+	for _, name := range strings.FieldsFunc(strings.TrimPrefix(comment, LintAllowDirective), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		rule, err := ParseLintRule(name)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		allowed[rule] = true
+	}
+	return nil
+}
+
+// unquoteIdentifier removes the quotes of the identifier
+func unquoteIdentifier(name string) string {
+	if len(name) < 2 {
+		return name
+	}
+	switch {
+	case name[0] == '"' && name[len(name)-1] == '"':
+		return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+	case name[0] == '`' && name[len(name)-1] == '`', name[0] == '[' && name[len(name)-1] == ']':
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// ParseSQLiteVersion returns the version as a number; e.g. 3.31.1 is 3031001
+func ParseSQLiteVersion(version string) (int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid SQLite version `%v`, expected major.minor[.patch]", version)
+	}
+	var number int
+	for i := 0; i < 3; i++ {
+		number *= 1000
+		if i >= len(parts) {
+			continue
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 || n >= 1000 {
+			return 0, fmt.Errorf("invalid SQLite version `%v`, expected major.minor[.patch]", version)
+		}
+		number += n
+	}
+	return number, nil
+}
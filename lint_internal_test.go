@@ -0,0 +1,118 @@
+package migration
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_Lint(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/config.txt": {Data: []byte("min_sqlite_version 3.31.0\nlint foreign-keys-pragma error\n")},
+		"migrations/sequence.txt": {Data: []byte(
+			"0001_users.sql\n0002_drop.sql\n0003_allowed.sql\n0004_index.sql\n0005_trigger.sql\n0006_rebuild.sql\n",
+		)},
+		"migrations/0001_users.sql": {Data: []byte(`CREATE TABLE users (name TEXT);
+INSERT INTO users (name) VALUES ('DROP TABLE users; -- not sql');
+`)},
+		"migrations/0002_drop.sql": {Data: []byte(`-- cleanup
+DROP TABLE IF EXISTS old_users;
+ALTER TABLE users
+  ADD COLUMN email TEXT NOT NULL;
+ALTER TABLE users ADD COLUMN age INTEGER NOT NULL DEFAULT 0;
+PRAGMA foreign_keys = OFF;
+`)},
+		"migrations/0003_allowed.sql": {Data: []byte(`/* lint:allow drop-table, drop-column */
+DROP TABLE users;
+ALTER TABLE users DROP COLUMN email;
+`)},
+		"migrations/0004_index.sql": {Data: []byte(`CREATE INDEX users_name ON "users" (name);
+CREATE INDEX IF NOT EXISTS users_age ON users (age);
+`)},
+		"migrations/0005_trigger.sql": {Data: []byte(`CREATE TRIGGER users_ai AFTER INSERT ON users BEGIN
+  UPDATE users SET name = CASE WHEN name = '' THEN NULL ELSE name END;
+  DROP TABLE x;
+END;
+SELECT name FROM users RETURNING name;
+`)},
+		"migrations/0006_rebuild.sql": {Data: []byte(`PRAGMA foreign_keys = OFF;
+BEGIN;
+CREATE TABLE users_rebuilt (name TEXT NOT NULL);
+COMMIT;
+PRAGMA foreign_keys = ON;
+`)},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+	// so that the PRAGMA foreign_keys of 0002_drop.sql is in a transaction, and 0006_rebuild.sql can not begin one
+	migrations.SetTransactionPerFile(true)
+
+	problems, err := migrations.Lint(nil, LintOptions{})
+	if err != nil {
+		t.Fatalf("lint error, expected nil got %v", err)
+	}
+	type problem struct {
+		Filename string
+		Line     int
+		Rule     LintRule
+		Severity LintSeverity
+	}
+	got := make([]problem, len(problems))
+	for i, p := range problems {
+		got[i] = problem{Filename: p.Filename, Line: p.Line, Rule: p.Rule, Severity: p.Severity}
+	}
+	expected := []problem{
+		{"0002_drop.sql", 2, LintDropTable, LintError},
+		{"0002_drop.sql", 3, LintAddColumnNotNull, LintError},
+		{"0002_drop.sql", 6, LintForeignKeysPragma, LintError},
+		{"0003_allowed.sql", 3, LintSQLiteVersion, LintError},
+		{"0005_trigger.sql", 5, LintSQLiteVersion, LintError},
+		{"0006_rebuild.sql", 2, LintBeginTransaction, LintError},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("problems,\n\texpected %+v\n\t     got %+v", expected, problems)
+	}
+
+	// the create-index rule needs the size of the table, and only the pending files are linted
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	t.Cleanup(cleanup)
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatalf("open error, expected nil got %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, _, err = migrations.UpgradeTo(db, "test", "0001_users.sql"); err != nil {
+		t.Fatalf("upgrade error, expected nil got %v", err)
+	}
+	if _, err = db.Exec(`INSERT INTO users (name) VALUES ('a'), ('b'), ('c');`); err != nil {
+		t.Fatalf("insert error, expected nil got %v", err)
+	}
+	problems, err = migrations.Lint(db, LintOptions{
+		Severities:     map[LintRule]LintSeverity{LintDropTable: LintOff, LintSQLiteVersion: LintWarning},
+		LargeTableRows: 3,
+	})
+	if err != nil {
+		t.Fatalf("lint error, expected nil got %v", err)
+	}
+	got = got[:0]
+	for _, p := range problems {
+		got = append(got, problem{Filename: p.Filename, Line: p.Line, Rule: p.Rule, Severity: p.Severity})
+	}
+	expected = []problem{
+		{"0002_drop.sql", 3, LintAddColumnNotNull, LintError},
+		{"0002_drop.sql", 6, LintForeignKeysPragma, LintError},
+		{"0003_allowed.sql", 3, LintSQLiteVersion, LintWarning},
+		{"0004_index.sql", 1, LintCreateIndex, LintWarning},
+		{"0005_trigger.sql", 5, LintSQLiteVersion, LintWarning},
+		{"0006_rebuild.sql", 2, LintBeginTransaction, LintError},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("pending problems,\n\texpected %+v\n\t     got %+v", expected, problems)
+	}
+
+	// unknown rules in a directive are an error
+	fsys["migrations/0005_trigger.sql"] = &fstest.MapFile{Data: []byte("-- lint:allow drop-everything\nSELECT 1;")}
+	if _, err = migrations.Lint(nil, LintOptions{}); err == nil {
+		t.Errorf("lint error, expected unknown rule got nil")
+	}
+}
@@ -0,0 +1,122 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaintenanceStep is a maintenance task that can be run after all the files of an upgrade have been applied
+type MaintenanceStep string
+
+const (
+	// MaintenanceOptimize runs PRAGMA optimize, which analyzes the tables that would benefit from it
+	MaintenanceOptimize = MaintenanceStep("optimize")
+	// MaintenanceAnalyze runs ANALYZE, gathering the statistics used by the query planner for all tables
+	MaintenanceAnalyze = MaintenanceStep("analyze")
+	// MaintenanceVacuum runs VACUUM, rebuilding the database file to reclaim the space of dropped objects.
+	// It can not be run in a transaction.
+	MaintenanceVacuum = MaintenanceStep("vacuum")
+	// MaintenanceIncrementalVacuum runs PRAGMA incremental_vacuum, which reclaims the free pages of a
+	// database with auto_vacuum set to incremental
+	MaintenanceIncrementalVacuum = MaintenanceStep("incremental_vacuum")
+	// MaintenanceCheckpoint runs PRAGMA wal_checkpoint(TRUNCATE), moving the write-ahead log into the
+	// database file and truncating the log
+	MaintenanceCheckpoint = MaintenanceStep("checkpoint")
+
+	// TrackingKindMaintenance is the kind of tracking entry recorded each time a maintenance step is run
+	TrackingKindMaintenance = "maintenance"
+)
+
+// MaintenanceSteps are all the maintenance steps, in the order they are run
+var MaintenanceSteps = []MaintenanceStep{
+	MaintenanceOptimize,
+	MaintenanceAnalyze,
+	MaintenanceVacuum,
+	MaintenanceIncrementalVacuum,
+	MaintenanceCheckpoint,
+}
+
+// ParseMaintenanceStep returns the maintenance step with the given name
+func ParseMaintenanceStep(name string) (MaintenanceStep, error) {
+	for _, step := range MaintenanceSteps {
+		if strings.EqualFold(string(step), strings.TrimSpace(name)) {
+			return step, nil
+		}
+	}
+	names := make([]string, len(MaintenanceSteps))
+	for i, step := range MaintenanceSteps {
+		names[i] = string(step)
+	}
+	return "", fmt.Errorf("unknown maintenance step `%v`, expected one of %v", name, strings.Join(names, ", "))
+}
+
+// sql returns the sql for the step on the given schema
+func (step MaintenanceStep) sql(schema string) string {
+	switch step {
+	case MaintenanceOptimize:
+		return fmt.Sprintf(`PRAGMA %v.optimize;`, schema)
+	case MaintenanceAnalyze:
+		return fmt.Sprintf(`ANALYZE %v;`, schema)
+	case MaintenanceVacuum:
+		return fmt.Sprintf(`VACUUM %v;`, schema)
+	case MaintenanceIncrementalVacuum:
+		return fmt.Sprintf(`PRAGMA %v.incremental_vacuum;`, schema)
+	case MaintenanceCheckpoint:
+		return fmt.Sprintf(`PRAGMA %v.wal_checkpoint(TRUNCATE);`, schema)
+	default:
+		return ""
+	}
+}
+
+// Maintenance returns the maintenance steps that are run after a successful upgrade
+func (mng *Manager) Maintenance() []MaintenanceStep {
+	if mng == nil {
+		return nil
+	}
+	return mng.maintenance
+}
+
+// SetMaintenance sets the maintenance steps that Upgrade runs after all the files have been applied. The steps
+// are run in the order of MaintenanceSteps, and each is recorded in the tracking table along with its duration.
+func (mng *Manager) SetMaintenance(steps ...MaintenanceStep) {
+	if mng == nil {
+		return
+	}
+	// a new slice, as the one returned by Maintenance may still be in use
+	maintenance := make([]MaintenanceStep, 0, len(steps))
+	for _, step := range MaintenanceSteps {
+		for _, selected := range steps {
+			if step == selected {
+				maintenance = append(maintenance, step)
+				break
+			}
+		}
+	}
+	mng.maintenance = maintenance
+}
+
+// runMaintenance will run the selected maintenance steps, recording each in the tracking table
+func (mng *Manager) runMaintenance(db Executor, author string) error {
+	for _, step := range mng.Maintenance() {
+		startT := time.Now()
+		// some of the pragmas do their work as their rows are read, so read all the rows
+		rows, err := db.QueryContext(context.Background(), step.sql(quoteIdentifier(mng.Schema())))
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Err()
+			rows.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("error running maintenance %v: %w", step, err)
+		}
+		duration := time.Now().Sub(startT).Seconds()
+		if err = mng.insertTrackingEntry(db, TrackingKindMaintenance, string(step), "", author, duration); err != nil {
+			return err
+		}
+		mng.Log().Printf("maintenance %v took %3.5fs", step, duration)
+	}
+	return nil
+}
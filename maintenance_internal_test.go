@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_Maintenance(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt": {Data: []byte("a.sql\n")},
+		"migrations/a.sql":        {Data: []byte("CREATE TABLE a ( name TEXT ); CREATE INDEX a_name ON a(name);")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+	migrations.SetMaintenance(MaintenanceVacuum, MaintenanceAnalyze, MaintenanceCheckpoint)
+	expected := []MaintenanceStep{MaintenanceAnalyze, MaintenanceVacuum, MaintenanceCheckpoint}
+	got := migrations.Maintenance()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("maintenance, expected %v got %v", expected, got)
+	}
+	// setting the steps again does not change the ones returned before
+	migrations.SetMaintenance(MaintenanceOptimize)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("returned maintenance, expected %v got %v", expected, got)
+	}
+	migrations.SetMaintenance(MaintenanceVacuum, MaintenanceAnalyze, MaintenanceCheckpoint)
+	if _, err := ParseMaintenanceStep("Optimize"); err != nil {
+		t.Errorf("parse optimize error, expected nil got %v", err)
+	}
+	if _, err := ParseMaintenanceStep("defrag"); err == nil {
+		t.Errorf("parse defrag error, expected error got nil")
+	}
+
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatalf("open error, expected nil got %v", err)
+	}
+	defer db.Close()
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Fatalf("upgrade error, expected nil got %v", err)
+	}
+
+	rows, err := db.Query(`SELECT file_path FROM gen_migrations WHERE kind = ? ORDER BY ROWID`, TrackingKindMaintenance)
+	if err != nil {
+		t.Fatalf("query error, expected nil got %v", err)
+	}
+	defer rows.Close()
+	var recorded []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatalf("scan error, expected nil got %v", err)
+		}
+		recorded = append(recorded, name)
+	}
+	if expected := []string{"analyze", "vacuum", "checkpoint"}; !reflect.DeepEqual(recorded, expected) {
+		t.Errorf("recorded, expected %v got %v", expected, recorded)
+	}
+	// ANALYZE creates the statistics table
+	var stats int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_stat1'`).Scan(&stats); err != nil || stats != 1 {
+		t.Errorf("sqlite_stat1, expected to exist got %v, %v", stats, err)
+	}
+	// maintenance steps are not reported as files
+	statuses, err := migrations.Status(db)
+	if err != nil {
+		t.Fatalf("status error, expected nil got %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Version != "a.sql" {
+		t.Errorf("status, expected only a.sql got %+v", statuses)
+	}
+}
@@ -1,19 +1,16 @@
 package migration
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"database/sql"
-	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
-	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -23,6 +20,8 @@ import (
 const (
 	// InitialVersion represents the value of the very first version, an empty database.
 	InitialVersion = ""
+	// DefaultSchema is the database schema that is migrated, unless another one is set with SetSchema
+	DefaultSchema = "main"
 )
 
 type osFS struct{}
@@ -41,21 +40,31 @@ func (nulLogger) Printf(_ string, _ ...interface{}) { return }
 
 // Manager will manage a set of migration file and apply them to the database
 type Manager struct {
-	tblName string
-	dir     string
-	fs      FSOpener
-	log     Logger
+	tblName         string
+	dir             string
+	fs              FSOpener
+	log             Logger
+	allowOutOfOrder bool
+	ordering        Ordering
+	environments    []string
+	schema          string
+	// transactionPerFile is set when sql files should be wrapped in a transaction
+	transactionPerFile bool
+	maintenance        []MaintenanceStep
+	syncUserVersion    bool
 }
 
+// FS returns the file system the migration files are read from. The getters do not set defaults, as a
+// Manager may be shared by goroutines (see UpgradeAll); New does.
 func (mng *Manager) FS() FSOpener {
 	if mng == nil || mng.fs == nil {
-		mng.fs = osFS{}
+		return osFS{}
 	}
 	return mng.fs
 }
 func (mng *Manager) Log() Logger {
 	if mng == nil || mng.log == nil {
-		mng.log = nulLogger{}
+		return nulLogger{}
 	}
 	return mng.log
 }
@@ -64,55 +73,62 @@ func (mng *Manager) SetLog(l Logger) {
 	if mng == nil {
 		return
 	}
+	if l == nil {
+		l = nulLogger{}
+	}
 	mng.log = l
 }
 
+// AllowOutOfOrder reports whether versions inserted into the sequence before the current version
+// of the database will be applied, instead of being reported as an ErrDivergence.
+func (mng *Manager) AllowOutOfOrder() bool { return mng != nil && mng.allowOutOfOrder }
+
+// SetAllowOutOfOrder sets whether versions inserted into the sequence before the current version
+// of a database should be applied. (e.g. after merging two branches)
+func (mng *Manager) SetAllowOutOfOrder(allow bool) {
+	if mng == nil {
+		return
+	}
+	mng.allowOutOfOrder = allow
+}
+
+// Ordering returns how the sql files, of a directory without a sequence file, are ordered
+func (mng *Manager) Ordering() Ordering {
+	if mng == nil {
+		return OrderBySequence
+	}
+	return mng.ordering
+}
+
+// SetOrdering sets how the sql files, of a directory without a sequence file, are ordered
+func (mng *Manager) SetOrdering(ordering Ordering) {
+	if mng == nil {
+		return
+	}
+	mng.ordering = ordering
+}
+
 func (mng *Manager) VersionFile() string {
 	var dir = "migrations"
 	if mng != nil {
 		dir = mng.dir
 	}
-	return filepath.Join(dir, "sequence.txt")
+	return filepath.Join(dir, SequenceFilename)
 }
 
+// Versions returns the ordered list of versions, the first entry is always the InitialVersion.
+// The versions are the paths, relative to the migration directory, of the sql files to apply.
 func (mng *Manager) Versions() ([]string, error) {
-
-	versionFile := mng.VersionFile()
-	if _, err := fs.Stat(mng.FS(), versionFile); errors.Is(err, fs.ErrNotExist) {
-		// There is no version file, so let's see if
-		// there is only one SQLFile, if so that is our version.
-		versionDir := path.Dir(versionFile)
-
-		entries, err := fs.ReadDir(mng.FS(), versionDir)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read %v: %w", versionDir, err)
-		}
-		var sqlFiles = []string{""} // always start with an empty database
-		for _, e := range entries {
-			if e.Type()&(fs.ModeDir|fs.ModeNamedPipe|fs.ModeSocket|fs.ModeDevice|fs.ModeCharDevice|fs.ModeIrregular) != 0 { // only care about regular files
-				continue
-			}
-			name := e.Name()
-			if strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".sql.tpl") {
-				sqlFiles = append(sqlFiles, name)
-			}
-		}
-		sort.Strings(sqlFiles)
-		vFile := path.Base(versionFile)
-		switch len(sqlFiles) {
-		case 1:
-			return nil, fmt.Errorf("directory %v does not contain %v or any sql files", versionDir, vFile)
-		case 2:
-			return sqlFiles, nil
-		default:
-			return nil, fmt.Errorf("directory %v does not contain %v to indicate order to apply sql files", versionDir, vFile)
-		}
+	entries, problems := mng.versionEntries()
+	if len(problems) != 0 {
+		return nil, problems[0]
 	}
-	// We already know the file exits
-	f, _ := mng.FS().Open(versionFile)
-	defer f.Close()
-
-	return getVersionsFromFile(f), nil
+	versions := make([]string, 0, len(entries)+1)
+	versions = append(versions, InitialVersion)
+	for _, entry := range entries {
+		versions = append(versions, entry.Name)
+	}
+	return versions, nil
 }
 
 func (mng *Manager) LatestVersion() (string, error) {
@@ -130,19 +146,51 @@ func (mng *Manager) TableName() string {
 	return mng.tblName
 }
 
-func (mng *Manager) HasTrackingTable(db *sql.DB) bool {
+// Schema returns the name of the database schema (main, or the name of an attached database) that is
+// migrated; the tracking table is kept in this schema.
+func (mng *Manager) Schema() string {
+	if mng == nil || mng.schema == "" {
+		return DefaultSchema
+	}
+	return mng.schema
+}
+
+// SetSchema sets the database schema to migrate. The database needs to be attached, on the connection
+// used to upgrade, before upgrading; as sql files are not rewritten they should use the .Schema template
+// value to qualify the objects they create, e.g. CREATE TABLE --{{ .Schema }}--.users ( ... );
+func (mng *Manager) SetSchema(name string) {
+	if mng == nil {
+		return
+	}
+	mng.schema = name
+}
+
+// trackingTable is the tracking table name, qualified by the schema, for use in sql statements
+func (mng *Manager) trackingTable() string {
+	if mng.Schema() == DefaultSchema {
+		return mng.TableName()
+	}
+	return quoteIdentifier(mng.Schema()) + "." + mng.TableName()
+}
+
+// quoteIdentifier will quote the name for use as an identifier in an sql statement
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (mng *Manager) HasTrackingTable(db Executor) bool {
 	const (
 		CountMigrationTableSQL = `
 SELECT COUNT(*)
-FROM sqlite_master
+FROM %[2]s.sqlite_master
 WHERE tbl_name = '%[1]s';
 `
 	)
 	count := 0
 
 	// let's check to see if our tables are there
-	sqlQuery := fmt.Sprintf(CountMigrationTableSQL, mng.TableName())
-	err := db.QueryRow(sqlQuery).Scan(&count)
+	sqlQuery := fmt.Sprintf(CountMigrationTableSQL, mng.TableName(), quoteIdentifier(mng.Schema()))
+	err := db.QueryRowContext(context.Background(), sqlQuery).Scan(&count)
 	if err != nil {
 		mng.Log().Printf("error running SQL\n%v\n%v", sqlQuery, err)
 		return false
@@ -152,8 +200,35 @@ WHERE tbl_name = '%[1]s';
 
 // Init will ensure that the initial tables for the file
 // correctly initialized, it returns the current database version
-func (mng *Manager) Init(db *sql.DB, author string) (ver string, didInit bool, err error) {
+func (mng *Manager) Init(db Executor, author string) (ver string, didInit bool, err error) {
+
+	if db == nil {
+		panic("db is nil")
+	}
+
+	didInit, err = mng.initTrackingTable(db)
+	if err != nil {
+		return "", false, err
+	}
+	if !didInit {
+		// get the current version of the db from the table
+		ver, err := mng.DBVersion(db)
+		if err != nil {
+			return ver, false, err
+		}
+		return ver, false, mng.checkUserVersion(db, ver)
+	}
+	dbVersion, err := mng.addTrackingEntry(db, author, "")
+	if err != nil {
+		return "", false, err
+	}
+	return dbVersion, true, nil
 
+}
+
+// initTrackingTable will create the tracking table if it does not exist, or add any missing columns
+// to it if it does. didCreate is true if the table was created.
+func (mng *Manager) initTrackingTable(db Executor) (didCreate bool, err error) {
 	const (
 		// MigrationsTableCreateSQL is used to create the basic table used to manage sql migrations
 		MigrationsTableCreateSQL = `
@@ -167,37 +242,115 @@ CREATE TABLE IF NOT EXISTS %[1]s (
 	`
 	)
 
-	if db == nil {
-		panic("db is nil")
+	if err = mng.verifyApplicationID(db, true); err != nil {
+		return false, err
 	}
-
 	if mng.HasTrackingTable(db) {
-		// Tracking table exists
-		// get the current version of the db from the table
-		ver, err := mng.DBVersion(db)
-		return ver, false, err
+		// Tracking table exists, but may have been created by an older version
+		return false, mng.upgradeTrackingTable(db)
 	}
 
 	// The tracking tables don't exist
 	// We need to add them.
-	sqlQuery := fmt.Sprintf(MigrationsTableCreateSQL, mng.TableName())
-	if _, err = db.Exec(sqlQuery); err != nil {
+	sqlQuery := fmt.Sprintf(MigrationsTableCreateSQL, mng.trackingTable())
+	if _, err = db.ExecContext(context.Background(), sqlQuery); err != nil {
 		mng.Log().Printf("Error running sql:\n%s", sqlQuery)
-		return "", false, ErrCreateTable{
+		return false, ErrCreateTable{
 			Err:       err,
 			TableName: mng.TableName(),
 		}
 	}
-	dbVersion, err := mng.addTrackingEntry(db, author, InitialVersion)
+	return true, mng.upgradeTrackingTable(db)
+}
+
+// trackingColumns are the columns that have been added to the tracking table since it was first
+// introduced; they are added to existing tracking tables by Init.
+var trackingColumns = []struct {
+	Name       string
+	Definition string
+	// Default is the value of the column for the entries recorded before it was added
+	Default string
+}{
+	{Name: "kind", Definition: "TEXT NOT NULL DEFAULT '" + TrackingKindVersion + "'", Default: "'" + TrackingKindVersion + "'"},
+	// environments are the active environments when the entry was recorded
+	{Name: "environments", Definition: "TEXT NOT NULL DEFAULT ''", Default: "''"},
+	// cursor is the rowid the next batch of a background file starts at
+	{Name: "cursor", Definition: "INTEGER NOT NULL DEFAULT 0", Default: "0"},
+	// completed_at is set once all the batches of a background file have been run
+	{Name: "completed_at", Definition: "TEXT NOT NULL DEFAULT ''", Default: "''"},
+	// last_rowid is the largest rowid of the table of a background file, when the file was started
+	{Name: "last_rowid", Definition: "INTEGER", Default: "NULL"},
+}
+
+// trackingTableColumns returns the columns the tracking table has
+func (mng *Manager) trackingTableColumns(db Executor) (map[string]bool, error) {
+	rows, err := db.QueryContext(context.Background(), fmt.Sprintf(`pragma %v.table_info( %v );`, quoteIdentifier(mng.Schema()), mng.TableName()))
 	if err != nil {
-		return "", false, err
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull bool
+			dValue  interface{}
+			pk      int
+		)
+		if err = rows.Scan(&cid, &name, &typ, &notNull, &dValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
 	}
-	return dbVersion, true, nil
+	return columns, rows.Err()
+}
+
+// trackingSource returns the tracking table to read the entries from. A tracking table created by an older
+// version, and not upgraded by Init since, is missing the newer tracking columns; they are read as their
+// defaults, so reading a database does not need, or make, an upgrade.
+func (mng *Manager) trackingSource(db Executor) (string, error) {
+	columns, err := mng.trackingTableColumns(db)
+	if err != nil {
+		return "", ErrTrackingRead{Err: err, TableName: mng.TableName()}
+	}
+	var missing []string
+	for _, column := range trackingColumns {
+		if !columns[column.Name] {
+			missing = append(missing, column.Default+" AS "+column.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return mng.trackingTable(), nil
+	}
+	return fmt.Sprintf("(SELECT ROWID AS ROWID, *, %v FROM %v)", strings.Join(missing, ", "), mng.trackingTable()), nil
+}
 
+// upgradeTrackingTable will add any missing columns to the tracking table
+func (mng *Manager) upgradeTrackingTable(db Executor) error {
+	const (
+		AddColumnSQL = `ALTER TABLE %s ADD COLUMN %s %s;`
+	)
+	columns, err := mng.trackingTableColumns(db)
+	if err != nil {
+		return ErrCreateTable{Err: err, TableName: mng.TableName()}
+	}
+	for _, column := range trackingColumns {
+		if columns[column.Name] {
+			continue
+		}
+		sqlQuery := fmt.Sprintf(AddColumnSQL, mng.trackingTable(), column.Name, column.Definition)
+		if _, err = db.ExecContext(context.Background(), sqlQuery); err != nil {
+			mng.Log().Printf("Error running sql:\n%s", sqlQuery)
+			return ErrCreateTable{Err: err, TableName: mng.TableName()}
+		}
+	}
+	return nil
 }
 
-// Upgrade will upgrade the db file to the latest schema
-func (mng *Manager) Upgrade(db *sql.DB, author string) (startingVersion string, newVersion string, err error) {
+// Upgrade will upgrade the db file to the latest schema, and then run the maintenance steps set with SetMaintenance
+func (mng *Manager) Upgrade(db Executor, author string) (startingVersion string, newVersion string, err error) {
 
 	var didInit bool
 	// insure the database is correctly initialized
@@ -207,114 +360,430 @@ func (mng *Manager) Upgrade(db *sql.DB, author string) (startingVersion string,
 	}
 	// we initialize the db, which means it's a new database, let's return "" for starting version
 	// Upgrade to the latest version
-	newVersion, err = mng.addTrackingEntry(db, author, startingVersion)
+	newVersion, err = mng.addTrackingEntry(db, author, "")
+	if err == nil {
+		err = mng.runMaintenance(db, author)
+	}
 	if didInit {
 		return "", newVersion, err
 	}
 	return startingVersion, newVersion, err
 }
 
+// UpgradeTo will upgrade the db file up to, and including, the given version. Repeatable files are
+// not applied, even when the given version is the latest one; they are only applied by Upgrade, after all versions.
+func (mng *Manager) UpgradeTo(db Executor, author, version string) (startingVersion string, newVersion string, err error) {
+	if db == nil {
+		panic("db is nil")
+	}
+	didInit, err := mng.initTrackingTable(db)
+	if err != nil {
+		return "", "", err
+	}
+	if !didInit {
+		if startingVersion, err = mng.DBVersion(db); err != nil {
+			return startingVersion, "", err
+		}
+		if err = mng.checkUserVersion(db, startingVersion); err != nil {
+			return startingVersion, "", err
+		}
+	}
+	if version == InitialVersion {
+		return startingVersion, startingVersion, nil
+	}
+	newVersion, err = mng.addTrackingEntry(db, author, version)
+	return startingVersion, newVersion, err
+}
+
 // DBVersion returns the version of migration in the given db
-func (mng *Manager) DBVersion(db *sql.DB) (string, error) {
+func (mng *Manager) DBVersion(db Executor) (string, error) {
+	if err := mng.verifyApplicationID(db, false); err != nil {
+		return InitialVersion, err
+	}
+	tracked, err := mng.trackedVersions(db)
+	if err != nil {
+		return InitialVersion, err
+	}
+	entries, problems := mng.versionEntries()
+	if len(problems) != 0 {
+		// without the sequence, the best we can do is the last applied version
+		return currentVersion(trackedNames(tracked), nil), nil
+	}
+	if tracked, err = resolveBaselines(tracked, entries); err != nil {
+		return InitialVersion, err
+	}
+	return currentVersion(trackedNames(tracked), entryNames(entries)), nil
+}
+
+func entryNames(entries []versionEntry) []string {
+	names := make([]string, len(entries))
+	for i := range entries {
+		names[i] = entries[i].Name
+	}
+	return names
+}
+
+// History returns the versions that have been applied to the given db, in the order they were applied
+func (mng *Manager) History(db Executor) ([]string, error) {
+	if err := mng.verifyApplicationID(db, false); err != nil {
+		return nil, err
+	}
+	tracked, err := mng.trackedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	var history []string
+	for _, version := range tracked {
+		if version.Kind == TrackingKindVersion {
+			history = append(history, version.Name)
+		}
+	}
+	return history, nil
+}
+
+// trackedVersion is a version that has either been applied or skipped
+type trackedVersion struct {
+	Name string
+	Kind string
+}
+
+// trackedVersions returns the versions that have been applied to, or skipped for, the given db in the order
+// they were recorded.
+func (mng *Manager) trackedVersions(db Executor) ([]trackedVersion, error) {
 	const (
-		SelectLatestVersionSQL = `
-	SELECT file_path AS file
+		SelectTrackedSQL = `
+	SELECT file_path AS file, kind
 	FROM %s
-	ORDER by ROWID desc
-	LIMIT 1;
+	WHERE kind IN (?, ?)
+	ORDER by ROWID asc;
 	`
 	)
-	var (
-		selectSQL = fmt.Sprintf(SelectLatestVersionSQL, mng.tblName)
-		dbVersion string
-		err       = db.QueryRow(selectSQL).Scan(&dbVersion)
-	)
-	if errors.Is(err, sql.ErrNoRows) {
-		return InitialVersion, nil
+	source, err := mng.trackingSource(db)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(context.Background(), fmt.Sprintf(SelectTrackedSQL, source), TrackingKindVersion, TrackingKindSkipped)
+	if err != nil {
+		return nil, ErrTrackingRead{Err: err, TableName: mng.TableName()}
+	}
+	defer rows.Close()
+	var tracked []trackedVersion
+	for rows.Next() {
+		var version trackedVersion
+		if err = rows.Scan(&version.Name, &version.Kind); err != nil {
+			return nil, err
+		}
+		tracked = append(tracked, version)
 	}
-	return dbVersion, err
+	return tracked, rows.Err()
 }
 
-// addTrackingEntry will add the sql file management entries into the lis_migration table
-func (mng *Manager) addTrackingEntry(db *sql.DB, author, initialVersion string) (string, error) {
+func trackedNames(tracked []trackedVersion) []string {
+	names := make([]string, len(tracked))
+	for i := range tracked {
+		names[i] = tracked[i].Name
+	}
+	return names
+}
 
-	const (
-		InsertMigrationSQL = `
-	INSERT INTO %s (file_path,file_hash, author,duration,created_at)
-	VALUES (?,?,?,?,datetime('now'));
-	`
+// currentVersion returns the version the database is at given the applied (or skipped) history.
+// This is the version that is furthest along in the sequence, unless the last recorded version
+// is not in the sequence, in which case that unknown version is returned.
+func currentVersion(history, versions []string) string {
+	if len(history) == 0 {
+		return InitialVersion
+	}
+	var (
+		last    = history[len(history)-1]
+		applied = make(map[string]bool, len(history))
 	)
-
-	versions, err := mng.Versions()
-	if err != nil {
-		return "", err
+	for _, version := range history {
+		applied[version] = true
+	}
+	current, found := InitialVersion, false
+	for _, version := range versions {
+		if applied[version] {
+			current = version
+		}
+		if version == last {
+			found = true
+		}
+	}
+	if !found {
+		return last
 	}
+	return current
+}
 
+// pendingVersion is a version that still needs to be applied, or recorded as skipped
+type pendingVersion struct {
+	Name string
+	// Skip is true if the version is not for the active environments
+	Skip bool
+}
+
+// pendingVersions will compare the tracked history to the sequence, returning the versions that still need
+// to be applied (or skipped). If the history is not the start of the sequence, an ErrDivergence is returned; unless
+// out of order upgrades are allowed, and no applied versions have been removed from the sequence. In which
+// case the versions that were inserted are applied, in sequence order, along with the rest of the pending versions.
+//
+// Versions that were skipped, because they were not for the active environments at the time, and now are
+// for the active environments are treated like inserted versions.
+func (mng *Manager) pendingVersions(tracked []trackedVersion, entries []versionEntry) ([]pendingVersion, error) {
 	var (
-		i       int
-		version string
-		found   bool
+		applied    = make(map[string]bool, len(tracked))
+		skipped    = make(map[string]bool)
+		inSequence = make(map[string]int, len(entries)+1)
+		versions   = make([]string, 0, len(entries)+1)
+		history    []string
+		lastIndex  = 0
+		divergence ErrDivergence
+		pending    []pendingVersion
 	)
+	versions = append(versions, InitialVersion)
+	for _, entry := range entries {
+		versions = append(versions, entry.Name)
+	}
+	for i, version := range versions {
+		inSequence[version] = i
+	}
+	for _, version := range tracked {
+		i, ok := inSequence[version.Name]
+		if version.Kind == TrackingKindSkipped {
+			skipped[version.Name] = true
+		} else {
+			applied[version.Name] = true
+			history = append(history, version.Name)
+			if !ok {
+				divergence.Removed = append(divergence.Removed, version.Name)
+			}
+		}
+		if ok && i > lastIndex {
+			lastIndex = i
+		}
+	}
+	for i, entry := range entries {
+		if applied[entry.Name] {
+			continue
+		}
+		if !mng.appliesTo(entry.Environments) {
+			if !skipped[entry.Name] {
+				pending = append(pending, pendingVersion{Name: entry.Name, Skip: true})
+			}
+			continue
+		}
+		switch {
+		case skipped[entry.Name]:
+			divergence.Skipped = append(divergence.Skipped, entry.Name)
+		case i+1 < lastIndex:
+			divergence.Inserted = append(divergence.Inserted, entry.Name)
+		}
+		pending = append(pending, pendingVersion{Name: entry.Name})
+	}
+	divergence.Reordered = reorderedVersions(history, versions, applied, inSequence)
 
-	for i, version = range versions {
-		if version == initialVersion {
-			found = true
-			break
+	if len(divergence.Inserted) == 0 && len(divergence.Skipped) == 0 &&
+		len(divergence.Removed) == 0 && len(divergence.Reordered) == 0 {
+		return pending, nil
+	}
+	if len(divergence.Removed) == 0 && mng.AllowOutOfOrder() {
+		if outOfOrder := append(divergence.Skipped, divergence.Inserted...); len(outOfOrder) != 0 {
+			mng.Log().Printf("applying versions out of order: %v", strings.Join(outOfOrder, ", "))
 		}
+		return pending, nil
 	}
+	divergence.Version = tracked[len(tracked)-1].Name
+	return nil, divergence
+}
 
-	// The version of the database is not in our set of
-	// migrations files. This is weird, lets error.
-	if !found {
-		// do nothing.
-		return initialVersion, ErrUnknownVersion(initialVersion)
+// reorderedVersions returns the applied versions whose relative order differs from the sequence. These are the
+// versions that are not part of the longest common subsequence of the history and the sequence.
+func reorderedVersions(history, versions []string, applied map[string]bool, inSequence map[string]int) (reordered []string) {
+	var hist, seq []string
+	for _, version := range history {
+		if _, ok := inSequence[version]; ok {
+			hist = append(hist, version)
+		}
+	}
+	for _, version := range versions {
+		if applied[version] {
+			seq = append(seq, version)
+		}
+	}
+	// lcs[i][j] is the length of the longest common subsequence of hist[i:] and seq[j:]
+	lcs := make([][]int, len(hist)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(seq)+1)
+	}
+	for i := len(hist) - 1; i >= 0; i-- {
+		for j := len(seq) - 1; j >= 0; j-- {
+			switch {
+			case hist[i] == seq[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(hist); {
+		switch {
+		case j < len(seq) && hist[i] == seq[j]:
+			i++
+			j++
+		case j < len(seq) && lcs[i][j+1] > lcs[i+1][j]:
+			j++
+		default:
+			reordered = append(reordered, hist[i])
+			i++
+		}
 	}
+	return reordered
+}
 
-	i++ // move to the next version
-	if i >= len(versions) {
-		// database it already at the latest version
-		return initialVersion, nil
+// addTrackingEntry will apply the pending sql files, up to and including through (or all of them if through is empty),
+// adding the management entries into the tracking table
+func (mng *Manager) addTrackingEntry(db Executor, author string, through string) (string, error) {
+
+	entries, problems := mng.versionEntries()
+	if len(problems) != 0 {
+		return "", problems[0]
+	}
+	versions := entryNames(entries)
+	tracked, err := mng.trackedVersions(db)
+	if err != nil {
+		return "", err
+	}
+	if tracked, err = resolveBaselines(tracked, entries); err != nil {
+		return "", err
+	}
+	history := trackedNames(tracked)
+	current := currentVersion(history, versions)
+
+	pending, err := mng.pendingVersions(tracked, entries)
+	if err != nil {
+		return current, err
+	}
+	var repeatables []string
+	if through == "" {
+		if repeatables, err = mng.Repeatables(); err != nil {
+			return current, err
+		}
+	} else {
+		if pending, err = pendingThrough(pending, versions, through); err != nil {
+			return current, err
+		}
 	}
 
 	// get the max length of the versions
-	var maxLength = utf8.RuneCountInString(versions[i])
-	for j := i + 1; j < len(versions); j++ {
-		if len(versions[j]) > maxLength {
-			maxLength = utf8.RuneCountInString(versions[j])
+	var maxLength = 0
+	for _, version := range pending {
+		if l := utf8.RuneCountInString(version.Name); l > maxLength {
+			maxLength = l
+		}
+	}
+	for _, version := range repeatables {
+		if l := utf8.RuneCountInString(version); l > maxLength {
+			maxLength = l
 		}
 	}
 
 	// Now we need to apply the remaining versions to the database
-	for ; i < len(versions); i++ {
-		startT := time.Now()
-		migrationFilename := filepath.Join(mng.dir, versions[i])
-		hash, err := mng.applySQLFile(db, migrationFilename)
-		if err != nil {
-			return InitialVersion, fmt.Errorf("error applying SQL file: %v : %w", migrationFilename, err)
-		}
-		duration := time.Now().Sub(startT).Seconds()
-		sqlQuery := fmt.Sprintf(InsertMigrationSQL, mng.tblName)
-		_, err = db.Exec(sqlQuery,
-			versions[i],
-			hash,
-			author,
-			duration,
+	for _, version := range pending {
+		if version.Skip {
+			if err = mng.insertTrackingEntry(db, TrackingKindSkipped, version.Name, "", author, 0); err != nil {
+				return "", err
+			}
+			mng.Log().Printf("SQL file %-*s skipped, not for environments %v", maxLength, version.Name, mng.Environments())
+			history = append(history, version.Name)
+			if err = mng.syncUserVersionTo(db, entries, currentVersion(history, versions)); err != nil {
+				return "", err
+			}
+			continue
+		}
+		var (
+			startT            = time.Now()
+			migrationFilename = filepath.Join(mng.dir, version.Name)
+			duration          float64
 		)
-		if err != nil {
-			mng.Log().Printf("Error running sqlQuery:\n%s", sqlQuery)
-			return "", ErrTrackingInfo{
-				Err:       err,
-				TableName: mng.tblName,
+		err = mng.inTransaction(db, func(exec Executor) error {
+			hash, err := mng.applySQLFile(exec, migrationFilename)
+			if err != nil {
+				return fmt.Errorf("error applying SQL file: %v : %w", migrationFilename, err)
+			}
+			duration = time.Now().Sub(startT).Seconds()
+			if err = mng.insertTrackingEntry(exec, TrackingKindVersion, version.Name, hash, author, duration); err != nil {
+				return err
 			}
+			return mng.syncUserVersionTo(exec, entries, currentVersion(append(history, version.Name), versions))
+		})
+		if err != nil {
+			return InitialVersion, err
+		}
+		mng.Log().Printf("SQL file %-*s took %3.5fs to apply", maxLength, version.Name, duration)
+		history = append(history, version.Name)
+	}
+	current = currentVersion(history, versions)
+
+	// Now that all the versions have been applied, re-apply any repeatable files that have changed
+	for _, repeatable := range repeatables {
+		if err = mng.applyRepeatable(db, author, repeatable, maxLength); err != nil {
+			return current, err
 		}
-		mng.Log().Printf("SQL file %-*s took %3.5fs to apply", maxLength, versions[i], duration)
 	}
 
-	return versions[i-1], nil
+	return current, nil
+
+}
 
+// pendingThrough will return the pending versions that are at or before the through version in the sequence
+func pendingThrough(pending []pendingVersion, versions []string, through string) ([]pendingVersion, error) {
+	position := make(map[string]int, len(versions))
+	for i, version := range versions {
+		position[version] = i
+	}
+	last, ok := position[through]
+	if !ok {
+		return nil, ErrUnknownVersion(through)
+	}
+	var filtered []pendingVersion
+	for _, version := range pending {
+		if position[version.Name] <= last {
+			filtered = append(filtered, version)
+		}
+	}
+	return filtered, nil
+}
+
+// insertTrackingEntry will record an entry of the given kind into the tracking table
+func (mng *Manager) insertTrackingEntry(db Executor, kind, filePath, hash, author string, duration float64) error {
+	const (
+		InsertMigrationSQL = `
+	INSERT INTO %s (file_path,file_hash, author,duration,created_at,kind,environments)
+	VALUES (?,?,?,?,datetime('now'),?,?);
+	`
+	)
+	sqlQuery := fmt.Sprintf(InsertMigrationSQL, mng.trackingTable())
+	_, err := db.ExecContext(context.Background(), sqlQuery,
+		filePath,
+		hash,
+		author,
+		duration,
+		kind,
+		strings.Join(mng.Environments(), ","),
+	)
+	if err != nil {
+		mng.Log().Printf("Error running sqlQuery:\n%s", sqlQuery)
+		return ErrTrackingInfo{
+			Err:       err,
+			TableName: mng.TableName(),
+		}
+	}
+	return nil
 }
 
-func renderSQLTPL(filename string, body []byte, tplFuncMap template.FuncMap) ([]byte, error) {
+func renderSQLTPL(filename string, body []byte, tplFuncMap template.FuncMap, schema string) ([]byte, error) {
 
 	tmpl, err := template.New(filename).
 		Delims("--{{", "}}--").
@@ -334,7 +803,9 @@ func renderSQLTPL(filename string, body []byte, tplFuncMap template.FuncMap) ([]
 	err = tmpl.Execute(&sqlBody, struct {
 		Filename string
 		Sha1Hash string
-	}{Filename: filename, Sha1Hash: sha1Hash})
+		// Schema is the database schema being migrated
+		Schema string
+	}{Filename: filename, Sha1Hash: sha1Hash, Schema: schema})
 	if err != nil {
 		return []byte{}, fmt.Errorf("error executing template %v: %v", filename, err)
 	}
@@ -344,7 +815,7 @@ func renderSQLTPL(filename string, body []byte, tplFuncMap template.FuncMap) ([]
 // loadPartial will load the partial from the "partial" directory in the
 // mng.dir dir, and return the contents it.
 func (mng *Manager) loadPartial(name string) (string, error) {
-	filename := filepath.Join(mng.dir, "partial", name)
+	filename := filepath.Join(mng.dir, PartialDir, name)
 	body, err := mng.readAllFile(filename)
 	if err != nil {
 		return "", err
@@ -375,19 +846,20 @@ func (mng *Manager) readAllFile(filename string) ([]byte, error) {
 	return body, nil
 }
 
-// applySQLFile will apply the given sql file to the db file
-func (mng *Manager) applySQLFile(db *sql.DB, filename string) (string, error) {
+// renderSQLFile will read, and render if it is a template, the given sql file; returning the body
+// and the hash of the body.
+func (mng *Manager) renderSQLFile(filename string) ([]byte, string, error) {
 
 	body, err := mng.readAllFile(filename)
 	if err != nil {
-		return "", ErrApplyFileRead{Err: err, Filename: filename}
+		return nil, "", ErrApplyFileRead{Err: err, Filename: filename}
 	}
 
 	// check to see if the filename is a template
 	if strings.HasSuffix(filename, "tpl") {
 		// we are going to treat the body as a template.
-		if body, err = renderSQLTPL(filename, body, mng.FuncMap()); err != nil {
-			return "", ErrApplyFileTemplate{Err: err, Filename: filename}
+		if body, err = renderSQLTPL(filename, body, mng.FuncMap(), mng.Schema()); err != nil {
+			return nil, "", ErrApplyFileTemplate{Err: err, Filename: filename}
 		}
 	}
 
@@ -395,19 +867,79 @@ func (mng *Manager) applySQLFile(db *sql.DB, filename string) (string, error) {
 	h.Write(body)
 	sum := h.Sum(nil)
 	sha1Hash := fmt.Sprintf("{sha1}%x", sum)
+	return body, sha1Hash, nil
+}
+
+// applySQLFile will apply the given sql file to the db file
+func (mng *Manager) applySQLFile(db Executor, filename string) (string, error) {
 
-	_, err = db.Exec(string(body))
+	body, sha1Hash, err := mng.renderSQLFile(filename)
 	if err != nil {
-		mng.Log().Printf("Error running sql:\n%s", body)
-		return "", ErrApplyFile{Err: err, Sha1Hash: sha1Hash, Filename: filename}
+		return "", err
 	}
+	return sha1Hash, mng.execSQL(db, filename, body, sha1Hash)
+
+}
 
-	return sha1Hash, nil
+// execSQL will run the rendered body of the given sql file against the db
+func (mng *Manager) execSQL(db Executor, filename string, body []byte, sha1Hash string) error {
+	ownTransaction, setsForeignKeys := connectionState(string(body))
+	if pool, ok := db.(*sql.DB); ok && (ownTransaction || setsForeignKeys) {
+		// the transaction the file begins has to be rolled back, and the foreign_keys it turns off turned back
+		// on, if the file fails, on the same connection
+		conn, err := pool.Conn(context.Background())
+		if err != nil {
+			return ErrApplyFile{Err: err, Sha1Hash: sha1Hash, Filename: filename}
+		}
+		defer conn.Close()
+		db = conn
+	}
+	_, onConn := db.(*sql.Conn)
+	var foreignKeys int
+	if onConn && setsForeignKeys {
+		if err := db.QueryRowContext(context.Background(), `PRAGMA foreign_keys;`).Scan(&foreignKeys); err != nil {
+			return ErrApplyFile{Err: err, Sha1Hash: sha1Hash, Filename: filename}
+		}
+	}
+	_, err := db.ExecContext(context.Background(), string(body))
+	if err != nil {
+		mng.Log().Printf("Error running sql:\n%s", body)
+		if onConn && ownTransaction {
+			// fails if the file did not get as far as beginning its transaction
+			_, _ = db.ExecContext(context.Background(), "ROLLBACK;")
+		}
+		if onConn && setsForeignKeys {
+			// the connection goes back to the pool
+			_, _ = db.ExecContext(context.Background(), fmt.Sprintf(`PRAGMA foreign_keys = %d;`, foreignKeys))
+		}
+		return ErrApplyFile{Err: err, Sha1Hash: sha1Hash, Filename: filename}
+	}
+	return nil
+}
 
+// connectionState returns whether the body of an sql file begins its own transaction, and whether it sets
+// PRAGMA foreign_keys; both outlast the file on the connection it is run on.
+func connectionState(body string) (beginsTransaction, setsForeignKeys bool) {
+	statements, _, err := scanLintStatements(body)
+	if err != nil {
+		return false, false
+	}
+	for _, statement := range statements {
+		if lintBeginRegexp.MatchString(statement.Text) {
+			beginsTransaction = true
+		}
+		if lintForeignKeysRegexp.MatchString(statement.Text) {
+			setsForeignKeys = true
+		}
+	}
+	return beginsTransaction, setsForeignKeys
 }
 
-// New returns a new manager
+// New returns a new manager, the migration files are read from the os if fs is nil
 func New(dir, tableName string, fs FSOpener) *Manager {
+	if fs == nil {
+		fs = osFS{}
+	}
 	return &Manager{
 		tblName: tableName,
 		dir:     dir,
@@ -427,14 +959,8 @@ func getVersionsFromFile(f io.Reader) []string {
 		l = []string{InitialVersion}
 	)
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
-		if line == "" || line[0] == '#' {
-			continue
-		}
-		l = append(l, line)
+	for _, line := range readSequenceLines(f) {
+		l = append(l, line.Text)
 	}
 
 	return l
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"testing/fstest"
 
 	// we only work with sqlite
 	_ "github.com/mattn/go-sqlite3"
@@ -80,6 +81,8 @@ func TestMigration_Update(t *testing.T) {
 		dbName   string
 		testDir  string
 		genTable string
+		// allowOutOfOrder will be passed to SetAllowOutOfOrder
+		allowOutOfOrder bool
 
 		Versions []string
 		VerErr   error
@@ -102,6 +105,7 @@ func TestMigration_Update(t *testing.T) {
 				genTable,
 				testdataFS,
 			)
+			migrations.SetAllowOutOfOrder(tc.allowOutOfOrder)
 			versions, err := migrations.Versions()
 			if tc.VerErr != nil {
 				if !errors.Is(err, tc.VerErr) {
@@ -167,6 +171,20 @@ func TestMigration_Update(t *testing.T) {
 			Start:    "simpletable.sql",
 			End:      "simpletable.sql",
 		},
+		"sequence_include": {
+			Versions: []string{"", "base.sql", "more/extra.sql", "users/create.sql", "users/indexes.sql"},
+			Start:    "",
+			End:      "users/indexes.sql",
+		},
+		"upgrade_out_of_order": {
+			allowOutOfOrder: true,
+			Versions:        []string{"", "simpletable.sql", "simple_table2.sql", "simple_table3.sql"},
+			Start:           "simple_table3.sql",
+			End:             "simple_table3.sql",
+		},
+		"sequence_duplicate": {
+			VerErr: ErrDuplicateVersion("simpletable.sql"),
+		},
 	}
 	for name, tc := range tests {
 		if tc.testDir == "" {
@@ -176,3 +194,226 @@ func TestMigration_Update(t *testing.T) {
 	}
 
 }
+
+func TestManager_pendingVersions(t *testing.T) {
+	type tcase struct {
+		history []string
+		// skipped are recorded after the history
+		skipped         []string
+		versions        []string
+		tags            map[string][]string
+		environments    []string
+		allowOutOfOrder bool
+
+		pending []pendingVersion
+		err     error
+	}
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			migrations := New("", "", nil)
+			migrations.SetAllowOutOfOrder(tc.allowOutOfOrder)
+			migrations.SetEnvironments(tc.environments...)
+			var tracked []trackedVersion
+			for _, version := range tc.history {
+				tracked = append(tracked, trackedVersion{Name: version, Kind: TrackingKindVersion})
+			}
+			for _, version := range tc.skipped {
+				tracked = append(tracked, trackedVersion{Name: version, Kind: TrackingKindSkipped})
+			}
+			var entries []versionEntry
+			for _, version := range tc.versions {
+				entries = append(entries, versionEntry{Name: version, Environments: tc.tags[version]})
+			}
+			pending, err := migrations.pendingVersions(tracked, entries)
+			if !reflect.DeepEqual(err, tc.err) {
+				t.Errorf("error, expected %v got %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(pending, tc.pending) {
+				t.Errorf("pending, expected %v got %v", tc.pending, pending)
+			}
+		}
+	}
+	apply := func(names ...string) (pending []pendingVersion) {
+		for _, name := range names {
+			pending = append(pending, pendingVersion{Name: name})
+		}
+		return pending
+	}
+	tests := map[string]tcase{
+		"new database": {
+			versions: []string{"a", "b"},
+			pending:  apply("a", "b"),
+		},
+		"up to date": {
+			history:  []string{"a", "b"},
+			versions: []string{"a", "b"},
+		},
+		"pending": {
+			history:  []string{"a"},
+			versions: []string{"a", "b", "c"},
+			pending:  apply("b", "c"),
+		},
+		"inserted": {
+			history:  []string{"a", "c"},
+			versions: []string{"a", "b", "c", "d"},
+			err: ErrDivergence{
+				Version:  "c",
+				Inserted: []string{"b"},
+			},
+		},
+		"inserted allow out of order": {
+			history:         []string{"a", "c"},
+			versions:        []string{"a", "b", "c", "d"},
+			allowOutOfOrder: true,
+			pending:         apply("b", "d"),
+		},
+		"removed": {
+			history:         []string{"a", "b", "c"},
+			versions:        []string{"a", "c"},
+			allowOutOfOrder: true,
+			err: ErrDivergence{
+				Version: "c",
+				Removed: []string{"b"},
+			},
+		},
+		"reordered": {
+			history:  []string{"a", "c", "b"},
+			versions: []string{"a", "b", "c"},
+			err: ErrDivergence{
+				Version:   "b",
+				Reordered: []string{"c"},
+			},
+		},
+		"environment skipped": {
+			versions:     []string{"a", "seed", "b"},
+			tags:         map[string][]string{"seed": {"dev", "test"}},
+			environments: []string{"prod"},
+			pending:      []pendingVersion{{Name: "a"}, {Name: "seed", Skip: true}, {Name: "b"}},
+		},
+		"environment applied": {
+			versions:     []string{"a", "seed", "b"},
+			tags:         map[string][]string{"seed": {"dev", "test"}},
+			environments: []string{"test"},
+			pending:      apply("a", "seed", "b"),
+		},
+		"environment previously skipped": {
+			history:  []string{"a"},
+			skipped:  []string{"seed"},
+			versions: []string{"a", "seed", "b"},
+			tags:     map[string][]string{"seed": {"dev"}},
+			// no active environment, so it is still skipped
+			pending: apply("b"),
+		},
+		"environment switched": {
+			history:      []string{"a"},
+			skipped:      []string{"seed"},
+			versions:     []string{"a", "seed", "b"},
+			tags:         map[string][]string{"seed": {"dev"}},
+			environments: []string{"dev"},
+			err: ErrDivergence{
+				Version: "seed",
+				Skipped: []string{"seed"},
+			},
+		},
+		"environment switched allow out of order": {
+			history:         []string{"a"},
+			skipped:         []string{"seed"},
+			versions:        []string{"a", "seed", "b"},
+			tags:            map[string][]string{"seed": {"dev"}},
+			environments:    []string{"dev"},
+			allowOutOfOrder: true,
+			pending:         apply("seed", "b"),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}
+
+func TestManager_Schema(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt":  {Data: []byte("users.sql.tpl\n")},
+		"migrations/users.sql.tpl": {Data: []byte("CREATE TABLE --{{ .Schema }}--.users ( name TEXT );")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+	migrations.SetSchema("aux")
+
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	auxFilename, auxCleanup := NewTestDBFilename(t, nil)
+	defer auxCleanup()
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatalf("open error, expected nil got %v", err)
+	}
+	defer db.Close()
+	// attached databases are per connection
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(`ATTACH DATABASE ? AS aux;`, auxFilename); err != nil {
+		t.Fatalf("attach error, expected nil got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, end, err := migrations.Upgrade(db, "test"); err != nil || end != "users.sql.tpl" {
+			t.Fatalf("[%v] upgrade, expected users.sql.tpl got %v, %v", i, end, err)
+		}
+	}
+	count := func(schema, name string) (n int) {
+		t.Helper()
+		err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %v.sqlite_master WHERE name = ?`, schema), name).Scan(&n)
+		if err != nil {
+			t.Fatalf("count error, expected nil got %v", err)
+		}
+		return n
+	}
+	for _, name := range []string{"users", "gen_migrations"} {
+		if count("aux", name) != 1 {
+			t.Errorf("%v, expected to be in aux", name)
+		}
+		if count("main", name) != 0 {
+			t.Errorf("%v, expected not to be in main", name)
+		}
+	}
+}
+
+func TestManager_readOldTrackingTable(t *testing.T) {
+	migrations := New(filepath.Join("testdata", "upgrade_issue", "migrations"), "gen_migrations", testdataFS)
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	db, err := openDBCopy(filepath.Join("testdata", "upgrade_issue", "test.db"), dbFilename)
+	if err != nil {
+		t.Fatalf("openDBCopy error expected nil, got error: %v", err)
+	}
+	defer db.Close()
+
+	version, err := migrations.DBVersion(db)
+	if err != nil {
+		t.Errorf("db version error, expected nil got %v", err)
+	}
+	if version != "simpletable2.sql" {
+		t.Errorf("db version, expected simpletable2.sql got %v", version)
+	}
+	history, err := migrations.History(db)
+	if err != nil {
+		t.Errorf("history error, expected nil got %v", err)
+	}
+	if expected := []string{"simpletable.sql", "simpletable2.sql"}; !reflect.DeepEqual(expected, history) {
+		t.Errorf("history, expected %v got %v", expected, history)
+	}
+	skipped, err := migrations.Skipped(db)
+	if err != nil {
+		t.Errorf("skipped error, expected nil got %v", err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("skipped, expected none got %v", skipped)
+	}
+
+	// reading must not upgrade the tracking table
+	columns, err := migrations.trackingTableColumns(db)
+	if err != nil {
+		t.Fatalf("tracking table columns error, expected nil got %v", err)
+	}
+	if columns["kind"] {
+		t.Errorf("kind column, expected it not to be added by a read")
+	}
+}
@@ -0,0 +1,297 @@
+// Package migrationtest applies a migration directory one version at a time against a temporary database,
+// so that tests can insert fixtures before a version and make assertions after it:
+//
+//	func TestMigrations(t *testing.T) {
+//		mng := migration.New("migrations", "", nil)
+//		migrationtest.New(t, mng).
+//			Before("0007_add_email.sql", func(t testing.TB, db *sql.DB) {
+//				migrationtest.Exec(t, db, `INSERT INTO users (name) VALUES ('gdey');`)
+//			}).
+//			After("0007_add_email.sql", func(t testing.TB, db *sql.DB) {
+//				migrationtest.HasColumn(t, db, "users", "email")
+//			}).
+//			Run()
+//	}
+//
+// Versions that have a down file (see migration.DownInfix) are checked to revert the schema to what it
+// was before the version was applied.
+package migrationtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+
+	// we only work with sqlite
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Author is the author recorded in the tracking table for the versions applied by a Runner
+const Author = "migrationtest"
+
+// Step is a fixture or an assertion run before or after a version is applied
+type Step func(t testing.TB, db *sql.DB)
+
+// Runner applies the versions of a migration directory one at a time
+type Runner struct {
+	t      testing.TB
+	mng    *migration.Manager
+	before map[string][]Step
+	after  map[string][]Step
+	// skipDown is true if the down files should not be checked
+	skipDown bool
+}
+
+// New returns a Runner for the versions of the manager
+func New(t testing.TB, mng *migration.Manager) *Runner {
+	return &Runner{
+		t:      t,
+		mng:    mng,
+		before: make(map[string][]Step),
+		after:  make(map[string][]Step),
+	}
+}
+
+// Before registers a step to run before the version is applied; e.g. to insert rows that the version
+// is expected to backfill.
+func (r *Runner) Before(version string, step Step) *Runner {
+	r.before[version] = append(r.before[version], step)
+	return r
+}
+
+// After registers a step to run after the version is applied
+func (r *Runner) After(version string, step Step) *Runner {
+	r.after[version] = append(r.after[version], step)
+	return r
+}
+
+// SkipDown sets whether the down files are checked
+func (r *Runner) SkipDown(skip bool) *Runner {
+	r.skipDown = skip
+	return r
+}
+
+// Run applies each version, in order, to a new temporary database, running the steps registered for the
+// version, and checking its down file. The database, at the latest version, is returned; it is removed
+// when the test is done.
+func (r *Runner) Run() *sql.DB {
+	r.t.Helper()
+	versions, err := r.mng.Versions()
+	if err != nil {
+		r.t.Fatalf("failed to get versions: %v", err)
+		return nil
+	}
+	known := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		known[version] = true
+	}
+	for _, steps := range []map[string][]Step{r.before, r.after} {
+		for version := range steps {
+			if !known[version] {
+				r.t.Fatalf("step registered for unknown version %v", version)
+				return nil
+			}
+		}
+	}
+
+	db := NewDB(r.t)
+	for _, version := range versions {
+		for _, step := range r.before[version] {
+			step(r.t, db)
+		}
+		if version != migration.InitialVersion {
+			r.apply(db, version)
+		}
+		for _, step := range r.after[version] {
+			step(r.t, db)
+		}
+		if r.t.Failed() {
+			r.t.FailNow()
+		}
+	}
+	return db
+}
+
+// apply will check that the down file of the version reverts it, and apply the version
+func (r *Runner) apply(db *sql.DB, version string) {
+	r.t.Helper()
+	if downFile, ok := r.mng.DownFile(version); ok && !r.skipDown {
+		if err := r.checkDown(db, version, downFile); err != nil {
+			r.t.Errorf("down file %v: %v", downFile, err)
+		}
+	}
+	if _, _, err := r.mng.UpgradeTo(db, Author, version); err != nil {
+		r.t.Fatalf("failed to apply %v: %v", version, err)
+	}
+}
+
+// checkDown will apply the version and then its down file to a scratch copy of the db, and compare the
+// schema with the schema from before the version. The files are not run in a transaction, so that files
+// with their own transaction, or that turn off foreign keys, are checked as they are applied. The version
+// is applied on its own, without being recorded in the tracking table.
+func (r *Runner) checkDown(db *sql.DB, version, downFile string) error {
+	up, err := r.mng.RenderFile(version)
+	if err != nil {
+		return err
+	}
+	down, err := r.mng.RenderFile(downFile)
+	if err != nil {
+		return err
+	}
+	scratch, err := sql.Open("sqlite3", NewFilename(r.t))
+	if err != nil {
+		return err
+	}
+	defer scratch.Close()
+	// pragmas apply to the connection they are run on
+	scratch.SetMaxOpenConns(1)
+	if err = backupDB(scratch, db); err != nil {
+		return fmt.Errorf("failed to copy the db: %w", err)
+	}
+	beforeSchema, err := schemaOf(scratch, r.mng)
+	if err != nil {
+		return err
+	}
+	if _, err = scratch.Exec(string(up)); err != nil {
+		return fmt.Errorf("failed to apply %v: %w", version, err)
+	}
+	if _, err = scratch.Exec(string(down)); err != nil {
+		return fmt.Errorf("failed to apply: %w", err)
+	}
+	afterSchema, err := schemaOf(scratch, r.mng)
+	if err != nil {
+		return err
+	}
+	if diff := diffSchema(beforeSchema, afterSchema); diff != "" {
+		return fmt.Errorf("schema does not match the schema before the version:\n%v", diff)
+	}
+	return nil
+}
+
+// schemaOf returns the sql of the objects of the db, other than the tracking table and sqlite's own objects
+func schemaOf(db migration.Executor, mng *migration.Manager) ([]string, error) {
+	const (
+		SelectSchemaSQL = `
+	SELECT type || ' ' || name || ': ' || coalesce(sql, '')
+	FROM %v.sqlite_master
+	WHERE name NOT LIKE 'sqlite_%%' AND tbl_name != ?
+	ORDER BY type, name;
+	`
+	)
+	rows, err := db.QueryContext(context.Background(), fmt.Sprintf(SelectSchemaSQL, sqlite.QuoteIdentifier(mng.Schema())), mng.TableName())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var schema []string
+	for rows.Next() {
+		var object string
+		if err = rows.Scan(&object); err != nil {
+			return nil, err
+		}
+		schema = append(schema, object)
+	}
+	return schema, rows.Err()
+}
+
+// diffSchema returns the objects that are only in one of the schemas, or "" if they are the same
+func diffSchema(expected, got []string) string {
+	var (
+		diff       strings.Builder
+		inGot      = make(map[string]bool, len(got))
+		inExpected = make(map[string]bool, len(expected))
+	)
+	for _, object := range got {
+		inGot[object] = true
+	}
+	for _, object := range expected {
+		inExpected[object] = true
+		if !inGot[object] {
+			fmt.Fprintf(&diff, "\t- %v\n", object)
+		}
+	}
+	for _, object := range got {
+		if !inExpected[object] {
+			fmt.Fprintf(&diff, "\t+ %v\n", object)
+		}
+	}
+	return diff.String()
+}
+
+// --------------------------------------------------------------------------------
+// Helpers
+// --------------------------------------------------------------------------------
+
+// NewFilename returns the name of a new database file, in a temporary directory that is removed when
+// the test is done.
+func NewFilename(t testing.TB) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "migrationtest.db")
+}
+
+// NewDB returns a new, empty, database that is closed and removed when the test is done
+func NewDB(t testing.TB) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", NewFilename(t))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+		return nil
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// Exec runs the sql against the db, failing the test on an error
+func Exec(t testing.TB, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("failed to exec `%v`: %v", query, err)
+	}
+}
+
+// HasTable fails the test if the db does not have the table
+func HasTable(t testing.TB, db *sql.DB, table string) {
+	t.Helper()
+	var count int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, table).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to look for table %v: %v", table, err)
+		return
+	}
+	if count == 0 {
+		t.Errorf("expected table %v", table)
+	}
+}
+
+// HasColumn fails the test if the table of the db does not have the column
+func HasColumn(t testing.TB, db *sql.DB, table, column string) {
+	t.Helper()
+	var count int
+	err := db.QueryRow(`SELECT count(*) FROM pragma_table_info(?) WHERE name = ?;`, table, column).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to look for column %v.%v: %v", table, column, err)
+		return
+	}
+	if count == 0 {
+		t.Errorf("expected column %v.%v", table, column)
+	}
+}
+
+// Count returns the number of rows of the table that match the where clause, which may be empty
+func Count(t testing.TB, db *sql.DB, table, where string, args ...interface{}) (count int) {
+	t.Helper()
+	query := fmt.Sprintf(`SELECT count(*) FROM %v`, sqlite.QuoteIdentifier(table))
+	if where != "" {
+		query += " WHERE " + where
+	}
+	if err := db.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatalf("failed to count %v: %v", table, err)
+	}
+	return count
+}
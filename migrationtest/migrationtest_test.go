@@ -0,0 +1,110 @@
+package migrationtest_test
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/migrationtest"
+)
+
+func TestRunner(t *testing.T) {
+	fs := fstest.MapFS{
+		"migrations/sequence.txt":        {Data: []byte("0001_users.sql\n0002_email.sql\n")},
+		"migrations/0001_users.sql":      {Data: []byte(`CREATE TABLE users (name TEXT);`)},
+		"migrations/0001_users.down.sql": {Data: []byte(`DROP TABLE users;`)},
+		"migrations/0002_email.sql":      {Data: []byte(`ALTER TABLE users ADD COLUMN email TEXT; UPDATE users SET email = name || '@example.com';`)},
+		"migrations/0002_email.down.sql": {Data: []byte(`CREATE TABLE users_down AS SELECT name FROM users; DROP TABLE users; CREATE TABLE users (name TEXT); INSERT INTO users SELECT name FROM users_down; DROP TABLE users_down;`)},
+		"migrations/repeatable/view.sql": {Data: []byte(`DROP VIEW IF EXISTS emails; CREATE VIEW emails AS SELECT email FROM users;`)},
+	}
+	mng := migration.New("migrations", "", fs)
+	var ran []string
+	db := migrationtest.New(t, mng).
+		After("0001_users.sql", func(t testing.TB, db *sql.DB) {
+			ran = append(ran, "after 0001")
+			migrationtest.HasTable(t, db, "users")
+			migrationtest.Exec(t, db, `INSERT INTO users (name) VALUES ('gdey');`)
+		}).
+		Before("0002_email.sql", func(t testing.TB, db *sql.DB) {
+			ran = append(ran, "before 0002")
+		}).
+		After("0002_email.sql", func(t testing.TB, db *sql.DB) {
+			ran = append(ran, "after 0002")
+			migrationtest.HasColumn(t, db, "users", "email")
+			if count := migrationtest.Count(t, db, "users", "email = ?", "gdey@example.com"); count != 1 {
+				t.Errorf("backfilled rows, expected 1 got %v", count)
+			}
+		}).
+		Run()
+	if len(ran) != 3 {
+		t.Errorf("steps, expected 3 got %v", ran)
+	}
+	migrationtest.HasTable(t, db, "users")
+	version, err := mng.DBVersion(db)
+	if err != nil || version != "0002_email.sql" {
+		t.Errorf("version, expected 0002_email.sql got %v (%v)", version, err)
+	}
+	if name, ok := mng.DownFile("0002_email.sql"); !ok || name != "0002_email.down.sql" {
+		t.Errorf("down file, expected 0002_email.down.sql got %v (%v)", name, ok)
+	}
+}
+
+// fakeT records the failures of the runner, instead of failing the test
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, format)
+	t.TB.Logf(format, args...)
+}
+func (t *fakeT) Failed() bool { return len(t.errors) != 0 }
+func (t *fakeT) FailNow()     { panic(t) }
+
+func TestRunner_BadDown(t *testing.T) {
+	fs := fstest.MapFS{
+		"migrations/sequence.txt":        {Data: []byte("0001_users.sql\n")},
+		"migrations/0001_users.sql":      {Data: []byte(`CREATE TABLE users (name TEXT); CREATE INDEX users_name ON users (name);`)},
+		"migrations/0001_users.down.sql": {Data: []byte(`DROP INDEX users_name;`)},
+	}
+	ft := &fakeT{TB: t}
+	func() {
+		defer func() {
+			if r := recover(); r != nil && r != ft {
+				panic(r)
+			}
+		}()
+		migrationtest.New(ft, migration.New("migrations", "", fs)).Run()
+	}()
+	if len(ft.errors) != 1 {
+		t.Errorf("errors, expected 1 got %v", ft.errors)
+	}
+}
+
+func TestRunner_DownWithTransaction(t *testing.T) {
+	fs := fstest.MapFS{
+		"migrations/sequence.txt":   {Data: []byte("0001_users.sql\n0002_rebuild.sql\n")},
+		"migrations/0001_users.sql": {Data: []byte(`CREATE TABLE "users" (name TEXT); CREATE TABLE posts (user TEXT REFERENCES users (name));`)},
+		"migrations/0002_rebuild.sql": {Data: []byte(`PRAGMA foreign_keys = OFF; BEGIN;
+CREATE TABLE users_new (name TEXT NOT NULL); INSERT INTO users_new SELECT name FROM users; DROP TABLE users; ALTER TABLE users_new RENAME TO users;
+COMMIT; PRAGMA foreign_keys = ON;`)},
+		"migrations/0002_rebuild.down.sql": {Data: []byte(`PRAGMA foreign_keys = OFF; BEGIN;
+CREATE TABLE users_old (name TEXT); INSERT INTO users_old SELECT name FROM users; DROP TABLE users; ALTER TABLE users_old RENAME TO users;
+COMMIT; PRAGMA foreign_keys = ON;`)},
+	}
+	ft := &fakeT{TB: t}
+	func() {
+		defer func() {
+			if r := recover(); r != nil && r != ft {
+				panic(r)
+			}
+		}()
+		migrationtest.New(ft, migration.New("migrations", "", fs)).Run()
+	}()
+	if len(ft.errors) != 0 {
+		t.Errorf("errors, expected none got %v", ft.errors)
+	}
+}
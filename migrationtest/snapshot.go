@@ -0,0 +1,62 @@
+package migrationtest
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/internal/textdiff"
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+)
+
+// UpdateSnapshotsEnv is the environment variable that, when set to 1, makes SchemaSnapshot write the golden
+// files instead of comparing with them
+const UpdateSnapshotsEnv = "MIGRATIONTEST_UPDATE"
+
+// Snapshot returns the dump (see schema.Dump) of the schema of a database at the latest version of the manager
+func Snapshot(mng *migration.Manager) (string, error) {
+	filename, err := templateFor(mng)
+	if err != nil {
+		return "", err
+	}
+	db, err := sqlite.New(filename)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	s, err := db.SchemaNamed(mng.Schema())
+	if err != nil {
+		return "", err
+	}
+	return schema.Dump(s, mng.TableName())
+}
+
+// SchemaSnapshot fails the test, showing the differences, if the schema of a database at the latest version
+// of the manager does not match the golden file; which can be written with `migrate schema-snapshot`, or by
+// running the test with MIGRATIONTEST_UPDATE=1.
+func SchemaSnapshot(t testing.TB, mng *migration.Manager, golden string) {
+	t.Helper()
+	snapshot, err := Snapshot(mng)
+	if err != nil {
+		t.Fatalf("failed to snapshot schema: %v", err)
+		return
+	}
+	if os.Getenv(UpdateSnapshotsEnv) == "1" {
+		if err = ioutil.WriteFile(golden, []byte(snapshot), 0666); err != nil {
+			t.Fatalf("failed to write %v: %v", golden, err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("failed to read %v: %v", golden, err)
+		return
+	}
+	if diff := textdiff.Lines(string(expected), snapshot); diff != "" {
+		t.Errorf("schema does not match %v, run with %v=1 to update it:\n%v", golden, UpdateSnapshotsEnv, diff)
+	}
+}
@@ -0,0 +1,69 @@
+package migrationtest_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/migrationtest"
+)
+
+func TestSchemaSnapshot(t *testing.T) {
+	fs := fstest.MapFS{
+		"migrations/sequence.txt": {Data: []byte("0001_users.sql\n")},
+		"migrations/0001_users.sql": {Data: []byte(`
+CREATE TABLE owners (id INTEGER PRIMARY KEY);
+CREATE TABLE users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT, -- the id
+  name TEXT NOT NULL DEFAULT 'a  b',
+  owner_id INTEGER REFERENCES owners(id) ON DELETE CASCADE
+);
+CREATE INDEX users_name ON users (name);
+CREATE VIEW names AS SELECT name FROM users;
+`)},
+	}
+	const expected = `TABLE owners
+	COLUMN id INTEGER PRIMARY KEY 1
+	SQL CREATE TABLE owners (id INTEGER PRIMARY KEY);
+
+TABLE users
+	COLUMN id INTEGER PRIMARY KEY 1
+	COLUMN name TEXT NOT NULL DEFAULT 'a  b'
+	COLUMN owner_id INTEGER
+	FOREIGN KEY owner_id REFERENCES owners(id) ON UPDATE NO ACTION ON DELETE CASCADE
+	SQL CREATE TABLE users ( id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL DEFAULT 'a  b', owner_id INTEGER REFERENCES owners(id) ON DELETE CASCADE );
+
+INDEX users_name
+	ON users
+	SQL CREATE INDEX users_name ON users (name);
+
+VIEW names
+	SQL CREATE VIEW names AS SELECT name FROM users;
+
+`
+	mng := migration.New("migrations", "", fs)
+	snapshot, err := migrationtest.Snapshot(mng)
+	if err != nil {
+		t.Fatalf("snapshot, expected nil got %v", err)
+	}
+	if snapshot != expected {
+		t.Errorf("snapshot,\n\texpected %q\n\t     got %q", expected, snapshot)
+	}
+
+	golden := filepath.Join(t.TempDir(), "schema_snapshot.txt")
+	if err = ioutil.WriteFile(golden, []byte(expected), 0666); err != nil {
+		t.Fatal(err)
+	}
+	migrationtest.SchemaSnapshot(t, mng, golden)
+
+	// a changed schema fails, with a diff
+	fs["migrations/sequence.txt"] = &fstest.MapFile{Data: []byte("0001_users.sql\n0002_email.sql\n")}
+	fs["migrations/0002_email.sql"] = &fstest.MapFile{Data: []byte(`ALTER TABLE users ADD COLUMN email TEXT;`)}
+	ft := &fakeT{TB: t}
+	migrationtest.SchemaSnapshot(ft, mng, golden)
+	if len(ft.errors) != 1 {
+		t.Errorf("errors, expected 1 got %v", ft.errors)
+	}
+}
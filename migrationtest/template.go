@@ -0,0 +1,221 @@
+package migrationtest
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/mattn/go-sqlite3"
+)
+
+// template is a database migrated to the latest version, that the databases of the tests are copied from. It
+// is an in-memory database, shared by the connections to its dsn, that goes away with the process.
+type template struct {
+	once sync.Once
+	dsn  string
+	// conn keeps the database alive, as an in-memory database is removed when its last connection closes
+	conn *sql.Conn
+	db   *sql.DB
+	err  error
+}
+
+var (
+	templatesLock sync.Mutex
+	// templates are keyed by the hash of the migrations
+	templates = make(map[string]*template)
+)
+
+// MigratedDB returns a new database file, at the latest version of the manager, that is closed and removed
+// when the test is done.
+//
+// The migrations are applied once per process, to an in-memory template database, which is copied for each
+// test with the SQLite backup API. Templates are keyed by the hash of the migrations, and the settings of the
+// manager, so tests using different, or changed, migrations get their own template. The templates go away
+// with the process.
+func MigratedDB(t testing.TB, mng *migration.Manager) *sql.DB {
+	t.Helper()
+	return copyTemplate(t, mng, NewFilename(t), nil)
+}
+
+// MigratedMemoryDB is like MigratedDB, but the database is in memory. The *sql.DB is limited to a single
+// connection, as each connection to :memory: is a different database.
+func MigratedMemoryDB(t testing.TB, mng *migration.Manager) *sql.DB {
+	t.Helper()
+	return copyTemplate(t, mng, ":memory:", func(db *sql.DB) {
+		db.SetMaxOpenConns(1)
+		// the database goes away with its connection
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	})
+}
+
+// RemoveTemplates frees the template databases created by MigratedDB and MigratedMemoryDB, before the process
+// ends; e.g. in a long running process.
+func RemoveTemplates() error {
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+	var errs []string
+	for _, tpl := range templates {
+		// wait for the template to be created
+		tpl.once.Do(func() {})
+		if tpl.db == nil {
+			continue
+		}
+		_ = tpl.conn.Close()
+		if err := tpl.db.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	templates = make(map[string]*template)
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// copyTemplate opens the dsn, and copies the template for the manager into it
+func copyTemplate(t testing.TB, mng *migration.Manager, dsn string, configure func(db *sql.DB)) *sql.DB {
+	t.Helper()
+	tpl, err := templateFor(mng)
+	if err != nil {
+		t.Fatalf("failed to create template: %v", err)
+		return nil
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+		return nil
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if configure != nil {
+		configure(db)
+	}
+	if err = backup(db, tpl); err != nil {
+		t.Fatalf("failed to copy template %v: %v", tpl, err)
+		return nil
+	}
+	return db
+}
+
+// templateFor returns the dsn of the template for the manager, creating it if needed
+func templateFor(mng *migration.Manager) (string, error) {
+	key, err := migrationsHash(mng)
+	if err != nil {
+		return "", err
+	}
+	templatesLock.Lock()
+	tpl, ok := templates[key]
+	if !ok {
+		tpl = &template{dsn: "file:migrationtest-" + key + "?mode=memory&cache=shared"}
+		templates[key] = tpl
+	}
+	templatesLock.Unlock()
+
+	// other templates can be created while this one is
+	tpl.once.Do(func() { tpl.err = tpl.create(mng) })
+	return tpl.dsn, tpl.err
+}
+
+// create migrates the new template database to the latest version
+func (tpl *template) create(mng *migration.Manager) error {
+	db, err := sql.Open("sqlite3", tpl.dsn)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return err
+	}
+	if _, _, err = mng.Upgrade(db, Author); err != nil {
+		conn.Close()
+		db.Close()
+		return err
+	}
+	tpl.db, tpl.conn = db, conn
+	return nil
+}
+
+// migrationsHash returns the hash of everything that goes into a migrated database: the rendered versions and
+// repeatable files, the config file, and the settings of the manager
+func migrationsHash(mng *migration.Manager) (string, error) {
+	versions, err := mng.Versions()
+	if err != nil {
+		return "", err
+	}
+	repeatables, err := mng.Repeatables()
+	if err != nil {
+		return "", err
+	}
+	config, err := mng.Config()
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%v\n%v\n%v\n", mng.TableName(), mng.Schema(), strings.Join(mng.Environments(), ","))
+	fmt.Fprintf(hash, "%v\n%v\n%v\n", mng.SyncUserVersion(), mng.Maintenance(), config.ApplicationID)
+	for _, name := range append(versions[1:], repeatables...) {
+		body, err := mng.RenderFile(name)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(hash, name+"\n")
+		hash.Write(body)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// backup copies the main database of the dsn into the main database of the db
+func backup(db *sql.DB, dsn string) error {
+	src, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return backupDB(db, src)
+}
+
+// backupDB copies the main database of src into the main database of db
+func backupDB(db, src *sql.DB) error {
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	destConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dest, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("not an sqlite3 connection")
+			}
+			src, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("not an sqlite3 connection")
+			}
+			b, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+			if _, err = b.Step(-1); err != nil {
+				b.Close()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package migrationtest_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	migration "github.com/gdey/sqlite-migration"
+	"github.com/gdey/sqlite-migration/migrationtest"
+)
+
+// countingLogger counts the sql files applied
+type countingLogger struct {
+	lock    sync.Mutex
+	applied int
+}
+
+func (l *countingLogger) Printf(format string, _ ...interface{}) {
+	if strings.Contains(format, "to apply") {
+		l.lock.Lock()
+		l.applied++
+		l.lock.Unlock()
+	}
+}
+
+func TestMigratedDB(t *testing.T) {
+	fs := fstest.MapFS{
+		"migrations/sequence.txt":   {Data: []byte("0001_users.sql\n0002_seed.sql\n")},
+		"migrations/0001_users.sql": {Data: []byte(`CREATE TABLE users (name TEXT);`)},
+		"migrations/0002_seed.sql":  {Data: []byte(`INSERT INTO users (name) VALUES ('admin');`)},
+	}
+	var logger countingLogger
+	mng := migration.New("migrations", "", fs)
+	mng.SetLog(&logger)
+
+	file1 := migrationtest.MigratedDB(t, mng)
+	file2 := migrationtest.MigratedDB(t, mng)
+	memory := migrationtest.MigratedMemoryDB(t, mng)
+	if logger.applied != 2 {
+		t.Errorf("applied, expected 2 got %v", logger.applied)
+	}
+
+	migrationtest.Exec(t, file1, `INSERT INTO users (name) VALUES ('gdey');`)
+	if count := migrationtest.Count(t, file1, "users", ""); count != 2 {
+		t.Errorf("file1 users, expected 2 got %v", count)
+	}
+	if count := migrationtest.Count(t, file2, "users", ""); count != 1 {
+		t.Errorf("file2 users, expected 1 got %v", count)
+	}
+	if count := migrationtest.Count(t, memory, "users", ""); count != 1 {
+		t.Errorf("memory users, expected 1 got %v", count)
+	}
+	version, err := mng.DBVersion(memory)
+	if err != nil || version != "0002_seed.sql" {
+		t.Errorf("version, expected 0002_seed.sql got %v (%v)", version, err)
+	}
+
+	// a change to the migrations gets a new template
+	fs["migrations/0002_seed.sql"] = &fstest.MapFile{Data: []byte(`INSERT INTO users (name) VALUES ('admin'), ('root');`)}
+	changed := migrationtest.MigratedMemoryDB(t, mng)
+	if count := migrationtest.Count(t, changed, "users", ""); count != 2 {
+		t.Errorf("changed users, expected 2 got %v", count)
+	}
+	if logger.applied != 4 {
+		t.Errorf("applied, expected 4 got %v", logger.applied)
+	}
+
+	// so does a change to the settings of the manager
+	mng.SetSyncUserVersion(true)
+	synced := migrationtest.MigratedDB(t, mng)
+	if logger.applied != 6 {
+		t.Errorf("applied, expected 6 got %v", logger.applied)
+	}
+	if userVersion, err := mng.UserVersion(synced); err != nil || userVersion != 2 {
+		t.Errorf("user version, expected 2 got %v (%v)", userVersion, err)
+	}
+
+	if err = migrationtest.RemoveTemplates(); err != nil {
+		t.Errorf("remove templates, expected nil got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// RepeatableDir is the directory, in the migration directory, containing repeatable sql files.
+	RepeatableDir = "repeatable"
+	// RepeatablePrefix is the prefix of repeatable sql files in the migration directory.
+	RepeatablePrefix = "R__"
+)
+
+const (
+	// TrackingKindVersion is the kind of tracking entry recorded for a version in the sequence
+	TrackingKindVersion = "version"
+	// TrackingKindRepeatable is the kind of tracking entry recorded each time a repeatable file is applied
+	TrackingKindRepeatable = "repeatable"
+)
+
+// isRepeatable returns true if the name (relative to the migration directory) is a repeatable sql file
+func isRepeatable(name string) bool {
+	return strings.HasPrefix(name, RepeatableDir+"/") ||
+		(strings.HasPrefix(name, RepeatablePrefix) && !strings.Contains(name, "/"))
+}
+
+// Repeatables returns the repeatable sql files, relative to the migration directory, sorted by name.
+// Repeatable files are the sql files in the repeatable directory, and the sql files in the migration
+// directory that start with the repeatable prefix (R__). Repeatable files are used for things like views,
+// triggers and lookup tables that are easier to maintain as their "current definition"; they are
+// re-applied, after all the versions, whenever their rendered content changes.
+func (mng *Manager) Repeatables() ([]string, error) {
+	var repeatables []string
+
+	dir := filepath.Clean(mng.dir)
+	sqlFiles, err := mng.sqlFilesIn(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sqlFiles {
+		if isRepeatable(name) {
+			repeatables = append(repeatables, name)
+		}
+	}
+
+	repeatableDir := filepath.Join(mng.dir, RepeatableDir)
+	if mng.isDir(repeatableDir) {
+		err = fs.WalkDir(mng.FS(), repeatableDir, func(filename string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isSQLFile(filename) {
+				return nil
+			}
+			repeatables = append(repeatables, path.Join(RepeatableDir, strings.TrimPrefix(filename, repeatableDir+"/")))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %v: %w", repeatableDir, err)
+		}
+	}
+	sort.Strings(repeatables)
+	return repeatables, nil
+}
+
+// RepeatableHash returns the hash that was recorded the last time the repeatable file was applied to the db,
+// or "" if it has never been applied.
+func (mng *Manager) RepeatableHash(db Executor, name string) (string, error) {
+	const (
+		SelectRepeatableHashSQL = `
+	SELECT file_hash
+	FROM %s
+	WHERE kind = ? AND file_path = ?
+	ORDER by ROWID desc
+	LIMIT 1;
+	`
+	)
+	if err := mng.verifyApplicationID(db, false); err != nil {
+		return "", err
+	}
+	source, err := mng.trackingSource(db)
+	if err != nil {
+		return "", err
+	}
+	var hash string
+	err = db.QueryRowContext(context.Background(), fmt.Sprintf(SelectRepeatableHashSQL, source), TrackingKindRepeatable, name).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", ErrTrackingRead{Err: err, TableName: mng.TableName()}
+	}
+	return hash, nil
+}
+
+// applyRepeatable will apply the repeatable file if its rendered hash differs from the last one recorded
+func (mng *Manager) applyRepeatable(db Executor, author, name string, maxLength int) error {
+	startT := time.Now()
+	filename := filepath.Join(mng.dir, name)
+	body, hash, err := mng.renderSQLFile(filename)
+	if err != nil {
+		return fmt.Errorf("error applying repeatable SQL file: %v : %w", filename, err)
+	}
+	lastHash, err := mng.RepeatableHash(db, name)
+	if err != nil {
+		return err
+	}
+	if lastHash == hash {
+		return nil
+	}
+	var duration float64
+	err = mng.inTransaction(db, func(exec Executor) error {
+		if err := mng.execSQL(exec, filename, body, hash); err != nil {
+			return fmt.Errorf("error applying repeatable SQL file: %v : %w", filename, err)
+		}
+		duration = time.Now().Sub(startT).Seconds()
+		return mng.insertTrackingEntry(exec, TrackingKindRepeatable, name, hash, author, duration)
+	})
+	if err != nil {
+		return err
+	}
+	mng.Log().Printf("SQL file %-*s took %3.5fs to apply", maxLength, name, duration)
+	return nil
+}
@@ -0,0 +1,71 @@
+package migration
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_Repeatables(t *testing.T) {
+	const viewSQL = `
+DROP VIEW IF EXISTS named;
+CREATE VIEW named AS SELECT name FROM aTable WHERE name IS NOT NULL;
+`
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt":          {Data: []byte("simpletable.sql\n")},
+		"migrations/simpletable.sql":       {Data: []byte("CREATE TABLE aTable ( name TEXT, value TEXT );")},
+		"migrations/R__lookup.sql":         {Data: []byte("CREATE TABLE IF NOT EXISTS lookup ( code TEXT );")},
+		"migrations/repeatable/views.sql":  {Data: []byte(viewSQL)},
+		"migrations/repeatable/README.txt": {Data: []byte("not sql")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+
+	repeatables, err := migrations.Repeatables()
+	if err != nil {
+		t.Fatalf("repeatables error, expected nil got %v", err)
+	}
+	expected := []string{"R__lookup.sql", "repeatable/views.sql"}
+	if !reflect.DeepEqual(repeatables, expected) {
+		t.Errorf("repeatables, expected %v got %v", expected, repeatables)
+	}
+
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatalf("open error, expected nil got %v", err)
+	}
+	defer db.Close()
+
+	count := func(kind string) (n int) {
+		err := db.QueryRow(`SELECT COUNT(*) FROM gen_migrations WHERE kind = ?`, kind).Scan(&n)
+		if err != nil {
+			t.Fatalf("count error, expected nil got %v", err)
+		}
+		return n
+	}
+	upgrade := func(versions, repeatable int) {
+		t.Helper()
+		_, end, err := migrations.Upgrade(db, "test")
+		if err != nil {
+			t.Fatalf("upgrade error, expected nil got %v", err)
+		}
+		if end != "simpletable.sql" {
+			t.Errorf("upgrade end, expected simpletable.sql got %v", end)
+		}
+		if n := count(TrackingKindVersion); n != versions {
+			t.Errorf("version entries, expected %v got %v", versions, n)
+		}
+		if n := count(TrackingKindRepeatable); n != repeatable {
+			t.Errorf("repeatable entries, expected %v got %v", repeatable, n)
+		}
+	}
+
+	upgrade(1, 2)
+	// nothing changed, nothing should be re-applied
+	upgrade(1, 2)
+	// change the view, only it should be re-applied
+	fsys["migrations/repeatable/views.sql"] = &fstest.MapFile{Data: []byte(viewSQL + "-- only named values\n")}
+	upgrade(1, 3)
+}
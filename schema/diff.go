@@ -0,0 +1,351 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is how an object differs between two schemas
+type ChangeKind string
+
+const (
+	// Added objects are only in the second schema
+	Added = ChangeKind("added")
+	// Removed objects are only in the first schema
+	Removed = ChangeKind("removed")
+	// Changed objects are in both schemas, but differ
+	Changed = ChangeKind("changed")
+)
+
+// symbol is the prefix of changes of the kind, when they are printed
+func (kind ChangeKind) symbol() string {
+	switch kind {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// Change is an object, or a column or foreign key of a table, that differs between two schemas
+type Change struct {
+	Kind ChangeKind
+	Name string
+	// From and To describe the object in the first and second schema; From is empty for added objects, and
+	// To for removed objects. For tables, views, indexes and triggers they are the normalised sql of the
+	// object; for columns and foreign keys their definition.
+	From string
+	To   string
+}
+
+// TableChange is a table that differs between two schemas
+type TableChange struct {
+	Change
+	// Columns and ForeignKeys that differ, for changed tables
+	Columns     []Change
+	ForeignKeys []Change
+}
+
+// Changes are the differences between two schemas
+type Changes struct {
+	Tables   []TableChange
+	Indexes  []Change
+	Views    []Change
+	Triggers []Change
+}
+
+// Empty returns true when the schemas are the same
+func (changes Changes) Empty() bool {
+	return len(changes.Tables) == 0 && len(changes.Indexes) == 0 && len(changes.Views) == 0 && len(changes.Triggers) == 0
+}
+
+// String describes the changes, a line per object; + for added, - for removed and ~ for changed objects
+func (changes Changes) String() string {
+	var desc strings.Builder
+	writeSQL := func(indent string, change Change) {
+		if change.From == change.To {
+			return
+		}
+		if change.From != "" {
+			fmt.Fprintf(&desc, "%v- SQL %v\n", indent, change.From)
+		}
+		if change.To != "" {
+			fmt.Fprintf(&desc, "%v+ SQL %v\n", indent, change.To)
+		}
+	}
+	for _, table := range changes.Tables {
+		fmt.Fprintf(&desc, "%v TABLE %v\n", table.Kind.symbol(), table.Name)
+		if table.Kind != Changed {
+			continue
+		}
+		for _, column := range table.Columns {
+			fmt.Fprintf(&desc, "\t%v COLUMN %v\n", column.Kind.symbol(), describeChange(column))
+		}
+		for _, key := range table.ForeignKeys {
+			fmt.Fprintf(&desc, "\t%v FOREIGN KEY %v\n", key.Kind.symbol(), describeChange(key))
+		}
+		writeSQL("\t", table.Change)
+	}
+	for _, objects := range []struct {
+		kind    string
+		changes []Change
+	}{
+		{"INDEX", changes.Indexes},
+		{"VIEW", changes.Views},
+		{"TRIGGER", changes.Triggers},
+	} {
+		for _, change := range objects.changes {
+			fmt.Fprintf(&desc, "%v %v %v\n", change.Kind.symbol(), objects.kind, change.Name)
+			if change.Kind == Changed {
+				writeSQL("\t", change)
+			}
+		}
+	}
+	return desc.String()
+}
+
+// describeChange returns the definition of a column or foreign key; for a changed one, both definitions
+func describeChange(change Change) string {
+	switch change.Kind {
+	case Added:
+		return change.To
+	case Removed:
+		return change.From
+	default:
+		return change.From + " => " + change.To
+	}
+}
+
+// Diff returns the changes that turn schema a into schema b. Objects of sqlite, and the tables named in skip,
+// and their indexes and triggers, are left out.
+func Diff(a, b Schema, skip ...string) (changes Changes, err error) {
+	include := IncludeFunc(skip...)
+
+	tablesA, err := diffTables(a, include)
+	if err != nil {
+		return changes, err
+	}
+	tablesB, err := diffTables(b, include)
+	if err != nil {
+		return changes, err
+	}
+	var namesA, namesB []string
+	for name := range tablesA {
+		namesA = append(namesA, name)
+	}
+	for name := range tablesB {
+		namesB = append(namesB, name)
+	}
+	for _, name := range sortedUnion(namesA, namesB) {
+		tableA, inA := tablesA[name]
+		tableB, inB := tablesB[name]
+		change := TableChange{Change: Change{Name: name, From: tableA.sql, To: tableB.sql}}
+		switch {
+		case !inA:
+			change.Kind = Added
+		case !inB:
+			change.Kind = Removed
+		default:
+			change.Kind = Changed
+			change.Columns = diffDefinitions(tableA.columns, tableB.columns)
+			change.ForeignKeys = diffDefinitions(tableA.foreignKeys, tableB.foreignKeys)
+			if len(change.Columns) == 0 && len(change.ForeignKeys) == 0 && change.From == change.To {
+				continue
+			}
+		}
+		changes.Tables = append(changes.Tables, change)
+	}
+
+	objectsSQL := func(s Schema, kind string) (map[string]string, error) {
+		objects := make(map[string]string)
+		switch kind {
+		case "INDEX":
+			indexes, err := s.Indexes()
+			if err != nil {
+				return nil, err
+			}
+			for _, index := range indexes {
+				if include(index.Name()) && include(index.Table()) {
+					objects[index.Name()] = NormaliseSQL(IndexSQL(index))
+				}
+			}
+		case "VIEW":
+			views, err := s.Views()
+			if err != nil {
+				return nil, err
+			}
+			for _, view := range views {
+				if include(view.Name()) {
+					objects[view.Name()] = NormaliseSQL(view.SQL())
+				}
+			}
+		case "TRIGGER":
+			triggers, err := s.Triggers()
+			if err != nil {
+				return nil, err
+			}
+			for _, trigger := range triggers {
+				if include(trigger.Name()) && include(trigger.Table()) {
+					objects[trigger.Name()] = NormaliseSQL(trigger.SQL())
+				}
+			}
+		}
+		return objects, nil
+	}
+	for _, object := range []struct {
+		kind    string
+		changes *[]Change
+	}{
+		{"INDEX", &changes.Indexes},
+		{"VIEW", &changes.Views},
+		{"TRIGGER", &changes.Triggers},
+	} {
+		objectsA, err := objectsSQL(a, object.kind)
+		if err != nil {
+			return changes, err
+		}
+		objectsB, err := objectsSQL(b, object.kind)
+		if err != nil {
+			return changes, err
+		}
+		*object.changes = diffObjects(objectsA, objectsB)
+	}
+	return changes, nil
+}
+
+// diffTable is a table, as it is compared
+type diffTable struct {
+	sql string
+	// columns and foreignKeys are the definitions, by column name and from columns
+	columns     definitions
+	foreignKeys definitions
+}
+
+// definitions are the definitions of the columns or foreign keys of a table, in the order of the table
+type definitions struct {
+	names  []string
+	byName map[string]string
+}
+
+func (defs *definitions) add(name, definition string) {
+	if defs.byName == nil {
+		defs.byName = make(map[string]string)
+	}
+	if _, ok := defs.byName[name]; !ok {
+		defs.names = append(defs.names, name)
+	}
+	defs.byName[name] = definition
+}
+
+// diffTables returns the included tables of the schema, by name
+func diffTables(s Schema, include func(name string) bool) (map[string]diffTable, error) {
+	tables, err := s.Tables()
+	if err != nil {
+		return nil, err
+	}
+	diffs := make(map[string]diffTable, len(tables))
+	for _, table := range tables {
+		if !include(table.Name()) {
+			continue
+		}
+		diff := diffTable{sql: NormaliseSQL(table.SQL())}
+		columns, err := table.Columns()
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range columns {
+			diff.columns.add(column.Name(), dumpColumn(column))
+		}
+		keys, err := table.ForeignKeys()
+		if err != nil {
+			return nil, err
+		}
+		// the columns of a foreign key are spread over rows with the same id
+		var (
+			ids  []int
+			byID = make(map[int][]ForeignKey)
+		)
+		for _, key := range keys {
+			if _, ok := byID[key.ID()]; !ok {
+				ids = append(ids, key.ID())
+			}
+			byID[key.ID()] = append(byID[key.ID()], key)
+		}
+		for _, id := range ids {
+			key := byID[id]
+			sort.Slice(key, func(i, j int) bool { return key[i].Seq() < key[j].Seq() })
+			from, to := make([]string, len(key)), make([]string, len(key))
+			for i := range key {
+				from[i], to[i] = key[i].FromColumn(), key[i].ToColumn()
+			}
+			name := "(" + strings.Join(from, ", ") + ")"
+			diff.foreignKeys.add(name, fmt.Sprintf("%v REFERENCES %v(%v) ON UPDATE %v ON DELETE %v",
+				name, key[0].ToTable(), strings.Join(to, ", "), key[0].OnUpdate(), key[0].OnDelete(),
+			))
+		}
+		diffs[table.Name()] = diff
+	}
+	return diffs, nil
+}
+
+// diffDefinitions returns the changes of the definitions; the removed and changed ones in the order of a,
+// followed by the added ones in the order of b
+func diffDefinitions(a, b definitions) (changes []Change) {
+	for _, name := range a.names {
+		from := a.byName[name]
+		to, ok := b.byName[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: Removed, Name: name, From: from})
+		case from != to:
+			changes = append(changes, Change{Kind: Changed, Name: name, From: from, To: to})
+		}
+	}
+	for _, name := range b.names {
+		if _, ok := a.byName[name]; !ok {
+			changes = append(changes, Change{Kind: Added, Name: name, To: b.byName[name]})
+		}
+	}
+	return changes
+}
+
+// diffObjects returns the changes of the sql of the objects, sorted by name
+func diffObjects(a, b map[string]string) (changes []Change) {
+	var namesA, namesB []string
+	for name := range a {
+		namesA = append(namesA, name)
+	}
+	for name := range b {
+		namesB = append(namesB, name)
+	}
+	for _, name := range sortedUnion(namesA, namesB) {
+		from, inA := a[name]
+		to, inB := b[name]
+		switch {
+		case !inA:
+			changes = append(changes, Change{Kind: Added, Name: name, To: to})
+		case !inB:
+			changes = append(changes, Change{Kind: Removed, Name: name, From: from})
+		case from != to:
+			changes = append(changes, Change{Kind: Changed, Name: name, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// sortedUnion returns the names in a or b, sorted and without duplicates
+func sortedUnion(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var names []string
+	for _, name := range append(append([]string{}, a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,198 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Object is the dump of an object of a schema
+type Object struct {
+	// Kind is one of TABLE, INDEX, VIEW or TRIGGER
+	Kind string
+	Name string
+	// Dump is the description of the object; for a table its columns, foreign keys and sql
+	Dump string
+}
+
+// Key returns the kind and name of the object, which is unique within a schema
+func (obj Object) Key() string { return obj.Kind + " " + obj.Name }
+
+// Dump returns a normalised, deterministic, description of the schema; objects are sorted by name, and the
+// whitespace and comments of their sql are normalised, so that two databases with the same schema have the
+// same dump. Objects of sqlite, and the tables named in skip, and their indexes and triggers, are left out.
+func Dump(s Schema, skip ...string) (string, error) {
+	objects, err := DumpObjects(s, skip...)
+	if err != nil {
+		return "", err
+	}
+	var dump strings.Builder
+	for _, obj := range objects {
+		fmt.Fprintf(&dump, "%v\n%v\n", obj.Key(), obj.Dump)
+	}
+	return dump.String(), nil
+}
+
+// DumpObjects returns the dump of each of the objects of the schema; tables, indexes, views and then triggers,
+// each sorted by name. See Dump.
+func DumpObjects(s Schema, skip ...string) (objects []Object, err error) {
+	include := IncludeFunc(skip...)
+
+	tables, err := s.Tables()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name() < tables[j].Name() })
+	for _, table := range tables {
+		if !include(table.Name()) {
+			continue
+		}
+		var dump strings.Builder
+		columns, err := table.Columns()
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range columns {
+			fmt.Fprintf(&dump, "\tCOLUMN %v\n", dumpColumn(column))
+		}
+		keys, err := table.ForeignKeys()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			fmt.Fprintf(&dump, "\tFOREIGN KEY %v REFERENCES %v(%v) ON UPDATE %v ON DELETE %v\n",
+				key.FromColumn(), key.ToTable(), key.ToColumn(), key.OnUpdate(), key.OnDelete(),
+			)
+		}
+		fmt.Fprintf(&dump, "\tSQL %v\n", NormaliseSQL(table.SQL()))
+		objects = append(objects, Object{Kind: "TABLE", Name: table.Name(), Dump: dump.String()})
+	}
+
+	indexes, err := s.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name() < indexes[j].Name() })
+	for _, index := range indexes {
+		if !include(index.Name()) || !include(index.Table()) {
+			continue
+		}
+		objects = append(objects, Object{
+			Kind: "INDEX",
+			Name: index.Name(),
+			Dump: fmt.Sprintf("\tON %v\n\tSQL %v\n", index.Table(), NormaliseSQL(IndexSQL(index))),
+		})
+	}
+
+	views, err := s.Views()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Name() < views[j].Name() })
+	for _, view := range views {
+		if !include(view.Name()) {
+			continue
+		}
+		objects = append(objects, Object{Kind: "VIEW", Name: view.Name(), Dump: fmt.Sprintf("\tSQL %v\n", NormaliseSQL(view.SQL()))})
+	}
+
+	triggers, err := s.Triggers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(triggers, func(i, j int) bool { return triggers[i].Name() < triggers[j].Name() })
+	for _, trigger := range triggers {
+		if !include(trigger.Name()) || !include(trigger.Table()) {
+			continue
+		}
+		objects = append(objects, Object{
+			Kind: "TRIGGER",
+			Name: trigger.Name(),
+			Dump: fmt.Sprintf("\tON %v\n\tSQL %v\n", trigger.Table(), NormaliseSQL(trigger.SQL())),
+		})
+	}
+	return objects, nil
+}
+
+// dumpColumn returns the description of the column; its name, type and constraints
+func dumpColumn(column Column) string {
+	var desc strings.Builder
+	desc.WriteString(column.Name())
+	if typ := column.Type(); typ != "" {
+		desc.WriteString(" " + typ)
+	}
+	if !column.Nullable() {
+		desc.WriteString(" NOT NULL")
+	}
+	if value, err := column.Default(); err == nil && value != nil {
+		fmt.Fprintf(&desc, " DEFAULT %s", value)
+	}
+	if primary, pos := column.IsPrimary(); primary {
+		fmt.Fprintf(&desc, " PRIMARY KEY %v", pos)
+	}
+	if column.Hidden() {
+		desc.WriteString(" HIDDEN")
+	}
+	return desc.String()
+}
+
+// NormaliseSQL removes the comments of the sql, and replaces each run of whitespace with a single space;
+// quoted strings and identifiers are left as they are.
+func NormaliseSQL(sql string) string {
+	var (
+		normalised strings.Builder
+		space      bool
+	)
+	writeSpace := func() {
+		if space && normalised.Len() > 0 {
+			normalised.WriteByte(' ')
+		}
+		space = false
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			space = true
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			space = true
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			space = true
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			writeSpace()
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			start := i
+			for i++; i < len(sql); i++ {
+				if sql[i] != closing {
+					continue
+				}
+				// quotes are escaped by doubling them
+				if closing != ']' && i+1 < len(sql) && sql[i+1] == closing {
+					i++
+					continue
+				}
+				break
+			}
+			if i >= len(sql) {
+				i = len(sql) - 1
+			}
+			normalised.WriteString(sql[start : i+1])
+		default:
+			writeSpace()
+			normalised.WriteByte(c)
+		}
+	}
+	return normalised.String()
+}
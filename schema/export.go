@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gdey/sqlite-migration/sqlparse"
+)
+
+// ExportFormatVersion is the version of the document of Export; it changes when a field is removed, or its
+// meaning changes. Fields may be added without changing the version.
+const ExportFormatVersion = 1
+
+// Exported is the structure of a schema, as a document for other tools; it is marshalled to JSON with the
+// field names given in the json tags. Every field is always present, lists are [] rather than null.
+type Exported struct {
+	// FormatVersion is ExportFormatVersion
+	FormatVersion int               `json:"format_version"`
+	Name          string            `json:"name"`
+	Tables        []ExportedTable   `json:"tables"`
+	Views         []ExportedView    `json:"views"`
+	Indexes       []ExportedIndex   `json:"indexes"`
+	Triggers      []ExportedTrigger `json:"triggers"`
+}
+
+// ExportedTable is a table of an exported schema
+type ExportedTable struct {
+	Name      string `json:"name"`
+	Temporary bool   `json:"temporary"`
+	// Columns are in the order of the table
+	Columns     []ExportedColumn     `json:"columns"`
+	ForeignKeys []ExportedForeignKey `json:"foreign_keys"`
+	// SQL is the CREATE TABLE statement, as it was written
+	SQL string `json:"sql"`
+}
+
+// ExportedColumn is a column of an exported table or view
+type ExportedColumn struct {
+	Name string `json:"name"`
+	// Type is the declared type, in upper case; "" if there is not one
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	// Default is the sql of the default value; e.g. 'none' or CURRENT_TIMESTAMP, nil if there is not one
+	Default *string `json:"default"`
+	// PrimaryKey is the position, starting at 1, of the column in the primary key; 0 if it is not part of it
+	PrimaryKey int  `json:"primary_key"`
+	Hidden     bool `json:"hidden"`
+	// Generated is VIRTUAL or STORED for a generated column, "" otherwise
+	Generated string `json:"generated"`
+}
+
+// ExportedForeignKey is a foreign key of an exported table
+type ExportedForeignKey struct {
+	// Columns of the table, and the ToColumns of the ToTable they reference; ToColumns are "" for the
+	// primary key of ToTable
+	Columns   []string `json:"columns"`
+	ToTable   string   `json:"to_table"`
+	ToColumns []string `json:"to_columns"`
+	// OnUpdate and OnDelete are the actions; e.g. NO ACTION or CASCADE
+	OnUpdate string `json:"on_update"`
+	OnDelete string `json:"on_delete"`
+	Match    string `json:"match"`
+}
+
+// ExportedView is a view of an exported schema
+type ExportedView struct {
+	Name    string           `json:"name"`
+	Columns []ExportedColumn `json:"columns"`
+	SQL     string           `json:"sql"`
+}
+
+// ExportedIndex is an index of an exported schema
+type ExportedIndex struct {
+	Name   string `json:"name"`
+	Table  string `json:"table"`
+	Unique bool   `json:"unique"`
+	// Columns are the columns of the index, in order; expressions are left out
+	Columns []string `json:"columns"`
+	// Where is the expression of a partial index, "" if it is not one
+	Where string `json:"where"`
+	SQL   string `json:"sql"`
+}
+
+// ExportedTrigger is a trigger of an exported schema
+type ExportedTrigger struct {
+	Name  string `json:"name"`
+	Table string `json:"table"`
+	SQL   string `json:"sql"`
+}
+
+// Export returns the structure of the schema; the tables, views, indexes and triggers are each sorted by name.
+// Objects of sqlite, and the tables named in skip, and their indexes and triggers, are left out.
+func Export(s Schema, skip ...string) (exported Exported, err error) {
+	include := IncludeFunc(skip...)
+	exported = Exported{
+		FormatVersion: ExportFormatVersion,
+		Name:          s.Name(),
+		Tables:        []ExportedTable{},
+		Views:         []ExportedView{},
+		Indexes:       []ExportedIndex{},
+		Triggers:      []ExportedTrigger{},
+	}
+
+	tables, err := s.Tables()
+	if err != nil {
+		return exported, err
+	}
+	for _, table := range tables {
+		if !include(table.Name()) {
+			continue
+		}
+		columns, err := table.Columns()
+		if err != nil {
+			return exported, err
+		}
+		keys, err := table.ForeignKeys()
+		if err != nil {
+			return exported, err
+		}
+		exported.Tables = append(exported.Tables, ExportedTable{
+			Name:        table.Name(),
+			Temporary:   table.Temporary(),
+			Columns:     exportColumns(columns),
+			ForeignKeys: exportForeignKeys(keys),
+			SQL:         table.SQL(),
+		})
+	}
+	sort.Slice(exported.Tables, func(i, j int) bool { return exported.Tables[i].Name < exported.Tables[j].Name })
+
+	views, err := s.Views()
+	if err != nil {
+		return exported, err
+	}
+	for _, view := range views {
+		if !include(view.Name()) {
+			continue
+		}
+		columns, err := view.Columns()
+		if err != nil {
+			return exported, err
+		}
+		exported.Views = append(exported.Views, ExportedView{Name: view.Name(), Columns: exportColumns(columns), SQL: view.SQL()})
+	}
+	sort.Slice(exported.Views, func(i, j int) bool { return exported.Views[i].Name < exported.Views[j].Name })
+
+	indexes, err := s.Indexes()
+	if err != nil {
+		return exported, err
+	}
+	for _, index := range indexes {
+		if !include(index.Name()) || !include(index.Table()) {
+			continue
+		}
+		columns, err := index.Columns()
+		if err != nil {
+			return exported, err
+		}
+		exportedIndex := ExportedIndex{Name: index.Name(), Table: index.Table(), Columns: []string{}, SQL: IndexSQL(index)}
+		for _, column := range columns {
+			exportedIndex.Columns = append(exportedIndex.Columns, column.Name())
+		}
+		if exportedIndex.SQL != "" {
+			statement, err := sqlparse.Parse(exportedIndex.SQL)
+			if err != nil {
+				return exported, fmt.Errorf("index %v: %w", index.Name(), err)
+			}
+			if createIndex, ok := statement.(*sqlparse.CreateIndex); ok {
+				exportedIndex.Unique, exportedIndex.Where = createIndex.Unique, createIndex.Where
+			}
+		}
+		exported.Indexes = append(exported.Indexes, exportedIndex)
+	}
+	sort.Slice(exported.Indexes, func(i, j int) bool { return exported.Indexes[i].Name < exported.Indexes[j].Name })
+
+	triggers, err := s.Triggers()
+	if err != nil {
+		return exported, err
+	}
+	for _, trigger := range triggers {
+		if include(trigger.Name()) && include(trigger.Table()) {
+			exported.Triggers = append(exported.Triggers, ExportedTrigger{Name: trigger.Name(), Table: trigger.Table(), SQL: trigger.SQL()})
+		}
+	}
+	sort.Slice(exported.Triggers, func(i, j int) bool { return exported.Triggers[i].Name < exported.Triggers[j].Name })
+	return exported, nil
+}
+
+// exportColumns returns the exported columns, in the order given
+func exportColumns(columns []Column) []ExportedColumn {
+	exported := make([]ExportedColumn, 0, len(columns))
+	for _, column := range columns {
+		_, primaryKey := column.IsPrimary()
+		exportedColumn := ExportedColumn{
+			Name:       column.Name(),
+			Type:       column.Type(),
+			Nullable:   column.Nullable(),
+			PrimaryKey: primaryKey,
+			Hidden:     column.Hidden(),
+		}
+		if value, err := column.Default(); err == nil && value != nil {
+			defaultValue := fmt.Sprintf("%s", value)
+			exportedColumn.Default = &defaultValue
+		}
+		if generated, ok := column.(GeneratedColumn); ok {
+			exportedColumn.Generated = generated.Generated()
+		}
+		exported = append(exported, exportedColumn)
+	}
+	return exported
+}
+
+// exportForeignKeys returns the exported foreign keys, joining the columns of each key
+func exportForeignKeys(keys []ForeignKey) []ExportedForeignKey {
+	exported := []ExportedForeignKey{}
+	byID := make(map[int]int)
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].ID() != keys[j].ID() {
+			return keys[i].ID() < keys[j].ID()
+		}
+		return keys[i].Seq() < keys[j].Seq()
+	})
+	for _, key := range keys {
+		i, ok := byID[key.ID()]
+		if !ok {
+			i = len(exported)
+			byID[key.ID()] = i
+			exported = append(exported, ExportedForeignKey{
+				Columns:   []string{},
+				ToTable:   key.ToTable(),
+				ToColumns: []string{},
+				OnUpdate:  key.OnUpdate(),
+				OnDelete:  key.OnDelete(),
+				Match:     key.Match(),
+			})
+		}
+		exported[i].Columns = append(exported[i].Columns, key.FromColumn())
+		exported[i].ToColumns = append(exported[i].ToColumns, key.ToColumn())
+	}
+	return exported
+}
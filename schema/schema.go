@@ -1,7 +1,10 @@
 // Package schema provide a general interface to obtain schema information from a database
 package schema
 
-import "database/sql"
+import (
+	"database/sql"
+	"strings"
+)
 
 type Databaser interface {
 	Database() *sql.DB
@@ -64,6 +67,9 @@ type Column interface {
 	Index() int
 	Type() string
 	// GoType should be the equivalent go type
+	//
+	// Deprecated: GoType only knows a few of the declared types. Use generator.GoStructs (migrate gen-go),
+	// which maps every declared type by its SQLite type affinity.
 	GoType() interface{}
 	// IsPrimary return true if the column is part of the Primary Key, with int being the index. Otherwise, bool will be false, and int will be -1
 	IsPrimary() (bool, int)
@@ -72,6 +78,12 @@ type Column interface {
 	Hidden() bool
 }
 
+// GeneratedColumn is implemented by columns that know if they are generated columns
+type GeneratedColumn interface {
+	// Generated returns VIRTUAL or STORED for a generated column, "" otherwise
+	Generated() string
+}
+
 type View interface {
 	NamedSQLer
 	Columns() ([]Column, error)
@@ -82,12 +94,22 @@ type Trigger interface {
 	Table() string
 }
 
+// Index describes an index; indexes that know the sql that created them also implement SQLer, see IndexSQL
 type Index interface {
 	Namer
 	Table() string
 	Columns() ([]Column, error)
 }
 
+// IndexSQL returns the sql that created the index, if the index implements SQLer; "" otherwise, as it is
+// for the indexes SQLite creates for UNIQUE and PRIMARY KEY constraints
+func IndexSQL(index Index) string {
+	if sqler, ok := index.(SQLer); ok {
+		return sqler.SQL()
+	}
+	return ""
+}
+
 type ForeignKey interface {
 	// ID is the ForeignKey ID
 	ID() int
@@ -101,3 +123,13 @@ type ForeignKey interface {
 	OnDelete() string
 	Match() string
 }
+
+// IncludeFunc returns a function that reports whether an object, or the table of an index or trigger, is
+// included; the objects of sqlite, and the tables named in skip, are not.
+func IncludeFunc(skip ...string) func(name string) bool {
+	skipped := make(map[string]bool, len(skip))
+	for _, name := range skip {
+		skipped[name] = true
+	}
+	return func(name string) bool { return !skipped[name] && !strings.HasPrefix(name, "sqlite_") }
+}
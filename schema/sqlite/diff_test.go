@@ -0,0 +1,99 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gdey/sqlite-migration/schema"
+	"github.com/gdey/sqlite-migration/schema/sqlite"
+)
+
+func newDiffDB(t *testing.T, name string, statements ...string) *sqlite.DB {
+	t.Helper()
+	db, err := sqlite.New(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatalf("new error, expected nil got %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, statement := range statements {
+		if _, err := db.DB.Exec(statement); err != nil {
+			t.Fatalf("exec %v, expected nil got %v", statement, err)
+		}
+	}
+	return db
+}
+
+func TestDiff(t *testing.T) {
+	common := []string{
+		`CREATE TABLE owners (id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE tracking (version TEXT)`,
+		`CREATE VIEW owner_ids AS SELECT id FROM owners`,
+	}
+	a := newDiffDB(t, "a.db", append(common,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)`,
+		`CREATE TABLE old (id INTEGER)`,
+		`CREATE INDEX users_name ON users (name)`,
+		`CREATE TRIGGER users_ai AFTER INSERT ON users BEGIN SELECT 1; END`,
+	)...)
+	b := newDiffDB(t, "b.db", append(common,
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY, -- the id
+			name TEXT NOT NULL DEFAULT '',
+			owner_id INTEGER REFERENCES owners(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE tracking2 (version TEXT)`,
+		`CREATE INDEX users_name ON users (name COLLATE NOCASE)`,
+		`CREATE INDEX users_owner ON users (owner_id)`,
+		`CREATE TRIGGER users_ai AFTER INSERT ON users BEGIN SELECT 1; END`,
+	)...)
+
+	changes, err := schema.Diff(a, b, "tracking", "tracking2")
+	if err != nil {
+		t.Fatalf("diff error, expected nil got %v", err)
+	}
+	expected := schema.Changes{
+		Tables: []schema.TableChange{
+			{Change: schema.Change{Kind: schema.Removed, Name: "old", From: "CREATE TABLE old (id INTEGER);"}},
+			{
+				Change: schema.Change{
+					Kind: schema.Changed,
+					Name: "users",
+					From: "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER);",
+					To:   "CREATE TABLE users ( id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '', owner_id INTEGER REFERENCES owners(id) ON DELETE CASCADE );",
+				},
+				Columns: []schema.Change{
+					{Kind: schema.Changed, Name: "name", From: "name TEXT", To: "name TEXT NOT NULL DEFAULT ''"},
+					{Kind: schema.Removed, Name: "age", From: "age INTEGER"},
+					{Kind: schema.Added, Name: "owner_id", To: "owner_id INTEGER"},
+				},
+				ForeignKeys: []schema.Change{
+					{Kind: schema.Added, Name: "(owner_id)", To: "(owner_id) REFERENCES owners(id) ON UPDATE NO ACTION ON DELETE CASCADE"},
+				},
+			},
+		},
+		Indexes: []schema.Change{
+			{
+				Kind: schema.Changed,
+				Name: "users_name",
+				From: "CREATE INDEX users_name ON users (name);",
+				To:   "CREATE INDEX users_name ON users (name COLLATE NOCASE);",
+			},
+			{Kind: schema.Added, Name: "users_owner", To: "CREATE INDEX users_owner ON users (owner_id);"},
+		},
+	}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("changes,\n\texpected %+v\n\t     got %+v", expected, changes)
+	}
+	if changes.Empty() {
+		t.Errorf("empty, expected false got true")
+	}
+
+	same, err := schema.Diff(a, a)
+	if err != nil {
+		t.Fatalf("diff error, expected nil got %v", err)
+	}
+	if !same.Empty() {
+		t.Errorf("diff with itself, expected no changes got\n%v", same)
+	}
+}
@@ -0,0 +1,106 @@
+package sqlite_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdey/sqlite-migration/schema"
+)
+
+func TestExport(t *testing.T) {
+	db := newDiffDB(t, "export.db",
+		`CREATE TABLE owners (id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE tracking (version TEXT)`,
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT 'none',
+			owner_id INTEGER REFERENCES owners,
+			lower_name AS (lower(name)),
+			name_length INTEGER AS (length(name)) STORED
+		)`,
+		`CREATE UNIQUE INDEX users_owner ON users (lower(name), owner_id) WHERE owner_id > 0`,
+		`CREATE VIEW owner_names AS SELECT owner_id, name FROM users`,
+		`CREATE TRIGGER users_ai AFTER INSERT ON users BEGIN SELECT 1; END`,
+	)
+	exported, err := schema.Export(db, "tracking")
+	if err != nil {
+		t.Fatalf("export error, expected nil got %v", err)
+	}
+	none := "'none'"
+	expected := schema.Exported{
+		FormatVersion: schema.ExportFormatVersion,
+		Name:          "main",
+		Tables: []schema.ExportedTable{
+			{
+				Name: "owners",
+				Columns: []schema.ExportedColumn{
+					{Name: "id", Type: "INTEGER", Nullable: true, PrimaryKey: 1},
+				},
+				ForeignKeys: []schema.ExportedForeignKey{},
+				SQL:         "CREATE TABLE owners (id INTEGER PRIMARY KEY);",
+			},
+			{
+				Name: "users",
+				Columns: []schema.ExportedColumn{
+					{Name: "id", Type: "INTEGER", Nullable: true, PrimaryKey: 1},
+					{Name: "name", Type: "TEXT", Default: &none},
+					{Name: "owner_id", Type: "INTEGER", Nullable: true},
+					{Name: "lower_name", Nullable: true, Generated: "VIRTUAL"},
+					{Name: "name_length", Type: "INTEGER", Nullable: true, Generated: "STORED"},
+				},
+				ForeignKeys: []schema.ExportedForeignKey{{
+					Columns:   []string{"owner_id"},
+					ToTable:   "owners",
+					ToColumns: []string{""},
+					OnUpdate:  "NO ACTION",
+					OnDelete:  "NO ACTION",
+					Match:     "NONE",
+				}},
+			},
+		},
+		Views: []schema.ExportedView{{
+			Name: "owner_names",
+			Columns: []schema.ExportedColumn{
+				{Name: "owner_id", Type: "INTEGER", Nullable: true},
+				{Name: "name", Type: "TEXT", Nullable: true},
+			},
+			SQL: "CREATE VIEW owner_names AS SELECT owner_id, name FROM users;",
+		}},
+		Indexes: []schema.ExportedIndex{{
+			Name:    "users_owner",
+			Table:   "users",
+			Unique:  true,
+			Columns: []string{"owner_id"},
+			Where:   "owner_id > 0",
+			SQL:     "CREATE UNIQUE INDEX users_owner ON users (lower(name), owner_id) WHERE owner_id > 0;",
+		}},
+		Triggers: []schema.ExportedTrigger{{
+			Name:  "users_ai",
+			Table: "users",
+			SQL:   "CREATE TRIGGER users_ai AFTER INSERT ON users BEGIN SELECT 1; END;",
+		}},
+	}
+	// the sql of users is as it was written, with its whitespace
+	if !strings.HasPrefix(exported.Tables[1].SQL, "CREATE TABLE users (") {
+		t.Errorf("users sql, expected CREATE TABLE users ( got %v", exported.Tables[1].SQL)
+	}
+	expected.Tables[1].SQL = exported.Tables[1].SQL
+	if !reflect.DeepEqual(expected, exported) {
+		t.Errorf("exported,\n\texpected %+v\n\t     got %+v", expected, exported)
+	}
+
+	// every field is present, and empty lists are not null
+	empty, err := schema.Export(newDiffDB(t, "empty.db"))
+	if err != nil {
+		t.Fatalf("export error, expected nil got %v", err)
+	}
+	body, err := json.Marshal(empty)
+	if err != nil {
+		t.Fatalf("marshal error, expected nil got %v", err)
+	}
+	if got := `{"format_version":1,"name":"main","tables":[],"views":[],"indexes":[],"triggers":[]}`; string(body) != got {
+		t.Errorf("json, expected %v got %s", got, body)
+	}
+}
@@ -17,20 +17,35 @@ const (
 	ObjectTypeView    = "view"
 )
 
+// ColumnType is the hidden value of PRAGMA table_xinfo
 type ColumnType int
 
 const (
 	ColumnTypeStandard = ColumnType(iota)
 	ColumnTypeHidden
-	ColumnTypeGeneratedStored
 	ColumnTypeGeneratedVirtual
+	ColumnTypeGeneratedStored
+)
+
+const (
+	// DefaultSchemaName is the name of the schema of the database file that was opened
+	DefaultSchemaName = "main"
+	// TempSchemaName is the name of the schema that holds temporary tables
+	TempSchemaName = "temp"
 )
 
 const (
-	sqliteObjSQL         = `select type, name, tbl_name, sql from main.sqlite_master where type=?;`
-	sqliteObjSQLForTable = `select type, name, tbl_name, sql from main.sqlite_master where type=? AND tbl_name=?`
+	sqliteObjSQL         = `select type, name, tbl_name, sql from %v.sqlite_master where type=?;`
+	sqliteObjSQLForTable = `select type, name, tbl_name, sql from %v.sqlite_master where type=? AND tbl_name=?`
+	databaseListSQL      = `pragma database_list;`
+	attachSQL            = `ATTACH DATABASE ? AS %v;`
 )
 
+// QuoteIdentifier will quote the name, so that it can be used as an identifier in an sql statement
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
 type database = *sql.DB
 
 func New(filename string) (*DB, error) {
@@ -51,6 +66,8 @@ type DB struct {
 	DB         database
 	DBName     string
 	schemaLock sync.Mutex
+	// schemata are the schemas that have been prepared, by name
+	schemata map[string]*Schema
 	*Schema
 }
 
@@ -73,14 +90,14 @@ func (db *DB) Close() error {
 	if db == nil {
 		return nil
 	}
-	err := db.Schema.objSQL.Close()
-	if err != nil {
-		return err
-	}
-	err = db.Schema.objSQLTable.Close()
-	if err != nil {
-		return err
+	db.schemaLock.Lock()
+	defer db.schemaLock.Unlock()
+	for _, s := range db.schemata {
+		if err := s.close(); err != nil {
+			return err
+		}
 	}
+	db.schemata = nil
 	return db.DB.Close()
 }
 
@@ -91,32 +108,102 @@ func (db *DB) Init() error {
 	if db.Schema != nil {
 		return nil
 	}
-	stmt, err := db.DB.Prepare(sqliteObjSQL)
+	s, err := db.schemaNamed(DefaultSchemaName)
 	if err != nil {
-		return fmt.Errorf("failed to prepare sql: %w", err)
-	}
-	stmtTable, err := db.DB.Prepare(sqliteObjSQLForTable)
-	if err != nil {
-		return fmt.Errorf("failed to prepare table sql: %w", err)
+		return err
 	}
-	db.Schema = &Schema{
-		db:          db.DB,
-		name:        "main",
-		objSQL:      stmt,
-		objSQLTable: stmtTable,
+	db.Schema = s
+	return nil
+}
+
+// Attach will attach the database file as the named schema. Attached databases only exist on the
+// connection that attached them, so the connection pool of the database is limited to a single connection.
+func (db *DB) Attach(name, filename string) error {
+	db.DB.SetMaxOpenConns(1)
+	if _, err := db.DB.Exec(fmt.Sprintf(attachSQL, QuoteIdentifier(name)), filename); err != nil {
+		return fmt.Errorf("failed to attach %v as %v: %w", filename, name, err)
 	}
 	return nil
 }
 
+// Schemata returns the schemas of the database; main, temp (if it is in use) and any attached databases,
+// as reported by pragma database_list.
 func (db *DB) Schemata() ([]schema.Schema, error) {
-	// TODO(gdey): should use pragma list_database to figure out all the available schemata
-	// For now we fake it and assume there is only one schema, the main one
 	if db.Schema == nil {
 		if err := db.Init(); err != nil {
 			return nil, err
 		}
 	}
-	return []schema.Schema{db.Schema}, nil
+	rows, err := db.DB.Query(databaseListSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var (
+			seq      int
+			name     string
+			filename string
+		)
+		if err = rows.Scan(&seq, &name, &filename); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	db.schemaLock.Lock()
+	defer db.schemaLock.Unlock()
+	schemata := make([]schema.Schema, 0, len(names))
+	for _, name := range names {
+		s, err := db.schemaNamed(name)
+		if err != nil {
+			return nil, err
+		}
+		schemata = append(schemata, s)
+	}
+	return schemata, nil
+}
+
+// SchemaNamed returns the schema with the given name, e.g. main, temp, or the name of an attached database.
+func (db *DB) SchemaNamed(name string) (*Schema, error) {
+	if name == "" {
+		name = DefaultSchemaName
+	}
+	db.schemaLock.Lock()
+	defer db.schemaLock.Unlock()
+	return db.schemaNamed(name)
+}
+
+// schemaNamed expects the schemaLock to be held
+func (db *DB) schemaNamed(name string) (*Schema, error) {
+	if s, ok := db.schemata[name]; ok {
+		return s, nil
+	}
+	stmt, err := db.DB.Prepare(fmt.Sprintf(sqliteObjSQL, QuoteIdentifier(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare sql for schema %v: %w", name, err)
+	}
+	stmtTable, err := db.DB.Prepare(fmt.Sprintf(sqliteObjSQLForTable, QuoteIdentifier(name)))
+	if err != nil {
+		stmt.Close()
+		return nil, fmt.Errorf("failed to prepare table sql for schema %v: %w", name, err)
+	}
+	s := &Schema{
+		db:          db.DB,
+		name:        name,
+		objSQL:      stmt,
+		objSQLTable: stmtTable,
+	}
+	if db.schemata == nil {
+		db.schemata = make(map[string]*Schema)
+	}
+	db.schemata[name] = s
+	return s, nil
 }
 
 type Schema struct {
@@ -126,6 +213,18 @@ type Schema struct {
 	objSQLTable *sql.Stmt
 }
 
+func (s *Schema) close() error {
+	if err := s.objSQL.Close(); err != nil {
+		return err
+	}
+	return s.objSQLTable.Close()
+}
+
+// pragma returns the sql for the pragma on the named object, in this schema
+func (s *Schema) pragma(pragma, name string) string {
+	return fmt.Sprintf(`pragma %v.%v( %v );`, QuoteIdentifier(s.name), pragma, QuoteIdentifier(name))
+}
+
 type RowScanner interface {
 	Scan(dest ...interface{}) error
 }
@@ -165,6 +264,7 @@ func (obj Object) AsIndex(s *Schema) Index {
 		schema:    s,
 		name:      obj.Name,
 		tableName: obj.TableName,
+		sql:       obj.SQL,
 	}
 }
 
@@ -256,7 +356,7 @@ type Table struct {
 	schema *Schema
 }
 
-func (tbl Table) Temporary() bool { return false }
+func (tbl Table) Temporary() bool { return tbl.schema.name == TempSchemaName }
 func (tbl Table) Name() string    { return tbl.name }
 func (tbl Table) SQL() string     { return tbl.sql }
 func (tbl Table) Triggers() (triggers []schema.Trigger, err error) {
@@ -290,9 +390,8 @@ func (tbl Table) Indexes() (indexes []schema.Index, err error) {
 	return indexes, nil
 }
 func (tbl Table) Columns() (cols []schema.Column, err error) {
-	const columnsSQL = `pragma table_xinfo( %v );`
 	db := tbl.schema.db
-	rows, err := db.Query(fmt.Sprintf(columnsSQL, tbl.name))
+	rows, err := db.Query(tbl.schema.pragma("table_xinfo", tbl.name))
 	if err != nil {
 		return nil, err
 	}
@@ -325,20 +424,20 @@ func (tbl Table) Columns() (cols []schema.Column, err error) {
 }
 
 func (tbl Table) ForeignKeys() (keys []schema.ForeignKey, err error) {
-	const columnsSQL = `pragma foreign_key_list(%v);`
 	db := tbl.schema.db
-	rows, err := db.Query(fmt.Sprintf(columnsSQL, tbl.name))
+	rows, err := db.Query(tbl.schema.pragma("foreign_key_list", tbl.name))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var (
-			id       int
-			seq      int
-			table    string
-			from     string
-			to       string
+			id    int
+			seq   int
+			table string
+			from  string
+			// to is NULL when the key references the primary key of the table
+			to       sql.NullString
 			onUpdate string
 			onDelete string
 			match    string
@@ -352,7 +451,7 @@ func (tbl Table) ForeignKeys() (keys []schema.ForeignKey, err error) {
 			fromTable: tbl.name,
 			from:      from,
 			toTable:   table,
-			to:        to,
+			to:        to.String,
 			onUpdate:  onUpdate,
 			onDelete:  onDelete,
 			match:     match,
@@ -382,6 +481,11 @@ func (col Column) Type() (typ string) {
 	}
 	return typ
 }
+
+// GoType returns a value of the go type for a few of the declared types, and "" for the others.
+//
+// Deprecated: use generator.GoStructs (migrate gen-go), which maps every declared type by its SQLite type
+// affinity; e.g. BIGINT is an int64, and a column without a declared type a []byte.
 func (col Column) GoType() interface{} {
 	switch col.Type() {
 	case "TEXT", "BLOB":
@@ -406,6 +510,18 @@ func (col Column) Default() (interface{}, error) { return col.defaultValue, nil
 func (col Column) Hidden() bool                  { return ColumnType(col.hidden) == ColumnTypeHidden }
 func (col Column) ColumnType() ColumnType        { return ColumnType(col.hidden) }
 
+// Generated returns VIRTUAL or STORED for a generated column, "" otherwise
+func (col Column) Generated() string {
+	switch col.ColumnType() {
+	case ColumnTypeGeneratedVirtual:
+		return "VIRTUAL"
+	case ColumnTypeGeneratedStored:
+		return "STORED"
+	default:
+		return ""
+	}
+}
+
 type Trigger struct {
 	schema    *Schema
 	name      string
@@ -448,9 +564,8 @@ type View struct {
 func (view View) Name() string { return view.name }
 func (view View) SQL() string  { return view.sql }
 func (view View) Columns() (cols []schema.Column, err error) {
-	const columnsSQL = `pragma table_xinfo( %v );`
 	db := view.schema.db
-	rows, err := db.Query(fmt.Sprintf(columnsSQL, view.name))
+	rows, err := db.Query(view.schema.pragma("table_xinfo", view.name))
 	if err != nil {
 		return nil, err
 	}
@@ -485,19 +600,23 @@ func (view View) Columns() (cols []schema.Column, err error) {
 type Index struct {
 	name      string
 	tableName string
+	sql       string
 	schema    *Schema
 }
 
 func (index Index) Name() string  { return index.name }
 func (index Index) Table() string { return index.tableName }
+
+// SQL is the sql used to create the index, it will be empty for indexes that are created
+// automatically for UNIQUE and PRIMARY KEY constraints.
+func (index Index) SQL() string { return index.sql }
 func (index Index) Columns() (cols []schema.Column, err error) {
 	db := index.schema.db
 
 	// first we need to get the list of columns that make up this index
 	// TODO(gdey): should we use the index_xinfo to get more info about the index columns
 	// REF: https://www.sqlite.org/pragma.html#pragma_index_xinfo
-	var indexColsSQL = fmt.Sprintf(`pragma index_info( %v )`, index.name)
-	rows, err := db.Query(indexColsSQL)
+	rows, err := db.Query(index.schema.pragma("index_info", index.name))
 	if err != nil {
 		return nil, err
 	}
@@ -507,12 +626,13 @@ func (index Index) Columns() (cols []schema.Column, err error) {
 		var (
 			seq  int // ignored
 			cid  int
-			name string // ignored
+			name sql.NullString // ignored, NULL for an expression
 		)
 		if err = rows.Scan(&seq, &cid, &name); err != nil {
 			return nil, err
 		}
-		if cid == -1 {
+		// -1 is the rowid, and -2 an expression
+		if cid < 0 {
 			continue
 		}
 		colNums = append(colNums, cid)
@@ -520,8 +640,7 @@ func (index Index) Columns() (cols []schema.Column, err error) {
 	cols = make([]schema.Column, len(colNums))
 
 	// we first have to get the table info;
-	const columnsSQL = `pragma table_xinfo( %v );`
-	rows, err = db.Query(fmt.Sprintf(columnsSQL, index.tableName))
+	rows, err = db.Query(index.schema.pragma("table_xinfo", index.tableName))
 	if err != nil {
 		return nil, err
 	}
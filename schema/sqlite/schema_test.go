@@ -1,6 +1,7 @@
 package sqlite_test
 
 import (
+	"github.com/gdey/sqlite-migration/schema"
 	"github.com/gdey/sqlite-migration/schema/internal/testdb"
 	"github.com/gdey/sqlite-migration/schema/sqlite"
 	"log"
@@ -78,3 +79,69 @@ func TestSqliteSchema(t *testing.T) {
 		log.Printf("%v: name:%v, table: %v", i, trigger.Name(), trigger.Table())
 	}
 }
+
+func TestSqliteSchemaAttached(t *testing.T) {
+	shouldCleanUp := true
+	filename, cleanup, tdb := testdb.New(t, &shouldCleanUp, "schema", "initial")
+	tdb.Close()
+	defer cleanup()
+	auxFilename, auxCleanup := testdb.NewFilename(t, &shouldCleanUp)
+	defer auxCleanup()
+
+	db, err := sqlite.New(filename)
+	if err != nil {
+		t.Fatalf("new error, expecting nil, got '%v'", err)
+	}
+	defer db.Close()
+	if err = db.Attach("aux", auxFilename); err != nil {
+		t.Fatalf("attach error, expecting nil, got '%v'", err)
+	}
+	if _, err = db.DB.Exec(`CREATE TABLE aux.things ( id INTEGER PRIMARY KEY, name TEXT ); CREATE INDEX aux.things_name ON things(name);`); err != nil {
+		t.Fatalf("create error, expecting nil, got '%v'", err)
+	}
+
+	schemata, err := db.Schemata()
+	if err != nil {
+		t.Fatalf("schemata error, expecting nil got %v", err)
+	}
+	var names []string
+	for _, s := range schemata {
+		names = append(names, s.Name())
+	}
+	if len(names) != 2 || names[0] != "main" || names[1] != "aux" {
+		t.Fatalf("schemata, expected [main aux] got %v", names)
+	}
+
+	aux, err := db.SchemaNamed("aux")
+	if err != nil {
+		t.Fatalf("schema named error, expecting nil got %v", err)
+	}
+	tables, err := aux.Tables()
+	if err != nil {
+		t.Fatalf("aux tables error, expecting nil got %v", err)
+	}
+	if len(tables) != 1 || tables[0].Name() != "things" {
+		t.Fatalf("aux tables, expected [things] got %v", tables)
+	}
+	cols, err := tables[0].Columns()
+	if err != nil {
+		t.Fatalf("aux columns error, expecting nil got %v", err)
+	}
+	if len(cols) != 2 {
+		t.Errorf("aux columns, expected 2 got %v", len(cols))
+	}
+	indexes, err := aux.Indexes()
+	if err != nil {
+		t.Fatalf("aux indexes error, expecting nil got %v", err)
+	}
+	if len(indexes) != 1 || schema.IndexSQL(indexes[0]) == "" {
+		t.Fatalf("aux indexes, expected things_name got %v", indexes)
+	}
+	if cols, err = indexes[0].Columns(); err != nil || len(cols) != 1 || cols[0].Name() != "name" {
+		t.Errorf("aux index columns, expected [name] got %v, %v", cols, err)
+	}
+
+	if _, err = db.SchemaNamed("missing"); err == nil {
+		t.Errorf("schema named missing error, expected error got nil")
+	}
+}
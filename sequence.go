@@ -0,0 +1,431 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SequenceFilename is the name of the file, in a migration directory, that lists the order
+	// in which the sql files should be applied.
+	SequenceFilename = "sequence.txt"
+
+	// DirectiveInclude is used in a sequence file to pull in the entries of another sequence file.
+	//   include path/to/other_sequence.txt
+	DirectiveInclude = "include"
+
+	// DirectiveBaseline is used in a sequence file for a sql file that replaces (squashes) a set of versions.
+	// New databases apply the baseline, databases that have applied all the squashed versions treat the baseline
+	// as applied.
+	//   baseline baseline.sql squashed.txt
+	DirectiveBaseline = "baseline"
+)
+
+// Ordering is how the sql files of a directory without a sequence file are ordered
+type Ordering int
+
+const (
+	// OrderBySequence requires a sequence file, unless the directory has only one sql file
+	OrderBySequence = Ordering(iota)
+	// OrderByFilename orders the sql files by their numeric (0001_) or timestamp (20240101120000_) prefix.
+	// Numeric prefixes must not have gaps, and no two files may share a prefix.
+	OrderByFilename
+)
+
+// sequenceLine is a non-comment line of a sequence file
+type sequenceLine struct {
+	// LineNo is the line number in the file, starting at 1
+	LineNo int
+	// Text is the line stripped of extra spaces
+	Text string
+}
+
+// readSequenceLines will split the provided file by newlines, skipping empty lines, and
+// lines that begin with Octothorpe(#) and return the lines in order.
+func readSequenceLines(f io.Reader) (lines []sequenceLine) {
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		lines = append(lines, sequenceLine{LineNo: lineNo, Text: line})
+	}
+	return lines
+}
+
+// sequenceDirective is a parsed sequence line
+type sequenceDirective struct {
+	// Directive is the directive for the line, or "" if the line is a plain entry
+	Directive string
+	// Value is the argument to the directive or the entry itself
+	Value string
+	// Environments the line is tagged with (without the @), empty means all environments
+	Environments []string
+	// Squashed is the sequence file of the versions squashed into a baseline
+	Squashed string
+}
+
+var environmentTagRegexp = regexp.MustCompile(`^@[a-zA-Z0-9_-]+$`)
+
+// parseSequenceLine will parse a line of a sequence file, returning the reason the line is
+// not well formed if it is not. Any line may end with environment tags (@dev @test). A line that is
+// not a directive, and is not tagged, is a filename, which may have spaces in it.
+func parseSequenceLine(line string) (directive sequenceDirective, reason string) {
+	fields := strings.Fields(line)
+	// pull of the environment tags from the end of the line
+	for len(fields) > 1 && strings.HasPrefix(fields[len(fields)-1], "@") {
+		tag := fields[len(fields)-1]
+		if !environmentTagRegexp.MatchString(tag) {
+			return directive, fmt.Sprintf("invalid environment tag `%v`", tag)
+		}
+		directive.Environments = append([]string{tag[1:]}, directive.Environments...)
+		fields = fields[:len(fields)-1]
+	}
+	switch {
+	case len(fields) == 0:
+		return directive, "empty entry"
+	case strings.HasPrefix(fields[0], "@"):
+		return directive, "environment tags must follow an entry"
+	case fields[0] == DirectiveInclude:
+		if len(fields) != 2 {
+			return directive, "include expects exactly one sequence file"
+		}
+		directive.Directive, directive.Value = DirectiveInclude, fields[1]
+		return directive, ""
+	case fields[0] == DirectiveBaseline:
+		if len(fields) != 3 {
+			return directive, "baseline expects a sql file and the sequence file of the versions it replaces"
+		}
+		directive.Directive, directive.Value, directive.Squashed = DirectiveBaseline, fields[1], fields[2]
+		return directive, ""
+	case len(fields) != 1 && len(directive.Environments) == 0:
+		directive.Value = strings.TrimSpace(line)
+		return directive, ""
+	case len(fields) != 1:
+		return directive, fmt.Sprintf("unknown directive `%v`", fields[0])
+	default:
+		directive.Value = fields[0]
+		return directive, ""
+	}
+}
+
+// combineEnvironments returns the environments of an entry tagged with inner, that was included by an entry
+// tagged with outer. Untagged means all environments, otherwise the entry is only for the environments in both.
+func combineEnvironments(outer, inner []string) []string {
+	if len(outer) == 0 {
+		return inner
+	}
+	if len(inner) == 0 {
+		return outer
+	}
+	var combined []string
+	for _, env := range inner {
+		for _, oEnv := range outer {
+			if env == oEnv {
+				combined = append(combined, env)
+				break
+			}
+		}
+	}
+	if len(combined) == 0 {
+		// not for any environment, but we need to keep it tagged so that it is never applied.
+		return []string{""}
+	}
+	return combined
+}
+
+// versionEntry is a version along with where it was declared
+type versionEntry struct {
+	// Name of the version, this is the path of the sql file relative to the manager's dir
+	Name string
+	// Source is the sequence file (relative to the manager's dir) the entry was listed in,
+	// it will be empty if the version was discovered in a directory without a sequence file.
+	Source string
+	// LineNo is the line in the Source file
+	LineNo int
+	// Environments the entry should be applied in, empty means all environments
+	Environments []string
+	// Squashed are the versions that were squashed into this baseline entry
+	Squashed []versionEntry
+}
+
+// squashedNames returns the names of all the versions squashed into the entry, including those squashed
+// into earlier baselines.
+func (entry versionEntry) squashedNames() (names []string) {
+	for _, squashed := range entry.Squashed {
+		names = append(names, squashed.Name)
+		names = append(names, squashed.squashedNames()...)
+	}
+	return names
+}
+
+// versionCount returns the number of versions the entry stands for; a baseline counts the versions
+// squashed into it, and not the earlier baselines it replaces.
+func (entry versionEntry) versionCount() (count int) {
+	if len(entry.Squashed) == 0 {
+		return 1
+	}
+	for _, squashed := range entry.Squashed {
+		count += squashed.versionCount()
+	}
+	return count
+}
+
+// sequenceWalk collects the entries, and any problems found, while expanding the sequence files
+type sequenceWalk struct {
+	mng      *Manager
+	entries  []versionEntry
+	problems []error
+}
+
+func (walk *sequenceWalk) problem(err error) { walk.problems = append(walk.problems, err) }
+
+// dir will add the versions for the directory (relative to the manager's dir),
+// the names will be prefixed with the directory. If the directory contains a sequence file
+// it will be used; otherwise the sql files are ordered by filename if the manager is set to OrderByFilename,
+// or the directory must contain exactly one sql file.
+func (walk *sequenceWalk) dir(dir string, stack []string, envs []string) {
+	mng := walk.mng
+	seqFile := path.Join(dir, SequenceFilename)
+	if mng.exists(filepath.Join(mng.dir, seqFile)) {
+		walk.sequence(seqFile, stack, envs)
+		return
+	}
+
+	// There is no version file, so let's see if
+	// there is only one SQLFile, if so that is our version.
+	versionDir := filepath.Join(mng.dir, dir)
+	dirFiles, err := mng.sqlFilesIn(versionDir)
+	if err != nil {
+		walk.problem(err)
+		return
+	}
+	// repeatable and down files are never part of the sequence
+	var sqlFiles []string
+	for _, name := range dirFiles {
+		if !isRepeatable(path.Join(dir, name)) && !isDownFile(name) {
+			sqlFiles = append(sqlFiles, name)
+		}
+	}
+	if mng.Ordering() == OrderByFilename {
+		walk.filenameOrdered(dir, sqlFiles, envs)
+		return
+	}
+	switch len(sqlFiles) {
+	case 0:
+		walk.problem(fmt.Errorf("directory %v does not contain %v or any sql files", versionDir, SequenceFilename))
+	case 1:
+		walk.entries = append(walk.entries, versionEntry{Name: path.Join(dir, sqlFiles[0]), Environments: envs})
+	default:
+		walk.problem(fmt.Errorf("directory %v does not contain %v to indicate order to apply sql files", versionDir, SequenceFilename))
+	}
+}
+
+// sequence will expand the given sequence file (relative to the manager's dir) into
+// the list of versions. Entries are relative to the directory of the sequence file, but the
+// versions are relative to the manager's dir, so that they stay stable and unique.
+func (walk *sequenceWalk) sequence(seqFile string, stack []string, envs []string) {
+	mng := walk.mng
+	seqFile = path.Clean(seqFile)
+	for i := range stack {
+		if stack[i] == seqFile {
+			walk.problem(ErrSequenceCycle(append(append([]string{}, stack[i:]...), seqFile)))
+			return
+		}
+	}
+	stack = append(stack, seqFile)
+
+	filename := filepath.Join(mng.dir, seqFile)
+	f, err := mng.FS().Open(filename)
+	if err != nil {
+		walk.problem(fmt.Errorf("failed to open sequence file %v: %w", filename, err))
+		return
+	}
+	defer f.Close()
+
+	for _, line := range readSequenceLines(f) {
+		walk.line(seqFile, line, stack, envs)
+	}
+}
+
+// line will add the versions for a single line of the given sequence file
+func (walk *sequenceWalk) line(seqFile string, line sequenceLine, stack []string, envs []string) {
+	var (
+		mng           = walk.mng
+		filename      = filepath.Join(mng.dir, seqFile)
+		dir           = path.Dir(seqFile)
+		entry, reason = parseSequenceLine(line.Text)
+		name          = path.Join(dir, entry.Value)
+		squashed      = path.Join(dir, entry.Squashed)
+	)
+	switch {
+	case reason != "":
+	case !fs.ValidPath(name) || !fs.ValidPath(squashed):
+		reason = "path is outside of the migration directory"
+	case isRepeatable(name):
+		reason = "repeatable files are applied automatically, and can not be part of the sequence"
+	case isBackground(name):
+		reason = "background files are applied by RunBackground, and can not be part of the sequence"
+	case isDownFile(name):
+		reason = "down files revert a version, and can not be part of the sequence"
+	}
+	if reason != "" {
+		walk.problem(ErrInvalidSequenceEntry{
+			Filename: filename,
+			LineNo:   line.LineNo,
+			Line:     line.Text,
+			Reason:   reason,
+		})
+		return
+	}
+	entryEnvs := combineEnvironments(envs, entry.Environments)
+	switch {
+	case entry.Directive == DirectiveInclude:
+		walk.sequence(name, stack, entryEnvs)
+	case entry.Directive == DirectiveBaseline:
+		// the squashed versions are collected separately, as they are not part of the sequence
+		// for new databases.
+		squashedWalk := sequenceWalk{mng: mng}
+		squashedWalk.sequence(squashed, stack, entryEnvs)
+		walk.problems = append(walk.problems, squashedWalk.problems...)
+		walk.entries = append(walk.entries, versionEntry{
+			Name:         name,
+			Source:       seqFile,
+			LineNo:       line.LineNo,
+			Environments: entryEnvs,
+			Squashed:     squashedWalk.entries,
+		})
+	case mng.isDir(filepath.Join(mng.dir, name)):
+		walk.dir(name, stack, entryEnvs)
+	default:
+		walk.entries = append(walk.entries, versionEntry{
+			Name:         name,
+			Source:       seqFile,
+			LineNo:       line.LineNo,
+			Environments: entryEnvs,
+		})
+	}
+}
+
+// filenameOrdered will add the sql files, of a directory without a sequence file, ordered by their
+// numeric (0001_) or timestamp (20240101120000_) prefix.
+func (walk *sequenceWalk) filenameOrdered(dir string, sqlFiles []string, envs []string) {
+	versionDir := filepath.Join(walk.mng.dir, dir)
+	if len(sqlFiles) == 0 {
+		walk.problem(fmt.Errorf("directory %v does not contain %v or any sql files", versionDir, SequenceFilename))
+		return
+	}
+	type prefixedFile struct {
+		name   string
+		number uint64
+	}
+	var (
+		files       []prefixedFile
+		unmatched   []string
+		isTimestamp = true
+	)
+	for _, name := range sqlFiles {
+		matches := filenamePrefixRegexp.FindStringSubmatch(name)
+		if matches == nil {
+			unmatched = append(unmatched, name)
+			continue
+		}
+		number, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			unmatched = append(unmatched, name)
+			continue
+		}
+		if _, err := time.Parse(timestampPrefixLayout, matches[1]); err != nil || len(matches[1]) != len(timestampPrefixLayout) {
+			isTimestamp = false
+		}
+		files = append(files, prefixedFile{name: name, number: number})
+	}
+	if len(unmatched) != 0 {
+		walk.problem(ErrFilenameConvention{Dir: versionDir, Filenames: unmatched})
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].number < files[j].number })
+	for i := range files {
+		walk.entries = append(walk.entries, versionEntry{Name: path.Join(dir, files[i].name), Environments: envs})
+		if i == 0 {
+			continue
+		}
+		prev, cur := files[i-1], files[i]
+		switch {
+		case prev.number == cur.number:
+			walk.problem(ErrDuplicatePrefix{Dir: versionDir, Filenames: []string{prev.name, cur.name}})
+		case !isTimestamp && cur.number != prev.number+1:
+			walk.problem(ErrPrefixGap{Dir: versionDir, After: prev.name, Next: cur.name})
+		}
+	}
+}
+
+var filenamePrefixRegexp = regexp.MustCompile(`^([0-9]+)_[^/]+\.sql(?:\.tpl)?$`)
+
+const timestampPrefixLayout = "20060102150405"
+
+// versionEntries will expand the sequence files into the list of versions (not including the InitialVersion),
+// along with any problems found along the way.
+func (mng *Manager) versionEntries() ([]versionEntry, []error) {
+	walk := sequenceWalk{mng: mng}
+	walk.dir(".", nil, nil)
+
+	seen := make(map[string]bool, len(walk.entries))
+	for _, entry := range walk.entries {
+		for _, name := range append([]string{entry.Name}, entry.squashedNames()...) {
+			if seen[name] {
+				walk.problem(ErrDuplicateVersion(name))
+			}
+			seen[name] = true
+		}
+	}
+	return walk.entries, walk.problems
+}
+
+// isDir will return true if the given name is a directory in the manager's FS
+func (mng *Manager) isDir(name string) bool {
+	info, err := fs.Stat(mng.FS(), name)
+	return err == nil && info.IsDir()
+}
+
+// exists will return true if the given name exists in the manager's FS
+func (mng *Manager) exists(name string) bool {
+	_, err := fs.Stat(mng.FS(), name)
+	return err == nil
+}
+
+// sqlFilesIn will return the sorted list of sql and sql template files in the given directory
+func (mng *Manager) sqlFilesIn(dir string) ([]string, error) {
+	entries, err := fs.ReadDir(mng.FS(), dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", dir, err)
+	}
+	var sqlFiles []string
+	for _, e := range entries {
+		if e.Type()&(fs.ModeDir|fs.ModeNamedPipe|fs.ModeSocket|fs.ModeDevice|fs.ModeCharDevice|fs.ModeIrregular) != 0 { // only care about regular files
+			continue
+		}
+		name := e.Name()
+		if isSQLFile(name) {
+			sqlFiles = append(sqlFiles, name)
+		}
+	}
+	sort.Strings(sqlFiles)
+	return sqlFiles, nil
+}
+
+// isSQLFile returns true if the name looks like an sql file or an sql template file
+func isSQLFile(name string) bool {
+	return strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".sql.tpl")
+}
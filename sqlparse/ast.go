@@ -0,0 +1,462 @@
+package sqlparse
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Statement is a parsed CREATE statement; String renders it back to sql, which parses to the same statement
+type Statement interface {
+	String() string
+	statement()
+}
+
+// QualifiedName is a name, optionally qualified by a schema; e.g. main.users
+type QualifiedName struct {
+	Schema string
+	Name   string
+}
+
+func (name QualifiedName) String() string {
+	if name.Schema == "" {
+		return QuoteIdentifier(name.Name)
+	}
+	return QuoteIdentifier(name.Schema) + "." + QuoteIdentifier(name.Name)
+}
+
+// CreateTable is a CREATE TABLE statement
+type CreateTable struct {
+	Temporary   bool
+	IfNotExists bool
+	Name        QualifiedName
+	Columns     []ColumnDef
+	Constraints []TableConstraint
+	// AsSelect is the select, of a CREATE TABLE ... AS SELECT; which has no columns or constraints
+	AsSelect     string
+	WithoutRowID bool
+	Strict       bool
+}
+
+func (*CreateTable) statement() {}
+
+// Column returns the column with the name, ignoring case; nil if there is not one
+func (table *CreateTable) Column(name string) *ColumnDef {
+	for i := range table.Columns {
+		if strings.EqualFold(table.Columns[i].Name, name) {
+			return &table.Columns[i]
+		}
+	}
+	return nil
+}
+
+func (table *CreateTable) String() string {
+	var sql strings.Builder
+	sql.WriteString("CREATE ")
+	if table.Temporary {
+		sql.WriteString("TEMP ")
+	}
+	sql.WriteString("TABLE ")
+	if table.IfNotExists {
+		sql.WriteString("IF NOT EXISTS ")
+	}
+	sql.WriteString(table.Name.String())
+	if table.AsSelect != "" {
+		sql.WriteString(" AS " + table.AsSelect)
+		return sql.String()
+	}
+	sql.WriteString(" (\n")
+	definitions := make([]string, 0, len(table.Columns)+len(table.Constraints))
+	for _, column := range table.Columns {
+		definitions = append(definitions, "  "+column.String())
+	}
+	for _, constraint := range table.Constraints {
+		definitions = append(definitions, "  "+constraint.String())
+	}
+	sql.WriteString(strings.Join(definitions, ",\n"))
+	sql.WriteString("\n)")
+	var options []string
+	if table.WithoutRowID {
+		options = append(options, "WITHOUT ROWID")
+	}
+	if table.Strict {
+		options = append(options, "STRICT")
+	}
+	if len(options) != 0 {
+		sql.WriteString(" " + strings.Join(options, ", "))
+	}
+	return sql.String()
+}
+
+// ColumnDef is the definition of a column of a CREATE TABLE
+type ColumnDef struct {
+	Name string
+	// Type is the declared type, "" if there is not one; e.g. VARCHAR(255)
+	Type        string
+	Constraints []ColumnConstraint
+}
+
+// Constraint returns the first constraint of the kind, nil if there is not one
+func (column *ColumnDef) Constraint(kind ConstraintKind) *ColumnConstraint {
+	for i := range column.Constraints {
+		if column.Constraints[i].Kind == kind {
+			return &column.Constraints[i]
+		}
+	}
+	return nil
+}
+
+func (column ColumnDef) String() string {
+	parts := []string{QuoteIdentifier(column.Name)}
+	if column.Type != "" {
+		parts = append(parts, column.Type)
+	}
+	for _, constraint := range column.Constraints {
+		parts = append(parts, constraint.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// ConstraintKind is the kind of a column, or table, constraint
+type ConstraintKind string
+
+const (
+	ConstraintPrimaryKey = ConstraintKind("PRIMARY KEY")
+	ConstraintNotNull    = ConstraintKind("NOT NULL")
+	// ConstraintNull is the NULL column constraint, which SQLite accepts and ignores
+	ConstraintNull      = ConstraintKind("NULL")
+	ConstraintUnique    = ConstraintKind("UNIQUE")
+	ConstraintCheck     = ConstraintKind("CHECK")
+	ConstraintDefault   = ConstraintKind("DEFAULT")
+	ConstraintCollate   = ConstraintKind("COLLATE")
+	ConstraintGenerated = ConstraintKind("GENERATED")
+	// ConstraintForeignKey is a REFERENCES column constraint, or a FOREIGN KEY table constraint
+	ConstraintForeignKey = ConstraintKind("FOREIGN KEY")
+)
+
+// ColumnConstraint is a constraint of a column definition
+type ColumnConstraint struct {
+	// Name is the name given with CONSTRAINT name, "" if there is not one
+	Name string
+	Kind ConstraintKind
+	// Order is ASC or DESC, of a PRIMARY KEY
+	Order string
+	// OnConflict is the conflict resolution of a PRIMARY KEY, NOT NULL or UNIQUE; e.g. REPLACE
+	OnConflict    string
+	AutoIncrement bool
+	// Expr is the expression of a CHECK or generated column, or the value of a DEFAULT; as it was written.
+	// The expression of a CHECK or generated column does not include its parentheses, the value of a DEFAULT
+	// does.
+	Expr string
+	// Collation of a COLLATE
+	Collation string
+	// Stored is true for a STORED generated column, false for a VIRTUAL one
+	Stored bool
+	// ForeignKey of a REFERENCES
+	ForeignKey *ForeignKeyClause
+}
+
+func (constraint ColumnConstraint) String() string {
+	var parts []string
+	if constraint.Name != "" {
+		parts = append(parts, "CONSTRAINT "+QuoteIdentifier(constraint.Name))
+	}
+	switch constraint.Kind {
+	case ConstraintPrimaryKey:
+		parts = append(parts, "PRIMARY KEY")
+		if constraint.Order != "" {
+			parts = append(parts, constraint.Order)
+		}
+		if constraint.OnConflict != "" {
+			parts = append(parts, "ON CONFLICT "+constraint.OnConflict)
+		}
+		if constraint.AutoIncrement {
+			parts = append(parts, "AUTOINCREMENT")
+		}
+	case ConstraintNotNull, ConstraintUnique:
+		parts = append(parts, string(constraint.Kind))
+		if constraint.OnConflict != "" {
+			parts = append(parts, "ON CONFLICT "+constraint.OnConflict)
+		}
+	case ConstraintNull:
+		parts = append(parts, "NULL")
+	case ConstraintCheck:
+		parts = append(parts, "CHECK ("+constraint.Expr+")")
+	case ConstraintDefault:
+		parts = append(parts, "DEFAULT "+constraint.Expr)
+	case ConstraintCollate:
+		parts = append(parts, "COLLATE "+QuoteIdentifier(constraint.Collation))
+	case ConstraintGenerated:
+		parts = append(parts, "GENERATED ALWAYS AS ("+constraint.Expr+")")
+		if constraint.Stored {
+			parts = append(parts, "STORED")
+		} else {
+			parts = append(parts, "VIRTUAL")
+		}
+	case ConstraintForeignKey:
+		parts = append(parts, constraint.ForeignKey.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// TableConstraint is a constraint of a CREATE TABLE
+type TableConstraint struct {
+	// Name is the name given with CONSTRAINT name, "" if there is not one
+	Name string
+	// Kind is ConstraintPrimaryKey, ConstraintUnique, ConstraintCheck or ConstraintForeignKey
+	Kind ConstraintKind
+	// Columns of a PRIMARY KEY or UNIQUE
+	Columns []IndexedColumn
+	// OnConflict is the conflict resolution of a PRIMARY KEY or UNIQUE
+	OnConflict string
+	// Expr is the expression of a CHECK, without its parentheses
+	Expr string
+	// FromColumns, and ForeignKey, of a FOREIGN KEY
+	FromColumns []string
+	ForeignKey  *ForeignKeyClause
+}
+
+func (constraint TableConstraint) String() string {
+	var sql strings.Builder
+	if constraint.Name != "" {
+		sql.WriteString("CONSTRAINT " + QuoteIdentifier(constraint.Name) + " ")
+	}
+	switch constraint.Kind {
+	case ConstraintPrimaryKey, ConstraintUnique:
+		sql.WriteString(string(constraint.Kind) + " (" + indexedColumnsString(constraint.Columns) + ")")
+		if constraint.OnConflict != "" {
+			sql.WriteString(" ON CONFLICT " + constraint.OnConflict)
+		}
+	case ConstraintCheck:
+		sql.WriteString("CHECK (" + constraint.Expr + ")")
+	case ConstraintForeignKey:
+		sql.WriteString("FOREIGN KEY (" + identifiersString(constraint.FromColumns) + ") " + constraint.ForeignKey.String())
+	}
+	return sql.String()
+}
+
+// ForeignKeyClause is the REFERENCES clause of a foreign key
+type ForeignKeyClause struct {
+	Table string
+	// Columns of the table, empty for its primary key
+	Columns []string
+	// OnDelete and OnUpdate are the actions; e.g. CASCADE or SET NULL, "" if not given
+	OnDelete string
+	OnUpdate string
+	// Match is the name of a MATCH clause, which SQLite ignores
+	Match string
+	// Deferrable is the deferrable clause; e.g. DEFERRABLE INITIALLY DEFERRED, "" if not given
+	Deferrable string
+}
+
+func (clause *ForeignKeyClause) String() string {
+	if clause == nil {
+		return ""
+	}
+	sql := "REFERENCES " + QuoteIdentifier(clause.Table)
+	if len(clause.Columns) != 0 {
+		sql += " (" + identifiersString(clause.Columns) + ")"
+	}
+	if clause.OnDelete != "" {
+		sql += " ON DELETE " + clause.OnDelete
+	}
+	if clause.OnUpdate != "" {
+		sql += " ON UPDATE " + clause.OnUpdate
+	}
+	if clause.Match != "" {
+		sql += " MATCH " + QuoteIdentifier(clause.Match)
+	}
+	if clause.Deferrable != "" {
+		sql += " " + clause.Deferrable
+	}
+	return sql
+}
+
+// IndexedColumn is a column, or expression, of an index, PRIMARY KEY or UNIQUE
+type IndexedColumn struct {
+	// Name of the column; "" if it is an expression
+	Name string
+	// Expr is the expression, as it was written; "" if it is a column
+	Expr      string
+	Collation string
+	// Order is ASC or DESC, "" if not given
+	Order string
+}
+
+func (column IndexedColumn) String() string {
+	sql := column.Expr
+	if column.Name != "" {
+		sql = QuoteIdentifier(column.Name)
+	}
+	if column.Collation != "" {
+		sql += " COLLATE " + QuoteIdentifier(column.Collation)
+	}
+	if column.Order != "" {
+		sql += " " + column.Order
+	}
+	return sql
+}
+
+// CreateIndex is a CREATE INDEX statement
+type CreateIndex struct {
+	Unique      bool
+	IfNotExists bool
+	Name        QualifiedName
+	Table       string
+	Columns     []IndexedColumn
+	// Where is the expression of a partial index, "" if it is not one
+	Where string
+}
+
+func (*CreateIndex) statement() {}
+
+func (index *CreateIndex) String() string {
+	var sql strings.Builder
+	sql.WriteString("CREATE ")
+	if index.Unique {
+		sql.WriteString("UNIQUE ")
+	}
+	sql.WriteString("INDEX ")
+	if index.IfNotExists {
+		sql.WriteString("IF NOT EXISTS ")
+	}
+	sql.WriteString(index.Name.String() + " ON " + QuoteIdentifier(index.Table) + " (" + indexedColumnsString(index.Columns) + ")")
+	if index.Where != "" {
+		sql.WriteString(" WHERE " + index.Where)
+	}
+	return sql.String()
+}
+
+// CreateView is a CREATE VIEW statement
+type CreateView struct {
+	Temporary   bool
+	IfNotExists bool
+	Name        QualifiedName
+	// Columns are the names given to the columns of the view, empty if they are not given
+	Columns []string
+	// Select is the select of the view, as it was written
+	Select string
+}
+
+func (*CreateView) statement() {}
+
+func (view *CreateView) String() string {
+	var sql strings.Builder
+	sql.WriteString("CREATE ")
+	if view.Temporary {
+		sql.WriteString("TEMP ")
+	}
+	sql.WriteString("VIEW ")
+	if view.IfNotExists {
+		sql.WriteString("IF NOT EXISTS ")
+	}
+	sql.WriteString(view.Name.String())
+	if len(view.Columns) != 0 {
+		sql.WriteString(" (" + identifiersString(view.Columns) + ")")
+	}
+	sql.WriteString(" AS " + view.Select)
+	return sql.String()
+}
+
+// CreateTrigger is a CREATE TRIGGER statement
+type CreateTrigger struct {
+	Temporary   bool
+	IfNotExists bool
+	Name        QualifiedName
+	// Time is BEFORE, AFTER or INSTEAD OF; "" if not given
+	Time string
+	// Event is DELETE, INSERT or UPDATE
+	Event string
+	// Columns of an UPDATE OF
+	Columns    []string
+	Table      string
+	ForEachRow bool
+	// When is the expression of the WHEN clause, "" if there is not one
+	When string
+	// Body is the statements of the trigger, as they were written, without their ;
+	Body []string
+}
+
+func (*CreateTrigger) statement() {}
+
+func (trigger *CreateTrigger) String() string {
+	var sql strings.Builder
+	sql.WriteString("CREATE ")
+	if trigger.Temporary {
+		sql.WriteString("TEMP ")
+	}
+	sql.WriteString("TRIGGER ")
+	if trigger.IfNotExists {
+		sql.WriteString("IF NOT EXISTS ")
+	}
+	sql.WriteString(trigger.Name.String() + " ")
+	if trigger.Time != "" {
+		sql.WriteString(trigger.Time + " ")
+	}
+	sql.WriteString(trigger.Event)
+	if len(trigger.Columns) != 0 {
+		sql.WriteString(" OF " + identifiersString(trigger.Columns))
+	}
+	sql.WriteString(" ON " + QuoteIdentifier(trigger.Table))
+	if trigger.ForEachRow {
+		sql.WriteString(" FOR EACH ROW")
+	}
+	if trigger.When != "" {
+		sql.WriteString(" WHEN " + trigger.When)
+	}
+	sql.WriteString(" BEGIN\n")
+	for _, statement := range trigger.Body {
+		sql.WriteString("  " + statement + ";\n")
+	}
+	sql.WriteString("END")
+	return sql.String()
+}
+
+func indexedColumnsString(columns []IndexedColumn) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func identifiersString(names []string) string {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = QuoteIdentifier(name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+var bareIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QuoteIdentifier returns the name, quoted if it is a keyword or is not a plain identifier
+func QuoteIdentifier(name string) string {
+	if bareIdentifierRegexp.MatchString(name) && !IsKeyword(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// IsKeyword returns true if the name is an SQLite keyword, ignoring case
+func IsKeyword(name string) bool { return keywords[strings.ToUpper(name)] }
+
+// keywords are the keywords of SQLite, see https://sqlite.org/lang_keywords.html
+var keywords = func() map[string]bool {
+	words := strings.Fields(`
+	ABORT ACTION ADD AFTER ALL ALTER ALWAYS ANALYZE AND AS ASC ATTACH AUTOINCREMENT BEFORE BEGIN BETWEEN BY
+	CASCADE CASE CAST CHECK COLLATE COLUMN COMMIT CONFLICT CONSTRAINT CREATE CROSS CURRENT CURRENT_DATE
+	CURRENT_TIME CURRENT_TIMESTAMP DATABASE DEFAULT DEFERRABLE DEFERRED DELETE DESC DETACH DISTINCT DO DROP
+	EACH ELSE END ESCAPE EXCEPT EXCLUDE EXCLUSIVE EXISTS EXPLAIN FAIL FILTER FIRST FOLLOWING FOR FOREIGN FROM
+	FULL GENERATED GLOB GROUP GROUPS HAVING IF IGNORE IMMEDIATE IN INDEX INDEXED INITIALLY INNER INSERT
+	INSTEAD INTERSECT INTO IS ISNULL JOIN KEY LAST LEFT LIKE LIMIT MATCH MATERIALIZED NATURAL NO NOT NOTHING
+	NOTNULL NULL NULLS OF OFFSET ON OR ORDER OTHERS OUTER OVER PARTITION PLAN PRAGMA PRECEDING PRIMARY QUERY
+	RAISE RANGE RECURSIVE REFERENCES REGEXP REINDEX RELEASE RENAME REPLACE RESTRICT RETURNING RIGHT ROLLBACK
+	ROW ROWS SAVEPOINT SELECT SET TABLE TEMP TEMPORARY THEN TIES TO TRANSACTION TRIGGER UNBOUNDED UNION
+	UNIQUE UPDATE USING VACUUM VALUES VIEW VIRTUAL WHEN WHERE WINDOW WITH WITHOUT
+	`)
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}()
@@ -0,0 +1,717 @@
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse parses a single CREATE TABLE, INDEX, VIEW or TRIGGER statement; a trailing ; is allowed.
+// The statement returned is a *CreateTable, *CreateIndex, *CreateView or *CreateTrigger.
+func Parse(sql string) (Statement, error) {
+	statements, err := SplitStatements(sql)
+	if err != nil {
+		return nil, err
+	}
+	switch len(statements) {
+	case 0:
+		return nil, Error{Line: 1, Column: 1, Msg: "expected a statement"}
+	case 1:
+	default:
+		return nil, Error{Line: statements[1].Line, Column: statements[1].Column, Msg: "expected a single statement"}
+	}
+	p := newParser(sql, statements[0].Tokens)
+	return p.parseCreate()
+}
+
+// ParseAll parses each of the statements of the sql, all of which must be CREATE TABLE, INDEX, VIEW or
+// TRIGGER statements.
+func ParseAll(sql string) ([]Statement, error) {
+	raws, err := SplitStatements(sql)
+	if err != nil {
+		return nil, err
+	}
+	statements := make([]Statement, 0, len(raws))
+	for _, raw := range raws {
+		statement, err := newParser(sql, raw.Tokens).parseCreate()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// parser parses the tokens of a statement
+type parser struct {
+	sql string
+	// tokens, without whitespace or comments, ending with an EOF token
+	tokens []Token
+	pos    int
+}
+
+func newParser(sql string, tokens []Token) *parser {
+	p := &parser{sql: sql}
+	end := Token{Kind: TokenEOF, Offset: len(sql), Line: 1, Column: 1}
+	for _, tok := range tokens {
+		if !tok.IsTrivia() {
+			p.tokens = append(p.tokens, tok)
+		}
+		end = Token{Kind: TokenEOF, Offset: tok.Offset + len(tok.Text), Line: tok.Line, Column: tok.Column + len(tok.Text)}
+	}
+	p.tokens = append(p.tokens, end)
+	return p
+}
+
+func (p *parser) peek() Token { return p.tokens[p.pos] }
+
+func (p *parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != TokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok Token, format string, args ...interface{}) error {
+	near := tok.Text
+	if tok.Kind == TokenEOF {
+		near = ""
+		format += " at the end of the statement"
+	}
+	return Error{Line: tok.Line, Column: tok.Column, Near: near, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isAt returns true if the next tokens are the keywords
+func (p *parser) isAt(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	for i, word := range words {
+		if !p.tokens[p.pos+i].Is(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// accept consumes the keywords, if they are next
+func (p *parser) accept(words ...string) bool {
+	if !p.isAt(words...) {
+		return false
+	}
+	p.pos += len(words)
+	return true
+}
+
+// expect consumes the keywords, or returns an error if they are not next
+func (p *parser) expect(words ...string) error {
+	if !p.accept(words...) {
+		return p.errorf(p.peek(), "expected %v", strings.Join(words, " "))
+	}
+	return nil
+}
+
+// acceptOperator consumes the operator, if it is next
+func (p *parser) acceptOperator(operator string) bool {
+	if !p.peek().IsOperator(operator) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// expectOperator consumes the operator, or returns an error if it is not next
+func (p *parser) expectOperator(operator string) error {
+	if !p.acceptOperator(operator) {
+		return p.errorf(p.peek(), "expected `%v`", operator)
+	}
+	return nil
+}
+
+// acceptOneOf consumes, and returns in upper case, the next token if it is one of the keywords
+func (p *parser) acceptOneOf(words ...string) string {
+	for _, word := range words {
+		if p.accept(word) {
+			return word
+		}
+	}
+	return ""
+}
+
+// expectEOF returns an error if there are tokens left
+func (p *parser) expectEOF() error {
+	if tok := p.peek(); tok.Kind != TokenEOF {
+		return p.errorf(tok, "unexpected %v", tok.Kind)
+	}
+	return nil
+}
+
+// parseName parses an identifier; bare, quoted, or (as SQLite allows) a string
+func (p *parser) parseName() (string, error) {
+	tok := p.peek()
+	switch tok.Kind {
+	case TokenIdent, TokenQuotedIdent, TokenString:
+		p.pos++
+		return tok.Value(), nil
+	default:
+		return "", p.errorf(tok, "expected a name")
+	}
+}
+
+// parseQualifiedName parses [schema.]name
+func (p *parser) parseQualifiedName() (name QualifiedName, err error) {
+	if name.Name, err = p.parseName(); err != nil {
+		return name, err
+	}
+	if p.acceptOperator(".") {
+		name.Schema = name.Name
+		if name.Name, err = p.parseName(); err != nil {
+			return name, err
+		}
+	}
+	return name, nil
+}
+
+// parseNames parses ( name, ... )
+func (p *parser) parseNames() (names []string, err error) {
+	if err = p.expectOperator("("); err != nil {
+		return nil, err
+	}
+	for {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.acceptOperator(",") {
+			break
+		}
+	}
+	return names, p.expectOperator(")")
+}
+
+// parseRaw consumes tokens up to, but not including, the first token (outside of parentheses) that stop
+// returns true for, an unmatched ), or the end of the statement; returning the sql of the tokens.
+func (p *parser) parseRaw(stop func(tok Token) bool) (string, error) {
+	start, depth := p.pos, 0
+	for {
+		tok := p.peek()
+		if tok.Kind == TokenEOF {
+			if depth > 0 {
+				return "", p.errorf(tok, "expected `)`")
+			}
+			break
+		}
+		if depth == 0 && stop != nil && stop(tok) {
+			break
+		}
+		if tok.IsOperator("(") {
+			depth++
+		} else if tok.IsOperator(")") {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf(p.peek(), "expected an expression")
+	}
+	return p.text(start, p.pos), nil
+}
+
+// text returns the sql of the tokens from start up to end
+func (p *parser) text(start, end int) string {
+	first, last := p.tokens[start], p.tokens[end-1]
+	return p.sql[first.Offset : last.Offset+len(last.Text)]
+}
+
+// parseCreate parses a CREATE TABLE, INDEX, VIEW or TRIGGER statement
+func (p *parser) parseCreate() (Statement, error) {
+	if err := p.expect("CREATE"); err != nil {
+		return nil, err
+	}
+	temporary := p.acceptOneOf("TEMP", "TEMPORARY") != ""
+	unique := p.accept("UNIQUE")
+	tok := p.next()
+	switch {
+	case unique && !tok.Is("INDEX"):
+		return nil, p.errorf(tok, "expected INDEX")
+	case tok.Is("TABLE"):
+		return p.parseCreateTable(temporary)
+	case tok.Is("INDEX") && !temporary:
+		return p.parseCreateIndex(unique)
+	case tok.Is("VIEW"):
+		return p.parseCreateView(temporary)
+	case tok.Is("TRIGGER"):
+		return p.parseCreateTrigger(temporary)
+	case tok.Is("VIRTUAL"):
+		return nil, p.errorf(tok, "CREATE VIRTUAL TABLE is not supported")
+	default:
+		return nil, p.errorf(tok, "expected TABLE, INDEX, VIEW or TRIGGER")
+	}
+}
+
+// constraintStart are the keywords that start a column constraint, and end the type of the column
+var constraintStart = []string{"CONSTRAINT", "PRIMARY", "NOT", "NULL", "UNIQUE", "CHECK", "DEFAULT", "COLLATE", "REFERENCES", "GENERATED", "AS"}
+
+func (p *parser) isAtConstraint() bool {
+	for _, word := range constraintStart {
+		if p.isAt(word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) isAtTableConstraint() bool {
+	for _, word := range []string{"CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN"} {
+		if p.isAt(word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseCreateTable(temporary bool) (_ Statement, err error) {
+	table := &CreateTable{Temporary: temporary, IfNotExists: p.accept("IF", "NOT", "EXISTS")}
+	if table.Name, err = p.parseQualifiedName(); err != nil {
+		return nil, err
+	}
+	if p.accept("AS") {
+		if table.AsSelect, err = p.parseRaw(nil); err != nil {
+			return nil, err
+		}
+		return table, p.expectEOF()
+	}
+	if err = p.expectOperator("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.isAtTableConstraint() {
+			break
+		}
+		column, err := p.parseColumnDef()
+		if err != nil {
+			return nil, err
+		}
+		table.Columns = append(table.Columns, column)
+		if !p.acceptOperator(",") {
+			break
+		}
+	}
+	for p.isAtTableConstraint() {
+		constraint, err := p.parseTableConstraint()
+		if err != nil {
+			return nil, err
+		}
+		table.Constraints = append(table.Constraints, constraint)
+		// SQLite allows the comma between table constraints to be left out
+		p.acceptOperator(",")
+	}
+	if err = p.expectOperator(")"); err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("WITHOUT"):
+			if tok := p.next(); !tok.Is("ROWID") {
+				return nil, p.errorf(tok, "expected ROWID")
+			}
+			table.WithoutRowID = true
+		case p.accept("STRICT"):
+			table.Strict = true
+		default:
+			return table, p.expectEOF()
+		}
+		if !p.acceptOperator(",") {
+			return table, p.expectEOF()
+		}
+	}
+}
+
+func (p *parser) parseColumnDef() (column ColumnDef, err error) {
+	if column.Name, err = p.parseName(); err != nil {
+		return column, err
+	}
+	if column.Type, err = p.parseTypeName(); err != nil {
+		return column, err
+	}
+	for !p.peek().IsOperator(",") && !p.peek().IsOperator(")") {
+		constraint, err := p.parseColumnConstraint()
+		if err != nil {
+			return column, err
+		}
+		column.Constraints = append(column.Constraints, constraint)
+	}
+	return column, nil
+}
+
+// parseTypeName parses the optional type of a column; names, optionally followed by one or two signed numbers
+// in parentheses
+func (p *parser) parseTypeName() (string, error) {
+	var words []string
+	for {
+		tok := p.peek()
+		if (tok.Kind != TokenIdent && tok.Kind != TokenQuotedIdent) || p.isAtConstraint() {
+			break
+		}
+		words = append(words, tok.Text)
+		p.pos++
+	}
+	if len(words) == 0 {
+		return "", nil
+	}
+	typ := strings.Join(words, " ")
+	if !p.acceptOperator("(") {
+		return typ, nil
+	}
+	var args []string
+	for {
+		var arg strings.Builder
+		if tok := p.peek(); tok.IsOperator("+") || tok.IsOperator("-") {
+			arg.WriteString(p.next().Text)
+		}
+		tok := p.next()
+		if tok.Kind != TokenNumber {
+			return "", p.errorf(tok, "expected a number")
+		}
+		arg.WriteString(tok.Text)
+		args = append(args, arg.String())
+		if !p.acceptOperator(",") {
+			break
+		}
+	}
+	if err := p.expectOperator(")"); err != nil {
+		return "", err
+	}
+	return typ + "(" + strings.Join(args, ", ") + ")", nil
+}
+
+// parseConflictClause parses the optional ON CONFLICT clause
+func (p *parser) parseConflictClause() (string, error) {
+	if !p.accept("ON", "CONFLICT") {
+		return "", nil
+	}
+	if resolution := p.acceptOneOf("ROLLBACK", "ABORT", "FAIL", "IGNORE", "REPLACE"); resolution != "" {
+		return resolution, nil
+	}
+	return "", p.errorf(p.peek(), "expected ROLLBACK, ABORT, FAIL, IGNORE or REPLACE")
+}
+
+// parseParenthesised parses ( expr ), returning the expression
+func (p *parser) parseParenthesised() (string, error) {
+	if err := p.expectOperator("("); err != nil {
+		return "", err
+	}
+	expr, err := p.parseRaw(nil)
+	if err != nil {
+		return "", err
+	}
+	return expr, p.expectOperator(")")
+}
+
+func (p *parser) parseColumnConstraint() (constraint ColumnConstraint, err error) {
+	if p.accept("CONSTRAINT") {
+		if constraint.Name, err = p.parseName(); err != nil {
+			return constraint, err
+		}
+	}
+	switch tok := p.peek(); {
+	case p.accept("PRIMARY", "KEY"):
+		constraint.Kind = ConstraintPrimaryKey
+		constraint.Order = p.acceptOneOf("ASC", "DESC")
+		if constraint.OnConflict, err = p.parseConflictClause(); err != nil {
+			return constraint, err
+		}
+		constraint.AutoIncrement = p.accept("AUTOINCREMENT")
+	case p.accept("NOT", "NULL"):
+		constraint.Kind = ConstraintNotNull
+		constraint.OnConflict, err = p.parseConflictClause()
+	case p.accept("NULL"):
+		constraint.Kind = ConstraintNull
+	case p.accept("UNIQUE"):
+		constraint.Kind = ConstraintUnique
+		constraint.OnConflict, err = p.parseConflictClause()
+	case p.accept("CHECK"):
+		constraint.Kind = ConstraintCheck
+		constraint.Expr, err = p.parseParenthesised()
+	case p.accept("DEFAULT"):
+		constraint.Kind = ConstraintDefault
+		constraint.Expr, err = p.parseDefault()
+	case p.accept("COLLATE"):
+		constraint.Kind = ConstraintCollate
+		constraint.Collation, err = p.parseName()
+	case p.isAt("REFERENCES"):
+		constraint.Kind = ConstraintForeignKey
+		constraint.ForeignKey, err = p.parseForeignKeyClause()
+	case p.isAt("GENERATED"), p.isAt("AS"):
+		if p.accept("GENERATED") {
+			if err = p.expect("ALWAYS"); err != nil {
+				return constraint, err
+			}
+		}
+		if err = p.expect("AS"); err != nil {
+			return constraint, err
+		}
+		constraint.Kind = ConstraintGenerated
+		if constraint.Expr, err = p.parseParenthesised(); err != nil {
+			return constraint, err
+		}
+		constraint.Stored = p.acceptOneOf("STORED", "VIRTUAL") == "STORED"
+	default:
+		return constraint, p.errorf(tok, "expected a column constraint")
+	}
+	return constraint, err
+}
+
+// parseDefault parses the value of a DEFAULT; a parenthesised expression, a signed number, or a literal
+func (p *parser) parseDefault() (string, error) {
+	start := p.pos
+	switch tok := p.peek(); {
+	case tok.IsOperator("("):
+		if _, err := p.parseParenthesised(); err != nil {
+			return "", err
+		}
+	case tok.IsOperator("+"), tok.IsOperator("-"):
+		p.pos++
+		if number := p.next(); number.Kind != TokenNumber {
+			return "", p.errorf(number, "expected a number")
+		}
+	case tok.Kind == TokenNumber, tok.Kind == TokenString, tok.Kind == TokenBlob, tok.Kind == TokenIdent:
+		// identifiers are NULL, TRUE, FALSE, CURRENT_TIME, ...
+		p.pos++
+	default:
+		return "", p.errorf(tok, "expected a default value")
+	}
+	return p.text(start, p.pos), nil
+}
+
+func (p *parser) parseForeignKeyClause() (clause *ForeignKeyClause, err error) {
+	if err = p.expect("REFERENCES"); err != nil {
+		return nil, err
+	}
+	clause = new(ForeignKeyClause)
+	if clause.Table, err = p.parseName(); err != nil {
+		return nil, err
+	}
+	if p.peek().IsOperator("(") {
+		if clause.Columns, err = p.parseNames(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		switch {
+		case p.accept("ON"):
+			event := p.acceptOneOf("DELETE", "UPDATE")
+			if event == "" {
+				return nil, p.errorf(p.peek(), "expected DELETE or UPDATE")
+			}
+			var action string
+			switch {
+			case p.accept("SET", "NULL"):
+				action = "SET NULL"
+			case p.accept("SET", "DEFAULT"):
+				action = "SET DEFAULT"
+			case p.accept("NO", "ACTION"):
+				action = "NO ACTION"
+			default:
+				if action = p.acceptOneOf("CASCADE", "RESTRICT"); action == "" {
+					return nil, p.errorf(p.peek(), "expected SET NULL, SET DEFAULT, CASCADE, RESTRICT or NO ACTION")
+				}
+			}
+			if event == "DELETE" {
+				clause.OnDelete = action
+			} else {
+				clause.OnUpdate = action
+			}
+		case p.accept("MATCH"):
+			if clause.Match, err = p.parseName(); err != nil {
+				return nil, err
+			}
+		case p.isAt("DEFERRABLE"), p.isAt("NOT", "DEFERRABLE"):
+			deferrable := "DEFERRABLE"
+			if p.accept("NOT") {
+				deferrable = "NOT DEFERRABLE"
+			}
+			p.pos++
+			if p.accept("INITIALLY") {
+				initially := p.acceptOneOf("DEFERRED", "IMMEDIATE")
+				if initially == "" {
+					return nil, p.errorf(p.peek(), "expected DEFERRED or IMMEDIATE")
+				}
+				deferrable += " INITIALLY " + initially
+			}
+			clause.Deferrable = deferrable
+		default:
+			return clause, nil
+		}
+	}
+}
+
+func (p *parser) parseTableConstraint() (constraint TableConstraint, err error) {
+	if p.accept("CONSTRAINT") {
+		if constraint.Name, err = p.parseName(); err != nil {
+			return constraint, err
+		}
+	}
+	switch tok := p.peek(); {
+	case p.accept("PRIMARY", "KEY"), p.accept("UNIQUE"):
+		constraint.Kind = ConstraintUnique
+		if tok.Is("PRIMARY") {
+			constraint.Kind = ConstraintPrimaryKey
+		}
+		if constraint.Columns, err = p.parseIndexedColumns(); err != nil {
+			return constraint, err
+		}
+		constraint.OnConflict, err = p.parseConflictClause()
+	case p.accept("CHECK"):
+		constraint.Kind = ConstraintCheck
+		constraint.Expr, err = p.parseParenthesised()
+	case p.accept("FOREIGN", "KEY"):
+		constraint.Kind = ConstraintForeignKey
+		if constraint.FromColumns, err = p.parseNames(); err != nil {
+			return constraint, err
+		}
+		constraint.ForeignKey, err = p.parseForeignKeyClause()
+	default:
+		return constraint, p.errorf(tok, "expected a table constraint")
+	}
+	return constraint, err
+}
+
+// parseIndexedColumns parses ( indexed-column, ... )
+func (p *parser) parseIndexedColumns() (columns []IndexedColumn, err error) {
+	if err = p.expectOperator("("); err != nil {
+		return nil, err
+	}
+	for {
+		start := p.pos
+		if _, err = p.parseRaw(func(tok Token) bool { return tok.IsOperator(",") }); err != nil {
+			return nil, err
+		}
+		end := p.pos
+		var column IndexedColumn
+		if last := p.tokens[end-1]; end-start > 1 && (last.Is("ASC") || last.Is("DESC")) {
+			column.Order = strings.ToUpper(last.Text)
+			end--
+		}
+		if end-start > 2 && p.tokens[end-2].Is("COLLATE") {
+			column.Collation = p.tokens[end-1].Value()
+			end -= 2
+		}
+		if first := p.tokens[start]; end-start == 1 && (first.Kind == TokenIdent || first.Kind == TokenQuotedIdent) {
+			column.Name = first.Value()
+		} else {
+			column.Expr = p.text(start, end)
+		}
+		columns = append(columns, column)
+		if !p.acceptOperator(",") {
+			break
+		}
+	}
+	return columns, p.expectOperator(")")
+}
+
+func (p *parser) parseCreateIndex(unique bool) (_ Statement, err error) {
+	index := &CreateIndex{Unique: unique, IfNotExists: p.accept("IF", "NOT", "EXISTS")}
+	if index.Name, err = p.parseQualifiedName(); err != nil {
+		return nil, err
+	}
+	if err = p.expect("ON"); err != nil {
+		return nil, err
+	}
+	if index.Table, err = p.parseName(); err != nil {
+		return nil, err
+	}
+	if index.Columns, err = p.parseIndexedColumns(); err != nil {
+		return nil, err
+	}
+	if p.accept("WHERE") {
+		if index.Where, err = p.parseRaw(nil); err != nil {
+			return nil, err
+		}
+	}
+	return index, p.expectEOF()
+}
+
+func (p *parser) parseCreateView(temporary bool) (_ Statement, err error) {
+	view := &CreateView{Temporary: temporary, IfNotExists: p.accept("IF", "NOT", "EXISTS")}
+	if view.Name, err = p.parseQualifiedName(); err != nil {
+		return nil, err
+	}
+	if p.peek().IsOperator("(") {
+		if view.Columns, err = p.parseNames(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expect("AS"); err != nil {
+		return nil, err
+	}
+	if view.Select, err = p.parseRaw(nil); err != nil {
+		return nil, err
+	}
+	return view, p.expectEOF()
+}
+
+func (p *parser) parseCreateTrigger(temporary bool) (_ Statement, err error) {
+	trigger := &CreateTrigger{Temporary: temporary, IfNotExists: p.accept("IF", "NOT", "EXISTS")}
+	if trigger.Name, err = p.parseQualifiedName(); err != nil {
+		return nil, err
+	}
+	if p.accept("INSTEAD", "OF") {
+		trigger.Time = "INSTEAD OF"
+	} else {
+		trigger.Time = p.acceptOneOf("BEFORE", "AFTER")
+	}
+	if trigger.Event = p.acceptOneOf("DELETE", "INSERT", "UPDATE"); trigger.Event == "" {
+		return nil, p.errorf(p.peek(), "expected DELETE, INSERT or UPDATE")
+	}
+	if trigger.Event == "UPDATE" && p.accept("OF") {
+		for {
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			trigger.Columns = append(trigger.Columns, name)
+			if !p.acceptOperator(",") {
+				break
+			}
+		}
+	}
+	if err = p.expect("ON"); err != nil {
+		return nil, err
+	}
+	if trigger.Table, err = p.parseName(); err != nil {
+		return nil, err
+	}
+	trigger.ForEachRow = p.accept("FOR", "EACH", "ROW")
+	if p.accept("WHEN") {
+		if trigger.When, err = p.parseRaw(func(tok Token) bool { return tok.Is("BEGIN") }); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expect("BEGIN"); err != nil {
+		return nil, err
+	}
+	// the body is everything up to the END that ends the statement
+	end := len(p.tokens) - 2
+	if end < p.pos || !p.tokens[end].Is("END") {
+		return nil, p.errorf(p.tokens[len(p.tokens)-1], "expected END")
+	}
+	if end > p.pos {
+		body, err := SplitStatements(p.text(p.pos, end))
+		if err != nil {
+			return nil, err
+		}
+		for _, statement := range body {
+			trigger.Body = append(trigger.Body, statement.Text)
+		}
+	}
+	if len(trigger.Body) == 0 {
+		return nil, p.errorf(p.tokens[end], "expected a statement")
+	}
+	p.pos = end + 1
+	return trigger, p.expectEOF()
+}
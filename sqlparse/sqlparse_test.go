@@ -0,0 +1,237 @@
+package sqlparse
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	const sql = "SELECT a->>'$.b', x'0aFF', 1.5e-3, \"q\"\"d\", [b r], `c`, ?1, :name -- done\n/* block */ FROM t;"
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		t.Fatalf("tokenize, expected nil got %v", err)
+	}
+	var joined strings.Builder
+	var kinds []TokenKind
+	for _, tok := range tokens {
+		joined.WriteString(tok.Text)
+		if !tok.IsTrivia() {
+			kinds = append(kinds, tok.Kind)
+		}
+	}
+	if joined.String() != sql {
+		t.Errorf("joined tokens, expected %q got %q", sql, joined.String())
+	}
+	expected := []TokenKind{
+		TokenIdent, TokenIdent, TokenOperator, TokenString, TokenOperator, TokenBlob, TokenOperator,
+		TokenNumber, TokenOperator, TokenQuotedIdent, TokenOperator, TokenQuotedIdent, TokenOperator,
+		TokenQuotedIdent, TokenOperator, TokenVariable, TokenOperator, TokenVariable, TokenIdent, TokenIdent,
+		TokenOperator,
+	}
+	if !reflect.DeepEqual(expected, kinds) {
+		t.Errorf("kinds,\n\texpected %v\n\t     got %v", expected, kinds)
+	}
+	if last := tokens[len(tokens)-1]; last.Line != 2 || last.Column != 19 {
+		t.Errorf("position of `;`, expected 2:19 got %v:%v", last.Line, last.Column)
+	}
+
+	for _, bad := range []string{"'unterminated", "x'abc'", "1.5e", "SELECT !"} {
+		var sqlErr Error
+		if _, err := Tokenize(bad); !errors.As(err, &sqlErr) {
+			t.Errorf("tokenize %q, expected Error got %v", bad, err)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	const sql = `-- leading comment
+CREATE TABLE "trigger" (a);
+CREATE TRIGGER t AFTER INSERT ON a BEGIN
+  UPDATE a SET a = CASE WHEN a > 0 THEN 1 ELSE 0 END;
+  DELETE FROM b;
+END;
+;
+INSERT INTO a VALUES ('a;b') -- no ;`
+	statements, err := SplitStatements(sql)
+	if err != nil {
+		t.Fatalf("split, expected nil got %v", err)
+	}
+	var texts []string
+	for _, statement := range statements {
+		texts = append(texts, statement.Text)
+	}
+	expected := []string{
+		"-- leading comment\nCREATE TABLE \"trigger\" (a)",
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE a SET a = CASE WHEN a > 0 THEN 1 ELSE 0 END;\n  DELETE FROM b;\nEND",
+		"INSERT INTO a VALUES ('a;b') -- no ;",
+	}
+	if !reflect.DeepEqual(expected, texts) {
+		t.Errorf("statements,\n\texpected %q\n\t     got %q", expected, texts)
+	}
+	if statements[2].Line != 8 {
+		t.Errorf("line, expected 8 got %v", statements[2].Line)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		sql      string
+		expected Statement
+	}{
+		"table": {
+			sql: `CREATE TABLE IF NOT EXISTS main.users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT, -- the id
+	"first name" VARCHAR ( 255 ) NOT NULL ON CONFLICT REPLACE COLLATE NOCASE,
+	age UNSIGNED BIG INT DEFAULT -1 CHECK (age >= -1),
+	score REAL DEFAULT (0.5 * 2),
+	owner_id INTEGER CONSTRAINT fk_owner REFERENCES owners(id) ON DELETE SET NULL DEFERRABLE INITIALLY DEFERRED,
+	full_name TEXT GENERATED ALWAYS AS ("first name" || ' ' || 'x') STORED,
+	upper_name AS (upper("first name")),
+	CONSTRAINT one_per_owner UNIQUE (owner_id, "first name" COLLATE NOCASE DESC)
+	FOREIGN KEY (age, score) REFERENCES ages ON UPDATE CASCADE
+) WITHOUT ROWID, STRICT;`,
+			expected: &CreateTable{
+				IfNotExists: true,
+				Name:        QualifiedName{Schema: "main", Name: "users"},
+				Columns: []ColumnDef{
+					{Name: "id", Type: "INTEGER", Constraints: []ColumnConstraint{{Kind: ConstraintPrimaryKey, AutoIncrement: true}}},
+					{Name: "first name", Type: "VARCHAR(255)", Constraints: []ColumnConstraint{
+						{Kind: ConstraintNotNull, OnConflict: "REPLACE"},
+						{Kind: ConstraintCollate, Collation: "NOCASE"},
+					}},
+					{Name: "age", Type: "UNSIGNED BIG INT", Constraints: []ColumnConstraint{
+						{Kind: ConstraintDefault, Expr: "-1"},
+						{Kind: ConstraintCheck, Expr: "age >= -1"},
+					}},
+					{Name: "score", Type: "REAL", Constraints: []ColumnConstraint{{Kind: ConstraintDefault, Expr: "(0.5 * 2)"}}},
+					{Name: "owner_id", Type: "INTEGER", Constraints: []ColumnConstraint{{
+						Name: "fk_owner",
+						Kind: ConstraintForeignKey,
+						ForeignKey: &ForeignKeyClause{
+							Table: "owners", Columns: []string{"id"}, OnDelete: "SET NULL", Deferrable: "DEFERRABLE INITIALLY DEFERRED",
+						},
+					}}},
+					{Name: "full_name", Type: "TEXT", Constraints: []ColumnConstraint{
+						{Kind: ConstraintGenerated, Expr: `"first name" || ' ' || 'x'`, Stored: true},
+					}},
+					{Name: "upper_name", Constraints: []ColumnConstraint{{Kind: ConstraintGenerated, Expr: `upper("first name")`}}},
+				},
+				Constraints: []TableConstraint{
+					{Name: "one_per_owner", Kind: ConstraintUnique, Columns: []IndexedColumn{
+						{Name: "owner_id"},
+						{Name: "first name", Collation: "NOCASE", Order: "DESC"},
+					}},
+					{Kind: ConstraintForeignKey, FromColumns: []string{"age", "score"}, ForeignKey: &ForeignKeyClause{Table: "ages", OnUpdate: "CASCADE"}},
+				},
+				WithoutRowID: true,
+				Strict:       true,
+			},
+		},
+		"table as select": {
+			sql:      `CREATE TEMP TABLE copy AS SELECT * FROM users`,
+			expected: &CreateTable{Temporary: true, Name: QualifiedName{Name: "copy"}, AsSelect: "SELECT * FROM users"},
+		},
+		"index": {
+			sql: `CREATE UNIQUE INDEX IF NOT EXISTS users_lower ON users (lower(name), age DESC) WHERE age > 0`,
+			expected: &CreateIndex{
+				Unique:      true,
+				IfNotExists: true,
+				Name:        QualifiedName{Name: "users_lower"},
+				Table:       "users",
+				Columns:     []IndexedColumn{{Expr: "lower(name)"}, {Name: "age", Order: "DESC"}},
+				Where:       "age > 0",
+			},
+		},
+		"view": {
+			sql: `CREATE VIEW adults (name, "group") AS SELECT name, 'adult' FROM users WHERE age >= 18`,
+			expected: &CreateView{
+				Name:    QualifiedName{Name: "adults"},
+				Columns: []string{"name", "group"},
+				Select:  `SELECT name, 'adult' FROM users WHERE age >= 18`,
+			},
+		},
+		"trigger": {
+			sql: `CREATE TRIGGER users_au AFTER UPDATE OF name, age ON users FOR EACH ROW WHEN new.age > (SELECT 1) BEGIN
+	UPDATE log SET n = CASE WHEN n IS NULL THEN 1 ELSE n + 1 END;
+	INSERT INTO log (msg) VALUES ('end;');
+END;`,
+			expected: &CreateTrigger{
+				Name:       QualifiedName{Name: "users_au"},
+				Time:       "AFTER",
+				Event:      "UPDATE",
+				Columns:    []string{"name", "age"},
+				Table:      "users",
+				ForEachRow: true,
+				When:       "new.age > (SELECT 1)",
+				Body: []string{
+					"UPDATE log SET n = CASE WHEN n IS NULL THEN 1 ELSE n + 1 END",
+					"INSERT INTO log (msg) VALUES ('end;')",
+				},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			statement, err := Parse(tc.sql)
+			if err != nil {
+				t.Fatalf("parse, expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(tc.expected, statement) {
+				t.Errorf("statement,\n\texpected %+v\n\t     got %+v", tc.expected, statement)
+			}
+			// the rendered sql parses to the same statement
+			rendered := statement.String()
+			reparsed, err := Parse(rendered)
+			if err != nil {
+				t.Fatalf("parse rendered, expected nil got %v\n%v", err, rendered)
+			}
+			if !reflect.DeepEqual(statement, reparsed) {
+				t.Errorf("reparsed,\n\texpected %+v\n\t     got %+v\n%v", statement, reparsed, rendered)
+			}
+			if again := reparsed.String(); again != rendered {
+				t.Errorf("rendered,\n\texpected %v\n\t     got %v", rendered, again)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		"not create":       "SELECT 1",
+		"two statements":   "CREATE TABLE a (b); CREATE TABLE c (d);",
+		"virtual":          "CREATE VIRTUAL TABLE a USING fts5(b)",
+		"unclosed":         "CREATE TABLE a (b INTEGER",
+		"bad constraint":   "CREATE TABLE a (b INTEGER PRIMARY)",
+		"bad type":         "CREATE TABLE a (b VARCHAR(x))",
+		"trailing":         "CREATE INDEX a ON b (c) d",
+		"empty trigger":    "CREATE TRIGGER a AFTER INSERT ON b BEGIN END",
+		"missing default":  "CREATE TABLE a (b DEFAULT)",
+		"temporary index":  "CREATE TEMP INDEX a ON b (c)",
+		"bad foreign key":  "CREATE TABLE a (b REFERENCES c ON DELETE NOTHING)",
+		"unbalanced check": "CREATE TABLE a (b CHECK (b > (1))",
+	}
+	for name, sql := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sqlErr Error
+			if _, err := Parse(sql); !errors.As(err, &sqlErr) {
+				t.Errorf("parse, expected Error got %v", err)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	for name, expected := range map[string]string{
+		"users":      "users",
+		"group":      `"group"`,
+		"first name": `"first name"`,
+		`a"b`:        `"a""b"`,
+		"1st":        `"1st"`,
+	} {
+		if got := QuoteIdentifier(name); got != expected {
+			t.Errorf("quote %q, expected %v got %v", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+// Package sqlparse tokenizes SQLite sql, splits it into statements, and parses CREATE TABLE, INDEX, VIEW
+// and TRIGGER statements into an AST that can be rendered back to sql.
+//
+// Expressions (defaults, checks, generated columns, WHERE clauses, the SELECT of a view and the body of a
+// trigger) are not parsed; they are kept as the sql they were written as.
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind is the kind of a token
+type TokenKind int
+
+const (
+	// TokenEOF is the end of the sql; it is not returned by Tokenize
+	TokenEOF = TokenKind(iota)
+	// TokenSpace is a run of whitespace
+	TokenSpace
+	// TokenComment is a -- or /* */ comment
+	TokenComment
+	// TokenIdent is a bare identifier, or a keyword; SQLite allows most keywords as identifiers
+	TokenIdent
+	// TokenQuotedIdent is an identifier in "", `` or []
+	TokenQuotedIdent
+	// TokenString is a string literal in ''
+	TokenString
+	// TokenBlob is a blob literal; x'hex'
+	TokenBlob
+	// TokenNumber is a numeric literal
+	TokenNumber
+	// TokenVariable is a parameter; ?, ?NNN, :name, @name or $name
+	TokenVariable
+	// TokenOperator is an operator or punctuation; e.g. ( , ; || ->>
+	TokenOperator
+)
+
+var tokenKindNames = map[TokenKind]string{
+	TokenEOF:         "EOF",
+	TokenSpace:       "space",
+	TokenComment:     "comment",
+	TokenIdent:       "identifier",
+	TokenQuotedIdent: "quoted identifier",
+	TokenString:      "string",
+	TokenBlob:        "blob",
+	TokenNumber:      "number",
+	TokenVariable:    "variable",
+	TokenOperator:    "operator",
+}
+
+func (kind TokenKind) String() string {
+	if name, ok := tokenKindNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(kind))
+}
+
+// Token is a token of sql; the Text of the tokens of Tokenize, joined, is the sql that was tokenized
+type Token struct {
+	Kind TokenKind
+	Text string
+	// Offset is the byte offset of the token in the sql
+	Offset int
+	// Line and Column, of the start of the token, start at 1
+	Line   int
+	Column int
+}
+
+// Is returns true if the token is the bare identifier, or keyword, ignoring case
+func (tok Token) Is(keyword string) bool {
+	return tok.Kind == TokenIdent && strings.EqualFold(tok.Text, keyword)
+}
+
+// IsOperator returns true if the token is the operator
+func (tok Token) IsOperator(operator string) bool {
+	return tok.Kind == TokenOperator && tok.Text == operator
+}
+
+// IsTrivia returns true for whitespace and comments
+func (tok Token) IsTrivia() bool { return tok.Kind == TokenSpace || tok.Kind == TokenComment }
+
+// Value returns the value of identifiers and strings, without their quotes; and the text of other tokens
+func (tok Token) Value() string {
+	switch tok.Kind {
+	case TokenString:
+		return strings.ReplaceAll(tok.Text[1:len(tok.Text)-1], `''`, `'`)
+	case TokenQuotedIdent:
+		switch tok.Text[0] {
+		case '"':
+			return strings.ReplaceAll(tok.Text[1:len(tok.Text)-1], `""`, `"`)
+		case '`':
+			return strings.ReplaceAll(tok.Text[1:len(tok.Text)-1], "``", "`")
+		default:
+			return tok.Text[1 : len(tok.Text)-1]
+		}
+	default:
+		return tok.Text
+	}
+}
+
+func (tok Token) String() string { return fmt.Sprintf("%v `%v`", tok.Kind, tok.Text) }
+
+// Error is an error in sql, at a position
+type Error struct {
+	Line   int
+	Column int
+	// Near is the text the error is at, if there is any
+	Near string
+	Msg  string
+}
+
+func (err Error) Error() string {
+	if err.Near == "" {
+		return fmt.Sprintf("line %d:%d: %v", err.Line, err.Column, err.Msg)
+	}
+	return fmt.Sprintf("line %d:%d: %v near `%v`", err.Line, err.Column, err.Msg, err.Near)
+}
+
+// operators are the multi-character operators, longest first
+var operators = []string{"->>", "->", "||", "<<", ">>", "<=", ">=", "==", "!=", "<>"}
+
+// Tokenize splits the sql into tokens, including whitespace and comments
+func Tokenize(sql string) ([]Token, error) {
+	var (
+		tokens       []Token
+		line, column = 1, 1
+	)
+	for offset := 0; offset < len(sql); {
+		kind, length, err := scanToken(sql[offset:])
+		if err != nil {
+			return tokens, Error{Line: line, Column: column, Near: firstLine(sql[offset:]), Msg: err.Error()}
+		}
+		text := sql[offset : offset+length]
+		tokens = append(tokens, Token{Kind: kind, Text: text, Offset: offset, Line: line, Column: column})
+		if newLines := strings.Count(text, "\n"); newLines != 0 {
+			line += newLines
+			column = len(text) - strings.LastIndexByte(text, '\n')
+		} else {
+			column += len(text)
+		}
+		offset += length
+	}
+	return tokens, nil
+}
+
+// firstLine returns the first line of s, for errors
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		s = s[:i]
+	}
+	if len(s) > 20 {
+		s = s[:20]
+	}
+	return s
+}
+
+func isSpace(c byte) bool    { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+func isDigit(c byte) bool    { return c >= '0' && c <= '9' }
+func isHexDigit(c byte) bool { return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' }
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// scanToken returns the kind and length of the token at the start of s
+func scanToken(s string) (TokenKind, int, error) {
+	c := s[0]
+	switch {
+	case isSpace(c):
+		i := 1
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		return TokenSpace, i, nil
+
+	case strings.HasPrefix(s, "--"):
+		if i := strings.IndexByte(s, '\n'); i != -1 {
+			return TokenComment, i, nil
+		}
+		return TokenComment, len(s), nil
+
+	case strings.HasPrefix(s, "/*"):
+		// like SQLite, an unterminated comment runs to the end of the sql
+		if i := strings.Index(s[2:], "*/"); i != -1 {
+			return TokenComment, i + 4, nil
+		}
+		return TokenComment, len(s), nil
+
+	case (c == 'x' || c == 'X') && len(s) > 1 && s[1] == '\'':
+		i := 2
+		for i < len(s) && isHexDigit(s[i]) {
+			i++
+		}
+		if i >= len(s) || s[i] != '\'' || (i-2)%2 != 0 {
+			return 0, 0, fmt.Errorf("malformed blob literal")
+		}
+		return TokenBlob, i + 1, nil
+
+	case isIdentChar(c) && !isDigit(c) && c != '$':
+		i := 1
+		for i < len(s) && isIdentChar(s[i]) {
+			i++
+		}
+		return TokenIdent, i, nil
+
+	case c == '\'':
+		length, ok := scanQuoted(s, '\'', true)
+		if !ok {
+			return 0, 0, fmt.Errorf("unterminated string")
+		}
+		return TokenString, length, nil
+
+	case c == '"' || c == '`':
+		length, ok := scanQuoted(s, c, true)
+		if !ok {
+			return 0, 0, fmt.Errorf("unterminated identifier")
+		}
+		return TokenQuotedIdent, length, nil
+
+	case c == '[':
+		length, ok := scanQuoted(s, ']', false)
+		if !ok {
+			return 0, 0, fmt.Errorf("unterminated identifier")
+		}
+		return TokenQuotedIdent, length, nil
+
+	case isDigit(c) || (c == '.' && len(s) > 1 && isDigit(s[1])):
+		return scanNumber(s)
+
+	case c == '?':
+		i := 1
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		return TokenVariable, i, nil
+
+	case c == ':' || c == '@' || c == '$':
+		i := 1
+		for i < len(s) && isIdentChar(s[i]) {
+			i++
+		}
+		if i == 1 {
+			return 0, 0, fmt.Errorf("unnamed variable")
+		}
+		return TokenVariable, i, nil
+	}
+
+	for _, operator := range operators {
+		if strings.HasPrefix(s, operator) {
+			return TokenOperator, len(operator), nil
+		}
+	}
+	if strings.IndexByte("(),;.+-*/%&|~<>=", c) != -1 {
+		return TokenOperator, 1, nil
+	}
+	return 0, 0, fmt.Errorf("unexpected character %q", c)
+}
+
+// scanQuoted returns the length of the quoted text at the start of s, that is closed by the closing character;
+// ok is false if it is not closed
+func scanQuoted(s string, closing byte, doubledEscapes bool) (length int, ok bool) {
+	for i := 1; i < len(s); i++ {
+		if s[i] != closing {
+			continue
+		}
+		if doubledEscapes && i+1 < len(s) && s[i+1] == closing {
+			i++
+			continue
+		}
+		return i + 1, true
+	}
+	return len(s), false
+}
+
+// scanNumber scans a numeric literal; decimal, with an optional fraction and exponent, or hex
+func scanNumber(s string) (TokenKind, int, error) {
+	i := 0
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') && isHexDigit(s[2]) {
+		i = 2
+		for i < len(s) && isHexDigit(s[i]) {
+			i++
+		}
+	} else {
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '.' {
+			i++
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+		}
+		if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+			j := i + 1
+			if j < len(s) && (s[j] == '+' || s[j] == '-') {
+				j++
+			}
+			if j >= len(s) || !isDigit(s[j]) {
+				return 0, 0, fmt.Errorf("malformed number")
+			}
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	if i < len(s) && isIdentChar(s[i]) {
+		return 0, 0, fmt.Errorf("malformed number")
+	}
+	return TokenNumber, i, nil
+}
+
+// RawStatement is a statement of sql, as it was written
+type RawStatement struct {
+	// Text of the statement, without the ; that ends it, and the whitespace around it
+	Text string
+	// Line and Column of the start of the statement
+	Line   int
+	Column int
+	// Tokens of the statement, including its comments; but not the whitespace around it, or the ;
+	Tokens []Token
+}
+
+// SplitStatements splits the sql into its statements. Statements that are only comments are dropped. The ;
+// of the statements in the body of a CREATE TRIGGER do not end the CREATE TRIGGER statement.
+func SplitStatements(sql string) ([]RawStatement, error) {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		statements []RawStatement
+		current    []Token
+		// words are the identifiers of the current statement, used to recognise a CREATE TRIGGER
+		words     int
+		prevWord  Token
+		isTrigger bool
+		// depth counts the BEGIN/CASE ... END blocks of a CREATE TRIGGER
+		depth int
+	)
+	endStatement := func() {
+		// drop the leading and trailing whitespace
+		for len(current) != 0 && current[0].Kind == TokenSpace {
+			current = current[1:]
+		}
+		for len(current) != 0 && current[len(current)-1].Kind == TokenSpace {
+			current = current[:len(current)-1]
+		}
+		onlyComments := true
+		for _, tok := range current {
+			if !tok.IsTrivia() {
+				onlyComments = false
+				break
+			}
+		}
+		if !onlyComments {
+			first, last := current[0], current[len(current)-1]
+			statements = append(statements, RawStatement{
+				Text:   sql[first.Offset : last.Offset+len(last.Text)],
+				Line:   first.Line,
+				Column: first.Column,
+				Tokens: current,
+			})
+		}
+		current, words, prevWord, isTrigger, depth = nil, 0, Token{}, false, 0
+	}
+	for _, tok := range tokens {
+		if tok.IsOperator(";") && depth <= 0 {
+			endStatement()
+			continue
+		}
+		current = append(current, tok)
+		if tok.Kind != TokenIdent {
+			continue
+		}
+		words++
+		// CREATE [TEMP|TEMPORARY] TRIGGER
+		if words <= 3 && tok.Is("TRIGGER") && firstWord(current).Is("CREATE") &&
+			(prevWord.Is("CREATE") || prevWord.Is("TEMP") || prevWord.Is("TEMPORARY")) {
+			isTrigger = true
+		}
+		prevWord = tok
+		if !isTrigger {
+			continue
+		}
+		switch {
+		case tok.Is("BEGIN"), tok.Is("CASE"):
+			depth++
+		case tok.Is("END"):
+			depth--
+		}
+	}
+	endStatement()
+	return statements, nil
+}
+
+// firstWord returns the first token, that is not whitespace or a comment
+func firstWord(tokens []Token) Token {
+	for _, tok := range tokens {
+		if !tok.IsTrivia() {
+			return tok
+		}
+	}
+	return Token{Kind: TokenEOF}
+}
@@ -0,0 +1,227 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// MigrationState is the state of a migration file for a database
+type MigrationState string
+
+const (
+	// StateApplied is a file that has been applied, and not changed since
+	StateApplied = MigrationState("applied")
+	// StatePending is a file that has not been applied yet
+	StatePending = MigrationState("pending")
+	// StateModified is a file that has been applied, but has changed since
+	StateModified = MigrationState("modified")
+	// StateMissing is a file that is not on disk; either a file that was applied and has since been
+	// removed from the sequence, or a sequence entry without a file
+	StateMissing = MigrationState("missing")
+	// StateBaselined is a file that was applied, and has since been squashed into a baseline
+	StateBaselined = MigrationState("baselined")
+	// StateSkipped is a file that was skipped, as it was not for the active environments
+	StateSkipped = MigrationState("skipped")
+	// StateInProgress is a background file that has been started, but not all of its batches have been run
+	StateInProgress = MigrationState("in-progress")
+)
+
+// MigrationStatus is the state of a single migration file for a database
+type MigrationStatus struct {
+	// Version is the path, relative to the migration directory, of the file
+	Version string
+	// Kind is TrackingKindVersion, TrackingKindRepeatable or TrackingKindBackground
+	Kind  string
+	State MigrationState
+	// InSequence is true if the version is an entry of the sequence, a repeatable or a background file
+	InSequence bool
+	// Hash is the hash of the file when it was last applied, empty if it has not been applied
+	Hash string
+	// AppliedAt, and Author, are from when the file was last applied or skipped
+	AppliedAt string
+	Author    string
+}
+
+// trackingRow is the latest tracking entry for a file
+type trackingRow struct {
+	Kind      string
+	Hash      string
+	CreatedAt string
+	Author    string
+	// Completed is only set for background files
+	Completed bool
+}
+
+// trackingRows returns the latest tracking entry of each file, and the files in the order they were first recorded
+func (mng *Manager) trackingRows(db Executor) (rows map[string]trackingRow, order []string, err error) {
+	const (
+		SelectTrackingRowsSQL = `
+	SELECT file_path, kind, file_hash, created_at, author, completed_at != ''
+	FROM %s
+	ORDER by ROWID asc;
+	`
+	)
+	source, err := mng.trackingSource(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := db.QueryContext(context.Background(), fmt.Sprintf(SelectTrackingRowsSQL, source))
+	if err != nil {
+		return nil, nil, ErrTrackingRead{Err: err, TableName: mng.TableName()}
+	}
+	defer result.Close()
+	rows = make(map[string]trackingRow)
+	for result.Next() {
+		var (
+			name string
+			row  trackingRow
+		)
+		if err = result.Scan(&name, &row.Kind, &row.Hash, &row.CreatedAt, &row.Author, &row.Completed); err != nil {
+			return nil, nil, ErrTrackingRead{Err: err, TableName: mng.TableName()}
+		}
+		if row.Kind == TrackingKindMaintenance {
+			// maintenance steps are not files
+			continue
+		}
+		last, seen := rows[name]
+		if !seen {
+			order = append(order, name)
+		}
+		// a skipped entry does not replace an applied one
+		if seen && row.Kind == TrackingKindSkipped && last.Kind != TrackingKindSkipped {
+			continue
+		}
+		rows[name] = row
+	}
+	if err = result.Err(); err != nil {
+		return nil, nil, ErrTrackingRead{Err: err, TableName: mng.TableName()}
+	}
+	return rows, order, nil
+}
+
+// Status returns the state of every file in the sequence, the repeatable files, and every file in the tracking
+// table of the db. The sequence entries come first, in sequence order, followed by the repeatable and background files, and
+// then the tracked files that are no longer in the sequence in the order they were applied.
+func (mng *Manager) Status(db Executor) ([]MigrationStatus, error) {
+	if err := mng.verifyApplicationID(db, false); err != nil {
+		return nil, err
+	}
+	entries, problems := mng.versionEntries()
+	if len(problems) != 0 {
+		return nil, problems[0]
+	}
+	repeatables, err := mng.Repeatables()
+	if err != nil {
+		return nil, err
+	}
+	backgrounds, err := mng.Backgrounds()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		rows    = make(map[string]trackingRow)
+		order   []string
+		tracked []trackedVersion
+	)
+	if mng.HasTrackingTable(db) {
+		if rows, order, err = mng.trackingRows(db); err != nil {
+			return nil, err
+		}
+		if tracked, err = mng.trackedVersions(db); err != nil {
+			return nil, err
+		}
+	}
+	// the baselines whose squashed versions were applied, are applied
+	resolved, err := resolveBaselines(tracked, entries)
+	if err != nil {
+		return nil, err
+	}
+	appliedBaseline := make(map[string]bool)
+	for _, version := range resolved {
+		if _, ok := rows[version.Name]; !ok {
+			appliedBaseline[version.Name] = true
+		}
+	}
+
+	var (
+		statuses = make([]MigrationStatus, 0, len(entries)+len(repeatables))
+		listed   = make(map[string]bool, len(entries)+len(repeatables))
+	)
+	fileStatus := func(name, kind string) MigrationStatus {
+		status := MigrationStatus{Version: name, Kind: kind, InSequence: true}
+		row, applied := rows[name]
+		if applied {
+			status.Hash, status.AppliedAt, status.Author = row.Hash, row.CreatedAt, row.Author
+		}
+		_, hash, err := mng.renderSQLFile(filepath.Join(mng.dir, name))
+		switch {
+		case err != nil && !mng.exists(filepath.Join(mng.dir, name)):
+			status.State = StateMissing
+		case applied && row.Kind == TrackingKindSkipped:
+			status.State = StateSkipped
+		case applied && row.Kind == TrackingKindBackground && !row.Completed:
+			status.State = StateInProgress
+		case applied && hash != row.Hash:
+			// a template that no longer renders is also considered modified
+			status.State = StateModified
+		case applied || appliedBaseline[name]:
+			status.State = StateApplied
+		default:
+			status.State = StatePending
+		}
+		return status
+	}
+
+	var addSquashed func(entry versionEntry)
+	addSquashed = func(entry versionEntry) {
+		for _, squashed := range entry.Squashed {
+			addSquashed(squashed)
+			row, applied := rows[squashed.Name]
+			if !applied || listed[squashed.Name] {
+				continue
+			}
+			listed[squashed.Name] = true
+			statuses = append(statuses, MigrationStatus{
+				Version:   squashed.Name,
+				Kind:      TrackingKindVersion,
+				State:     StateBaselined,
+				Hash:      row.Hash,
+				AppliedAt: row.CreatedAt,
+				Author:    row.Author,
+			})
+		}
+	}
+	for _, entry := range entries {
+		addSquashed(entry)
+		listed[entry.Name] = true
+		statuses = append(statuses, fileStatus(entry.Name, TrackingKindVersion))
+	}
+	for _, name := range repeatables {
+		listed[name] = true
+		statuses = append(statuses, fileStatus(name, TrackingKindRepeatable))
+	}
+	for _, name := range backgrounds {
+		listed[name] = true
+		statuses = append(statuses, fileStatus(name, TrackingKindBackground))
+	}
+	for _, name := range order {
+		if listed[name] {
+			continue
+		}
+		row := rows[name]
+		kind := row.Kind
+		if kind == TrackingKindSkipped {
+			kind = TrackingKindVersion
+		}
+		statuses = append(statuses, MigrationStatus{
+			Version:   name,
+			Kind:      kind,
+			State:     StateMissing,
+			Hash:      row.Hash,
+			AppliedAt: row.CreatedAt,
+			Author:    row.Author,
+		})
+	}
+	return statuses, nil
+}
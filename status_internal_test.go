@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_Status(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt":  {Data: []byte("a.sql\nb.sql\nc.sql\ndev.sql @dev\n")},
+		"migrations/a.sql":         {Data: []byte("CREATE TABLE a ( name TEXT );")},
+		"migrations/b.sql":         {Data: []byte("CREATE TABLE b ( name TEXT );")},
+		"migrations/c.sql":         {Data: []byte("CREATE TABLE c ( name TEXT );")},
+		"migrations/dev.sql":       {Data: []byte("CREATE TABLE dev ( name TEXT );")},
+		"migrations/R__lookup.sql": {Data: []byte("CREATE TABLE IF NOT EXISTS lookup ( code TEXT );")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatalf("open error, expected nil got %v", err)
+	}
+	defer db.Close()
+
+	states := func() map[string]MigrationState {
+		t.Helper()
+		statuses, err := migrations.Status(db)
+		if err != nil {
+			t.Fatalf("status error, expected nil got %v", err)
+		}
+		got := make(map[string]MigrationState, len(statuses))
+		for _, status := range statuses {
+			got[status.Version] = status.State
+		}
+		return got
+	}
+
+	// a new database, without a tracking table
+	expected := map[string]MigrationState{
+		"a.sql":         StatePending,
+		"b.sql":         StatePending,
+		"c.sql":         StatePending,
+		"dev.sql":       StatePending,
+		"R__lookup.sql": StatePending,
+	}
+	if got := states(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("new status, expected %v got %v", expected, got)
+	}
+
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Fatalf("upgrade error, expected nil got %v", err)
+	}
+	// modify b, remove c from disk and a from the sequence
+	fsys["migrations/b.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b ( name TEXT, value TEXT );")}
+	delete(fsys, "migrations/c.sql")
+	fsys["migrations/sequence.txt"] = &fstest.MapFile{Data: []byte("b.sql\nc.sql\ndev.sql @dev\nd.sql\n")}
+	fsys["migrations/d.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE d ( name TEXT );")}
+	expected = map[string]MigrationState{
+		"a.sql":         StateMissing,
+		"b.sql":         StateModified,
+		"c.sql":         StateMissing,
+		"dev.sql":       StateSkipped,
+		"d.sql":         StatePending,
+		"R__lookup.sql": StateApplied,
+	}
+	if got := states(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("changed status, expected %v got %v", expected, got)
+	}
+
+	// squash a and b into a baseline
+	fsys["migrations/sequence.txt"] = &fstest.MapFile{Data: []byte("baseline base.sql squashed.txt\nc.sql\ndev.sql @dev\n")}
+	fsys["migrations/squashed.txt"] = &fstest.MapFile{Data: []byte("a.sql\nb.sql\n")}
+	fsys["migrations/base.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a ( name TEXT );\nCREATE TABLE b ( name TEXT );")}
+	fsys["migrations/c.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE c ( name TEXT );")}
+	expected = map[string]MigrationState{
+		"a.sql":         StateBaselined,
+		"b.sql":         StateBaselined,
+		"base.sql":      StateApplied,
+		"c.sql":         StateApplied,
+		"dev.sql":       StateSkipped,
+		"R__lookup.sql": StateApplied,
+	}
+	if got := states(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("baseline status, expected %v got %v", expected, got)
+	}
+}
+
+func TestManager_StatusOldTrackingTable(t *testing.T) {
+	migrations := New(filepath.Join("testdata", "upgrade_issue", "migrations"), "gen_migrations", testdataFS)
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	db, err := openDBCopy(filepath.Join("testdata", "upgrade_issue", "test.db"), dbFilename)
+	if err != nil {
+		t.Fatalf("openDBCopy error expected nil, got error: %v", err)
+	}
+	defer db.Close()
+
+	statuses, err := migrations.Status(db)
+	if err != nil {
+		t.Fatalf("status error, expected nil got %v", err)
+	}
+	got := make(map[string]MigrationState, len(statuses))
+	for _, status := range statuses {
+		got[status.Version] = status.State
+	}
+	expected := map[string]MigrationState{
+		"simpletable.sql":   StateApplied,
+		"simple_table2.sql": StatePending,
+		"simpletable2.sql":  StateMissing,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("status, expected %v got %v", expected, got)
+	}
+}
@@ -0,0 +1,3 @@
+CREATE TABLE roles (
+    name TEXT
+);
@@ -0,0 +1,3 @@
+CREATE TABLE things (
+    name TEXT
+);
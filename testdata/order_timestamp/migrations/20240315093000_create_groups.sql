@@ -0,0 +1,3 @@
+CREATE TABLE groups (
+    name TEXT
+);
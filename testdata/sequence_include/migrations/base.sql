@@ -0,0 +1,3 @@
+CREATE TABLE base (
+    name TEXT
+);
@@ -0,0 +1,3 @@
+CREATE TABLE extra (
+    name TEXT
+);
@@ -0,0 +1,4 @@
+CREATE TABLE users (
+    name TEXT
+  , base_name TEXT REFERENCES base(name)
+);
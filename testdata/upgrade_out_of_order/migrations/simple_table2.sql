@@ -0,0 +1,4 @@
+CREATE TABLE aTable2 (
+    name TEXT
+  , value TEXT
+);
\ No newline at end of file
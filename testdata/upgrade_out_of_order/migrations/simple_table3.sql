@@ -0,0 +1,4 @@
+CREATE TABLE aTable3 (
+    name TEXT
+  , value TEXT
+);
@@ -0,0 +1 @@
+--{{ if }}--
@@ -0,0 +1 @@
+  , value TEXT
@@ -0,0 +1,2 @@
+--{{ partial "columns.sql" }}--
+--{{ partial "nope.sql" }}--
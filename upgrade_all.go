@@ -0,0 +1,112 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// UpgradeAllOptions are the options for UpgradeAll
+type UpgradeAllOptions struct {
+	// Manager is used to upgrade each of the database files
+	Manager *Manager
+	// Author to record in the tracking table
+	Author string
+	// Parallel is the maximum number of database files upgraded at the same time, defaults to 1
+	Parallel int
+	// Open will open the database file; defaults to opening it with the sqlite3 driver,
+	// which must be registered by the caller.
+	Open func(path string) (*sql.DB, error)
+}
+
+// UpgradeResult is the outcome of upgrading a single database file
+type UpgradeResult struct {
+	Path string
+	// From is the version of the database before the upgrade, empty for a new database
+	From string
+	// To is the version of the database after the upgrade
+	To       string
+	Duration time.Duration
+	Err      error
+}
+
+// UpgradeAll will upgrade the database files at paths, using up to opts.Parallel workers. A failure to
+// upgrade one file does not stop the others from being upgraded; if the context is cancelled, files that
+// have not been started are not upgraded and have the context's error as their Err.
+// The results are in the same order as paths; if any upgrade failed an ErrUpgradeAll is returned as well.
+func UpgradeAll(ctx context.Context, paths []string, opts UpgradeAllOptions) ([]UpgradeResult, error) {
+	if opts.Manager == nil {
+		panic("manager is nil")
+	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	open := opts.Open
+	if open == nil {
+		open = func(path string) (*sql.DB, error) { return sql.Open("sqlite3", path) }
+	}
+
+	var (
+		results = make([]UpgradeResult, len(paths))
+		work    = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				results[idx] = upgradeFile(ctx, opts.Manager, open, paths[idx], opts.Author)
+			}
+		}()
+	}
+	for i := range paths {
+		results[i].Path = paths[i]
+	}
+queue:
+	for i := range paths {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			for j := i; j < len(paths); j++ {
+				results[j].Err = ctx.Err()
+			}
+			break queue
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return results, ErrUpgradeAll{Failed: failed, Total: len(paths)}
+	}
+	return results, nil
+}
+
+// upgradeFile will open, and upgrade, the database file at path
+func upgradeFile(ctx context.Context, mng *Manager, open func(string) (*sql.DB, error), path, author string) (result UpgradeResult) {
+	result.Path = path
+	if err := ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+	startT := time.Now()
+	defer func() { result.Duration = time.Now().Sub(startT) }()
+
+	db, err := open(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer db.Close()
+	result.From, result.To, result.Err = mng.Upgrade(db, author)
+	return result
+}
@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUpgradeAll(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt": {Data: []byte("a.sql\nb.sql\n")},
+		"migrations/a.sql":        {Data: []byte("CREATE TABLE a ( name TEXT );")},
+		"migrations/b.sql":        {Data: []byte("CREATE TABLE b ( name TEXT );")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+
+	var paths []string
+	for i := 0; i < 5; i++ {
+		filename, cleanup := NewTestDBFilename(t, nil)
+		defer cleanup()
+		paths = append(paths, filename)
+	}
+	// the third file is not a database
+	if err := ioutil.WriteFile(paths[2], []byte("not a database, but a long enough line of text to be a header"), 0644); err != nil {
+		t.Fatalf("write error, expected nil got %v", err)
+	}
+
+	results, err := UpgradeAll(context.Background(), paths, UpgradeAllOptions{
+		Manager:  migrations,
+		Author:   "test",
+		Parallel: 2,
+	})
+	var errAll ErrUpgradeAll
+	if !errors.As(err, &errAll) || errAll.Failed != 1 || errAll.Total != 5 {
+		t.Fatalf("upgrade all error, expected 1 of 5 failed got %v", err)
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("results, expected %v got %v", len(paths), len(results))
+	}
+	for i, result := range results {
+		if result.Path != paths[i] {
+			t.Errorf("[%v] path, expected %v got %v", i, paths[i], result.Path)
+		}
+		if i == 2 {
+			if result.Err == nil {
+				t.Errorf("[%v] error, expected error got nil", i)
+			}
+			continue
+		}
+		if result.Err != nil || result.From != "" || result.To != "b.sql" {
+			t.Errorf("[%v] result, expected '' to b.sql got %q to %q, %v", i, result.From, result.To, result.Err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = UpgradeAll(ctx, paths[:2], UpgradeAllOptions{Manager: migrations, Author: "test"})
+	if !errors.As(err, &errAll) || errAll.Failed != 2 {
+		t.Fatalf("cancelled upgrade all error, expected 2 failed got %v", err)
+	}
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("[%v] cancelled error, expected context.Canceled got %v", i, result.Err)
+		}
+	}
+}
+
+func TestUpgradeAll_NilFS(t *testing.T) {
+	// a nil fs, as the migrate command uses, reads the migration files from the os; run with -race
+	migrations := New(filepath.Join("testdata", "upgrade_simple", "migrations"), "gen_migrations", nil)
+
+	var paths []string
+	for i := 0; i < 4; i++ {
+		filename, cleanup := NewTestDBFilename(t, nil)
+		defer cleanup()
+		paths = append(paths, filename)
+	}
+	results, err := UpgradeAll(context.Background(), paths, UpgradeAllOptions{
+		Manager:  migrations,
+		Author:   "test",
+		Parallel: 4,
+	})
+	if err != nil {
+		t.Fatalf("upgrade all error, expected nil got %v", err)
+	}
+	for i, result := range results {
+		if result.Err != nil || result.To != "simple_table2.sql" {
+			t.Errorf("[%v] result, expected simple_table2.sql got %q, %v", i, result.To, result.Err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+)
+
+// SyncUserVersion reports whether PRAGMA user_version is kept in sync with the version of the database
+func (mng *Manager) SyncUserVersion() bool { return mng != nil && mng.syncUserVersion }
+
+// SetSyncUserVersion sets whether PRAGMA user_version is kept in sync with the version of the database.
+//
+// The user_version is set to the position of the current version in the sequence; the first entry is 1.
+// A baseline counts as the number of versions squashed into it, so squashing does not change the user_version.
+// Init checks the user_version against the tracking table, and returns an ErrUserVersionDrift if they do
+// not match; unless out of order versions are allowed, as the positions change when versions are inserted.
+// A database with a user_version of 0 has not been synced, and is synced by Init.
+func (mng *Manager) SetSyncUserVersion(sync bool) {
+	if mng == nil {
+		return
+	}
+	mng.syncUserVersion = sync
+}
+
+// UserVersion returns the PRAGMA user_version of the db
+func (mng *Manager) UserVersion(db Executor) (userVersion int, err error) {
+	err = db.QueryRowContext(context.Background(), fmt.Sprintf(`PRAGMA %v.user_version;`, quoteIdentifier(mng.Schema()))).
+		Scan(&userVersion)
+	return userVersion, err
+}
+
+// setUserVersion sets the PRAGMA user_version of the db
+func (mng *Manager) setUserVersion(db Executor, userVersion int) error {
+	// pragmas do not take parameters
+	_, err := db.ExecContext(context.Background(), fmt.Sprintf(`PRAGMA %v.user_version = %d;`, quoteIdentifier(mng.Schema()), userVersion))
+	return err
+}
+
+// userVersionOf returns the user_version for the version; ok is false if the version is not in the sequence
+func userVersionOf(entries []versionEntry, version string) (userVersion int, ok bool) {
+	if version == InitialVersion {
+		return 0, true
+	}
+	for _, entry := range entries {
+		userVersion += entry.versionCount()
+		if entry.Name == version {
+			return userVersion, true
+		}
+	}
+	return 0, false
+}
+
+// syncUserVersionTo will set the user_version to that of the version, if user_version is being synced
+func (mng *Manager) syncUserVersionTo(db Executor, entries []versionEntry, version string) error {
+	if !mng.SyncUserVersion() {
+		return nil
+	}
+	userVersion, ok := userVersionOf(entries, version)
+	if !ok {
+		return nil
+	}
+	return mng.setUserVersion(db, userVersion)
+}
+
+// checkUserVersion will check that the user_version matches the version of the database
+func (mng *Manager) checkUserVersion(db Executor, version string) error {
+	if !mng.SyncUserVersion() {
+		return nil
+	}
+	entries, problems := mng.versionEntries()
+	if len(problems) != 0 {
+		return problems[0]
+	}
+	expected, ok := userVersionOf(entries, version)
+	if !ok {
+		// the version is not in the sequence, which will be reported as a divergence
+		return nil
+	}
+	userVersion, err := mng.UserVersion(db)
+	if err != nil {
+		return err
+	}
+	switch {
+	case userVersion == expected:
+		return nil
+	case userVersion == 0:
+		// not synced yet
+		return mng.setUserVersion(db, expected)
+	case mng.AllowOutOfOrder():
+		mng.Log().Printf("user_version %v does not match version %v (%v), updating it", userVersion, version, expected)
+		return mng.setUserVersion(db, expected)
+	default:
+		return ErrUserVersionDrift{Version: version, Expected: expected, UserVersion: userVersion}
+	}
+}
@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManager_SyncUserVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/sequence.txt": {Data: []byte("a.sql\nb.sql\n")},
+		"migrations/a.sql":        {Data: []byte("CREATE TABLE a ( name TEXT );")},
+		"migrations/b.sql":        {Data: []byte("CREATE TABLE b ( name TEXT );")},
+		"migrations/c.sql":        {Data: []byte("CREATE TABLE c ( name TEXT );")},
+	}
+	migrations := New("migrations", "gen_migrations", fsys)
+	migrations.SetSyncUserVersion(true)
+
+	dbFilename, cleanup := NewTestDBFilename(t, nil)
+	defer cleanup()
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatalf("open error, expected nil got %v", err)
+	}
+	defer db.Close()
+	userVersion := func(expected int) {
+		t.Helper()
+		got, err := migrations.UserVersion(db)
+		if err != nil {
+			t.Fatalf("user version error, expected nil got %v", err)
+		}
+		if got != expected {
+			t.Errorf("user version, expected %v got %v", expected, got)
+		}
+	}
+
+	if _, _, err = migrations.UpgradeTo(db, "test", "a.sql"); err != nil {
+		t.Fatalf("upgrade to error, expected nil got %v", err)
+	}
+	userVersion(1)
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Fatalf("upgrade error, expected nil got %v", err)
+	}
+	userVersion(2)
+
+	// squashing a and b into a baseline does not change the user_version
+	fsys["migrations/sequence.txt"] = &fstest.MapFile{Data: []byte("baseline base.sql squashed.txt\nc.sql\n")}
+	fsys["migrations/squashed.txt"] = &fstest.MapFile{Data: []byte("a.sql\nb.sql\n")}
+	fsys["migrations/base.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a ( name TEXT );\nCREATE TABLE b ( name TEXT );")}
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Fatalf("upgrade after squash error, expected nil got %v", err)
+	}
+	userVersion(3)
+
+	// changing the user_version outside of the migrations is drift
+	if _, err = db.Exec(`PRAGMA user_version = 42;`); err != nil {
+		t.Fatalf("set user version error, expected nil got %v", err)
+	}
+	_, _, err = migrations.Init(db, "test")
+	var drift ErrUserVersionDrift
+	if !errors.As(err, &drift) {
+		t.Fatalf("init error, expected ErrUserVersionDrift got %v", err)
+	}
+	if drift.Expected != 3 || drift.UserVersion != 42 || drift.Version != "c.sql" {
+		t.Errorf("drift, expected c.sql at 3 got %+v", drift)
+	}
+
+	// a database that was never synced is synced by Init
+	if _, err = db.Exec(`PRAGMA user_version = 0;`); err != nil {
+		t.Fatalf("reset user version error, expected nil got %v", err)
+	}
+	if _, _, err = migrations.Init(db, "test"); err != nil {
+		t.Fatalf("init error, expected nil got %v", err)
+	}
+	userVersion(3)
+
+	// squashing the baseline and c into a second baseline counts only the versions, not the first baseline
+	fsys["migrations/sequence.txt"] = &fstest.MapFile{Data: []byte("baseline base2.sql squashed2.txt\nd.sql\n")}
+	fsys["migrations/squashed2.txt"] = &fstest.MapFile{Data: []byte("baseline base.sql squashed.txt\nc.sql\n")}
+	fsys["migrations/base2.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a ( name TEXT );\nCREATE TABLE b ( name TEXT );\nCREATE TABLE c ( name TEXT );")}
+	fsys["migrations/d.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE d ( name TEXT );")}
+	if _, _, err = migrations.Upgrade(db, "test"); err != nil {
+		t.Fatalf("upgrade after second squash error, expected nil got %v", err)
+	}
+	userVersion(4)
+}
@@ -0,0 +1,198 @@
+package migration
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const (
+	// PartialDir is the directory, in the migration directory, that partials are loaded from.
+	PartialDir = "partial"
+)
+
+// Validate will check the migration directory, without a database, for problems that would
+// otherwise only show up while upgrading a database. It checks that:
+//   - the sequence files are well formed, and do not include each other in a cycle
+//   - every entry in the sequence exists, and is listed only once
+//   - there are no sql files that are not referenced by the sequence, or are repeatable or background files
+//   - all templates parse, and all the partials they reference exist
+//   - all background files declare the table they are for
+//   - the config file, if there is one, is well formed
+//
+// If any problems are found an ErrValidation containing all of them is returned.
+func (mng *Manager) Validate() error {
+	entries, problems := mng.versionEntries()
+	if _, err := mng.Config(); err != nil {
+		problems = append(problems, err)
+	}
+
+	referenced := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		referenced[entry.Name] = true
+		// squashed versions are no longer applied, and may have been removed
+		for _, name := range entry.squashedNames() {
+			referenced[name] = true
+		}
+		referenced[downFilename(entry.Name)] = true
+		filename := filepath.Join(mng.dir, entry.Name)
+		info, err := fs.Stat(mng.FS(), filename)
+		if err != nil || info.IsDir() {
+			problems = append(problems, ErrMissingFile{
+				Filename: filename,
+				Source:   filepath.Join(mng.dir, entry.Source),
+				LineNo:   entry.LineNo,
+			})
+			continue
+		}
+		if !strings.HasSuffix(entry.Name, "tpl") {
+			continue
+		}
+		problems = append(problems, mng.validateTemplate(filename)...)
+	}
+
+	repeatables, err := mng.Repeatables()
+	if err != nil {
+		problems = append(problems, err)
+	}
+	for _, name := range repeatables {
+		referenced[name] = true
+		if strings.HasSuffix(name, "tpl") {
+			problems = append(problems, mng.validateTemplate(filepath.Join(mng.dir, name))...)
+		}
+	}
+
+	backgrounds, err := mng.Backgrounds()
+	if err != nil {
+		problems = append(problems, err)
+	}
+	for _, name := range backgrounds {
+		referenced[name] = true
+		if _, err := mng.loadBackground(name); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	unreferenced, err := mng.unreferencedSQLFiles(referenced)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	for _, name := range unreferenced {
+		problems = append(problems, ErrUnreferencedFile(filepath.Join(mng.dir, name)))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return ErrValidation(problems)
+}
+
+// validateTemplate will parse the template and check that all the partials it references exist.
+func (mng *Manager) validateTemplate(filename string) (problems []error) {
+	body, err := mng.readAllFile(filename)
+	if err != nil {
+		return []error{ErrApplyFileRead{Err: err, Filename: filename}}
+	}
+	tmpl, err := template.New(filename).
+		Delims("--{{", "}}--").
+		Funcs(mng.FuncMap()).
+		Parse(string(body))
+	if err != nil {
+		return []error{ErrApplyFileTemplate{Err: err, Filename: filename}}
+	}
+	var partials []string
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		partials = templatePartials(partials, t.Tree.Root)
+	}
+	for _, partial := range partials {
+		if !mng.exists(filepath.Join(mng.dir, PartialDir, partial)) {
+			problems = append(problems, ErrMissingPartial{Filename: filename, Partial: partial})
+		}
+	}
+	return problems
+}
+
+// templatePartials will walk the template tree and collect the names of partials that are referenced
+// with a string literal.
+func templatePartials(partials []string, node parse.Node) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return partials
+		}
+		for _, child := range n.Nodes {
+			partials = templatePartials(partials, child)
+		}
+	case *parse.ActionNode:
+		partials = templatePartials(partials, n.Pipe)
+	case *parse.IfNode:
+		partials = templatePartials(partials, &n.BranchNode)
+	case *parse.RangeNode:
+		partials = templatePartials(partials, &n.BranchNode)
+	case *parse.WithNode:
+		partials = templatePartials(partials, &n.BranchNode)
+	case *parse.BranchNode:
+		partials = templatePartials(partials, n.Pipe)
+		partials = templatePartials(partials, n.List)
+		partials = templatePartials(partials, n.ElseList)
+	case *parse.TemplateNode:
+		partials = templatePartials(partials, n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return partials
+		}
+		for _, cmd := range n.Cmds {
+			partials = templatePartials(partials, cmd)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) == 2 {
+			ident, isIdent := n.Args[0].(*parse.IdentifierNode)
+			str, isString := n.Args[1].(*parse.StringNode)
+			if isIdent && isString && ident.Ident == "partial" {
+				partials = append(partials, str.Text)
+			}
+		}
+		for _, arg := range n.Args {
+			partials = templatePartials(partials, arg)
+		}
+	}
+	return partials
+}
+
+// unreferencedSQLFiles will return the sql files in the migration directory (other than the partials)
+// that are not in referenced.
+func (mng *Manager) unreferencedSQLFiles(referenced map[string]bool) (names []string, err error) {
+	root := filepath.Clean(mng.dir)
+	err = fs.WalkDir(mng.FS(), root, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := filename
+		if root != "." {
+			name = strings.TrimPrefix(filename, root+"/")
+		}
+		if d.IsDir() {
+			if name == PartialDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isSQLFile(name) || referenced[name] {
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %v: %w", root, err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
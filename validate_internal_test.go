@@ -0,0 +1,64 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManager_Validate(t *testing.T) {
+	type tcase struct {
+		testDir string
+		// problems are the types of problems expected, in order
+		problems []string
+	}
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			migrations := New(filepath.Join("testdata", tc.testDir, "migrations"), "", testdataFS)
+			err := migrations.Validate()
+			if len(tc.problems) == 0 {
+				if err != nil {
+					t.Errorf("validate, expected nil got %v", err)
+				}
+				return
+			}
+			var vErr ErrValidation
+			if !errors.As(err, &vErr) {
+				t.Fatalf("validate, expected ErrValidation got %v", err)
+			}
+			problems := make([]string, len(vErr))
+			for i := range vErr {
+				problems[i] = fmt.Sprintf("%T", vErr[i])
+			}
+			if !reflect.DeepEqual(tc.problems, problems) {
+				t.Errorf("problems,\n\texpected %v\n\t     got %v\n%v", tc.problems, problems, err)
+			}
+		}
+	}
+	tests := map[string]tcase{
+		"sequence_include": {},
+		"upgrade_simple":   {},
+		"validate_down": {
+			// 0002_emails.down.sql does not have a version
+			problems: []string{"migration.ErrUnreferencedFile"},
+		},
+		"validate_problems": {
+			problems: []string{
+				"migration.ErrInvalidSequenceEntry",
+				"migration.ErrDuplicateVersion",
+				"migration.ErrMissingFile",
+				"migration.ErrMissingPartial",
+				"migration.ErrApplyFileTemplate",
+				"migration.ErrUnreferencedFile",
+			},
+		},
+	}
+	for name, tc := range tests {
+		if tc.testDir == "" {
+			tc.testDir = name
+		}
+		t.Run(name, fn(tc))
+	}
+}
@@ -3,6 +3,7 @@ package migration
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -67,3 +68,131 @@ func Test_ParseMigrationSequenceFile(t *testing.T) {
 		t.Run(name, fn(tc))
 	}
 }
+
+func Test_ManagerVersionsCycle(t *testing.T) {
+	migrations := New(filepath.Join("testdata", "sequence_cycle", "migrations"), "", testdataFS)
+	_, err := migrations.Versions()
+	expected := ErrSequenceCycle{"sequence.txt", "other.txt", "sequence.txt"}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("versions error, expected %v got %v", expected, err)
+	}
+}
+
+func Test_ManagerVersionsOrderByFilename(t *testing.T) {
+	type tcase struct {
+		versions []string
+		problems []error
+	}
+	fn := func(dir string, tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			migrations := New(filepath.Join("testdata", dir, "migrations"), "", testdataFS)
+			migrations.SetOrdering(OrderByFilename)
+			entries, problems := migrations.versionEntries()
+			if !reflect.DeepEqual(problems, tc.problems) {
+				t.Errorf("problems,\n\texpected %v\n\t     got %v", tc.problems, problems)
+			}
+			if len(tc.problems) != 0 {
+				return
+			}
+			versions, err := migrations.Versions()
+			if err != nil {
+				t.Fatalf("versions error, expected nil got %v", err)
+			}
+			if !reflect.DeepEqual(versions, tc.versions) {
+				t.Errorf("versions,\n\texpected %v\n\t     got %v", tc.versions, versions)
+			}
+			if len(entries) != len(versions)-1 {
+				t.Errorf("entries, expected %v got %v", len(versions)-1, len(entries))
+			}
+		}
+	}
+	problemsDir := filepath.Join("testdata", "order_problems", "migrations")
+	tests := map[string]tcase{
+		"order_numeric": {
+			versions: []string{"", "0001_create_users.sql", "0002_create_groups.sql", "0003_index_users.sql.tpl"},
+		},
+		"order_timestamp": {
+			versions: []string{"", "20240101120000_create_users.sql", "20240315093000_create_groups.sql"},
+		},
+		"order_problems": {
+			problems: []error{
+				ErrFilenameConvention{Dir: problemsDir, Filenames: []string{"create_things.sql"}},
+				ErrPrefixGap{Dir: problemsDir, After: "0001_create_users.sql", Next: "0003_create_groups.sql"},
+				ErrDuplicatePrefix{Dir: problemsDir, Filenames: []string{"0003_create_groups.sql", "0003_create_roles.sql"}},
+			},
+		},
+		// sequence.txt still takes precedence
+		"upgrade_simple": {
+			versions: []string{"", "simpletable.sql", "simple_table2.sql"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, fn(name, tc))
+	}
+}
+
+func Test_ParseSequenceLine(t *testing.T) {
+	type tcase struct {
+		line      string
+		directive sequenceDirective
+		reason    string
+	}
+	fn := func(tc tcase) func(*testing.T) {
+		return func(t *testing.T) {
+			directive, reason := parseSequenceLine(tc.line)
+			if reason != tc.reason {
+				t.Errorf("reason, expected '%v' got '%v'", tc.reason, reason)
+				return
+			}
+			if !reflect.DeepEqual(directive, tc.directive) {
+				t.Errorf("directive, expected %+v got %+v", tc.directive, directive)
+			}
+		}
+	}
+	tests := map[string]tcase{
+		"entry": {
+			line:      "2020/add_user.sql",
+			directive: sequenceDirective{Value: "2020/add_user.sql"},
+		},
+		"entry with spaces": {
+			line:      "2020/add user table.sql",
+			directive: sequenceDirective{Value: "2020/add user table.sql"},
+		},
+		"entry with environments": {
+			line:      "seed_users.sql @dev   @test",
+			directive: sequenceDirective{Value: "seed_users.sql", Environments: []string{"dev", "test"}},
+		},
+		"include": {
+			line:      "include stage_data/sequence.txt",
+			directive: sequenceDirective{Directive: DirectiveInclude, Value: "stage_data/sequence.txt"},
+		},
+		"include with environments": {
+			line: "include stage_data/sequence.txt @dev",
+			directive: sequenceDirective{
+				Directive:    DirectiveInclude,
+				Value:        "stage_data/sequence.txt",
+				Environments: []string{"dev"},
+			},
+		},
+		"include without file": {
+			line:   "include",
+			reason: "include expects exactly one sequence file",
+		},
+		"unknown directive": {
+			line:      "exclude foo.sql @dev",
+			directive: sequenceDirective{Environments: []string{"dev"}},
+			reason:    "unknown directive `exclude`",
+		},
+		"invalid environment": {
+			line:   "foo.sql @dev!",
+			reason: "invalid environment tag `@dev!`",
+		},
+		"only environment": {
+			line:   "@dev",
+			reason: "environment tags must follow an entry",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, fn(tc))
+	}
+}